@@ -174,6 +174,12 @@ func newClient(
 				return nil, fmt.Errorf("%s is not a valid QUIC version", v)
 			}
 		}
+		if err := validateMaxPacketSize(config.MaxPacketSize); err != nil {
+			return nil, err
+		}
+		if err := validatePacketNumberLength(config.PacketNumberLength); err != nil {
+			return nil, err
+		}
 	}
 	onClose := func(protocol.ConnectionID) {}
 	if closeCallback != nil {
@@ -253,6 +259,20 @@ func populateClientConfig(config *Config, createdPacketConn bool) *Config {
 		MaxIncomingStreams:                    maxIncomingStreams,
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
 		KeepAlive:                             config.KeepAlive,
+		IdleTimeoutRetransmittableOnly:        config.IdleTimeoutRetransmittableOnly,
+		PublicResetOnCryptoError:              config.PublicResetOnCryptoError,
+		MaxPacketSize:                         config.MaxPacketSize,
+		PacketNumberLength:                    config.PacketNumberLength,
+		MaxBytesInFlight:                      config.MaxBytesInFlight,
+		MaxAckDelay:                           config.MaxAckDelay,
+		WriteCoalescingDelay:                  config.WriteCoalescingDelay,
+		CongestionControl:                     config.CongestionControl,
+		OnConnectionWindowUpdate:              config.OnConnectionWindowUpdate,
+		OnStreamClosed:                        config.OnStreamClosed,
+		EnableDatagrams:                       config.EnableDatagrams,
+		OnDatagram:                            config.OnDatagram,
+		PacketSink:                            config.PacketSink,
+		Tracer:                                config.Tracer,
 	}
 }
 
@@ -312,6 +332,7 @@ func (c *client) dialTLS(ctx context.Context) error {
 		MaxBidiStreams:              uint16(c.config.MaxIncomingStreams),
 		MaxUniStreams:               uint16(c.config.MaxIncomingUniStreams),
 		DisableMigration:            true,
+		SupportsDatagrams:           c.config.EnableDatagrams,
 	}
 	extHandler := handshake.NewExtensionHandlerClient(params, c.initialVersion, c.config.Versions, c.version, c.logger)
 	mintConf, err := tlsToMintConfig(c.tlsConf, protocol.PerspectiveClient)
@@ -513,6 +534,7 @@ func (c *client) createNewGQUICSession() error {
 		c.config,
 		c.initialVersion,
 		c.negotiatedVersions,
+		utils.DefaultClock{},
 		c.logger,
 	)
 	if err != nil {
@@ -546,6 +568,7 @@ func (c *client) createNewTLSSession(
 		c.mintConf,
 		paramsChan,
 		1,
+		utils.DefaultClock{},
 		c.logger,
 		c.version,
 	)
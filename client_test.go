@@ -33,7 +33,7 @@ var _ = Describe("Client", func() {
 
 		supportedVersionsWithoutGQUIC44 []protocol.VersionNumber
 
-		originalClientSessConstructor func(connection, sessionRunner, protocol.VersionNumber, protocol.ConnectionID, protocol.ConnectionID, *tls.Config, *Config, protocol.VersionNumber, []protocol.VersionNumber, utils.Logger) (quicSession, error)
+		originalClientSessConstructor func(connection, sessionRunner, protocol.VersionNumber, protocol.ConnectionID, protocol.ConnectionID, *tls.Config, *Config, protocol.VersionNumber, []protocol.VersionNumber, utils.Clock, utils.Logger) (quicSession, error)
 	)
 
 	// generate a packet sent by the server that accepts the QUIC version suggested by the client
@@ -139,6 +139,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				remoteAddrChan <- conn.RemoteAddr().String()
@@ -167,6 +168,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				hostnameChan <- tlsConf.ServerName
@@ -195,6 +197,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				sess := NewMockQuicSession(mockCtrl)
@@ -242,6 +245,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				sess := NewMockQuicSession(mockCtrl)
@@ -280,6 +284,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				return sess, nil
@@ -323,6 +328,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				runner = runnerP
@@ -362,6 +368,7 @@ var _ = Describe("Client", func() {
 				_ *Config,
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				conn = connP
@@ -415,6 +422,78 @@ var _ = Describe("Client", func() {
 				Expect(c.ConnectionIDLength).To(Equal(13))
 			})
 
+			It("copies the congestion control", func() {
+				cc := NewUnlimitedCongestionControl()
+				config := &Config{CongestionControl: func() CongestionControl { return cc }}
+				c := populateClientConfig(config, false)
+				Expect(c.CongestionControl).ToNot(BeNil())
+				Expect(c.CongestionControl()).To(Equal(cc))
+			})
+
+			It("copies the connection window update callback", func() {
+				var called bool
+				config := &Config{OnConnectionWindowUpdate: func(ByteCount) { called = true }}
+				c := populateClientConfig(config, false)
+				c.OnConnectionWindowUpdate(42)
+				Expect(called).To(BeTrue())
+			})
+
+			It("copies the packet sink", func() {
+				var called bool
+				config := &Config{PacketSink: func(Direction, []byte) { called = true }}
+				c := populateClientConfig(config, false)
+				c.PacketSink(DirectionSent, []byte("foobar"))
+				Expect(called).To(BeTrue())
+			})
+
+			It("copies the tracer constructor", func() {
+				tracer := &recordingTracer{}
+				config := &Config{Tracer: func() Tracer { return tracer }}
+				c := populateClientConfig(config, false)
+				Expect(c.Tracer()).To(Equal(tracer))
+			})
+
+			It("copies the idle timeout reset policy", func() {
+				c := populateClientConfig(&Config{IdleTimeoutRetransmittableOnly: true}, false)
+				Expect(c.IdleTimeoutRetransmittableOnly).To(BeTrue())
+			})
+
+			It("copies the public reset policy for crypto errors", func() {
+				c := populateClientConfig(&Config{PublicResetOnCryptoError: true}, false)
+				Expect(c.PublicResetOnCryptoError).To(BeTrue())
+			})
+
+			It("copies the maximum bytes in flight", func() {
+				c := populateClientConfig(&Config{MaxBytesInFlight: 1 << 20}, false)
+				Expect(c.MaxBytesInFlight).To(Equal(ByteCount(1 << 20)))
+			})
+
+			It("copies the write coalescing delay", func() {
+				config := &Config{WriteCoalescingDelay: 5 * time.Millisecond}
+				c := populateClientConfig(config, false)
+				Expect(c.WriteCoalescingDelay).To(Equal(5 * time.Millisecond))
+			})
+
+			It("copies the OnStreamClosed callback", func() {
+				var called bool
+				config := &Config{OnStreamClosed: func(StreamID, error) { called = true }}
+				c := populateClientConfig(config, false)
+				c.OnStreamClosed(3, nil)
+				Expect(called).To(BeTrue())
+			})
+
+			It("copies the datagram support", func() {
+				c := populateClientConfig(&Config{EnableDatagrams: true}, false)
+				Expect(c.EnableDatagrams).To(BeTrue())
+			})
+
+			It("copies the OnDatagram callback", func() {
+				var data []byte
+				c := populateClientConfig(&Config{OnDatagram: func(b []byte) { data = b }}, false)
+				c.OnDatagram([]byte("foobar"))
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
 			It("uses a 0 byte connection IDs if gQUIC 44 is supported", func() {
 				config := &Config{
 					Versions:           []protocol.VersionNumber{protocol.Version43, protocol.Version44},
@@ -440,6 +519,37 @@ var _ = Describe("Client", func() {
 				Expect(err).To(MatchError("0x1234 is not a valid QUIC version"))
 			})
 
+			It("errors when the Config contains an invalid max packet size", func() {
+				manager := NewMockPacketHandlerManager(mockCtrl)
+				mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any()).Return(manager, nil)
+
+				_, err := Dial(packetConn, nil, "localhost:1234", &tls.Config{}, &Config{Versions: supportedVersionsWithoutGQUIC44, MaxPacketSize: 1000})
+				Expect(err).To(MatchError("invalid MaxPacketSize: 1000 (must be between 1200 and 1452)"))
+			})
+
+			It("copies the max packet size", func() {
+				c := populateClientConfig(&Config{MaxPacketSize: 1300}, false)
+				Expect(c.MaxPacketSize).To(Equal(protocol.ByteCount(1300)))
+			})
+
+			It("errors when the Config contains an invalid packet number length", func() {
+				manager := NewMockPacketHandlerManager(mockCtrl)
+				mockMultiplexer.EXPECT().AddConn(packetConn, gomock.Any()).Return(manager, nil)
+
+				_, err := Dial(packetConn, nil, "localhost:1234", &tls.Config{}, &Config{Versions: supportedVersionsWithoutGQUIC44, PacketNumberLength: 6})
+				Expect(err).To(MatchError("invalid PacketNumberLength: 6 (must be 1, 2 or 4)"))
+			})
+
+			It("copies the packet number length", func() {
+				c := populateClientConfig(&Config{PacketNumberLength: 2}, false)
+				Expect(c.PacketNumberLength).To(Equal(2))
+			})
+
+			It("copies the max ACK delay", func() {
+				c := populateClientConfig(&Config{MaxAckDelay: 42 * time.Millisecond}, false)
+				Expect(c.MaxAckDelay).To(Equal(42 * time.Millisecond))
+			})
+
 			It("disables bidirectional streams", func() {
 				config := &Config{
 					MaxIncomingStreams:    -1,
@@ -491,6 +601,7 @@ var _ = Describe("Client", func() {
 					_ *Config,
 					_ protocol.VersionNumber,
 					_ []protocol.VersionNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 				) (quicSession, error) {
 					return nil, testErr
@@ -527,6 +638,7 @@ var _ = Describe("Client", func() {
 					_ *mint.Config,
 					paramsChan <-chan handshake.TransportParameters,
 					_ protocol.PacketNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 					versionP protocol.VersionNumber,
 				) (quicSession, error) {
@@ -588,6 +700,7 @@ var _ = Describe("Client", func() {
 					_ *mint.Config,
 					_ <-chan handshake.TransportParameters,
 					_ protocol.PacketNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 					_ protocol.VersionNumber,
 				) (quicSession, error) {
@@ -647,6 +760,7 @@ var _ = Describe("Client", func() {
 					_ *mint.Config,
 					_ <-chan handshake.TransportParameters,
 					_ protocol.PacketNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 					_ protocol.VersionNumber,
 				) (quicSession, error) {
@@ -686,6 +800,7 @@ var _ = Describe("Client", func() {
 					_ *Config,
 					_ protocol.VersionNumber,
 					_ []protocol.VersionNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 				) (quicSession, error) {
 					Expect(conn.Write([]byte("0 fake CHLO"))).To(Succeed())
@@ -746,6 +861,7 @@ var _ = Describe("Client", func() {
 					_ *Config,
 					_ protocol.VersionNumber,
 					_ []protocol.VersionNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 				) (quicSession, error) {
 					return <-sessionChan, nil
@@ -793,6 +909,7 @@ var _ = Describe("Client", func() {
 					_ *Config,
 					_ protocol.VersionNumber,
 					_ []protocol.VersionNumber,
+					_ utils.Clock,
 					_ utils.Logger,
 				) (quicSession, error) {
 					return <-sessionChan, nil
@@ -936,6 +1053,7 @@ var _ = Describe("Client", func() {
 			configP *Config,
 			_ protocol.VersionNumber,
 			_ []protocol.VersionNumber,
+			_ utils.Clock,
 			_ utils.Logger,
 		) (quicSession, error) {
 			cconn = connP
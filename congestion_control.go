@@ -0,0 +1,10 @@
+package quic
+
+import "github.com/lucas-clemente/quic-go/internal/congestion"
+
+// NewUnlimitedCongestionControl creates a CongestionControl that never limits sending.
+// It can be used when the bandwidth is known, or when the sending rate is limited by other means.
+// Warning: This API should not be considered stable and might change soon.
+func NewUnlimitedCongestionControl() CongestionControl {
+	return congestion.NewUnlimitedCongestionControl()
+}
@@ -7,6 +7,7 @@ import (
 
 type connection interface {
 	Write([]byte) error
+	WriteTo([]byte, net.Addr) error
 	Read([]byte) (int, net.Addr, error)
 	Close() error
 	LocalAddr() net.Addr
@@ -28,6 +29,11 @@ func (c *conn) Write(p []byte) error {
 	return err
 }
 
+func (c *conn) WriteTo(p []byte, addr net.Addr) error {
+	_, err := c.pconn.WriteTo(p, addr)
+	return err
+}
+
 func (c *conn) Read(p []byte) (int, net.Addr, error) {
 	return c.pconn.ReadFrom(p)
 }
@@ -52,3 +58,7 @@ func (c *conn) RemoteAddr() net.Addr {
 func (c *conn) Close() error {
 	return c.pconn.Close()
 }
+
+func isSameAddr(a, b net.Addr) bool {
+	return a.Network() == b.Network() && a.String() == b.String()
+}
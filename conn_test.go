@@ -78,6 +78,14 @@ var _ = Describe("Connection", func() {
 		Expect(packetConn.dataWrittenTo.String()).To(Equal("192.168.100.200:1337"))
 	})
 
+	It("writes to a different address", func() {
+		err := c.WriteTo([]byte("foobar"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1336})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packetConn.dataWritten.Bytes()).To(Equal([]byte("foobar")))
+		Expect(packetConn.dataWrittenTo.String()).To(Equal("127.0.0.1:1336"))
+		Expect(c.RemoteAddr().String()).To(Equal("192.168.100.200:1337"))
+	})
+
 	It("reads", func() {
 		packetConn.dataToRead <- []byte("foo")
 		packetConn.dataReadFrom = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1336}
@@ -15,6 +15,8 @@ type framer struct {
 	streamQueueMutex sync.Mutex
 	activeStreams    map[protocol.StreamID]struct{}
 	streamQueue      []protocol.StreamID
+	// priorities of the streams, streams that are not in this map have priority 0
+	priorities map[protocol.StreamID]uint8
 
 	controlFrameMutex sync.Mutex
 	controlFrames     []wire.Frame
@@ -29,6 +31,7 @@ func newFramer(
 		streamGetter:  streamGetter,
 		cryptoStream:  cryptoStream,
 		activeStreams: make(map[protocol.StreamID]struct{}),
+		priorities:    make(map[protocol.StreamID]uint8),
 		version:       v,
 	}
 }
@@ -61,14 +64,61 @@ func (f *framer) AppendControlFrames(frames []wire.Frame, maxLen protocol.ByteCo
 func (f *framer) AddActiveStream(id protocol.StreamID) {
 	f.streamQueueMutex.Lock()
 	if _, ok := f.activeStreams[id]; !ok {
-		f.streamQueue = append(f.streamQueue, id)
+		f.queueStream(id)
 		f.activeStreams[id] = struct{}{}
 	}
 	f.streamQueueMutex.Unlock()
 }
 
+// SetStreamPriority sets the priority of a stream.
+// Data of streams with a higher priority is sent first.
+// Streams with the same priority are served round-robin.
+func (f *framer) SetStreamPriority(id protocol.StreamID, priority uint8) {
+	f.streamQueueMutex.Lock()
+	defer f.streamQueueMutex.Unlock()
+
+	if priority == 0 {
+		delete(f.priorities, id)
+	} else {
+		f.priorities[id] = priority
+	}
+	if _, ok := f.activeStreams[id]; !ok {
+		return
+	}
+	// move the stream to its new place in the queue
+	for i, sid := range f.streamQueue {
+		if sid == id {
+			f.streamQueue = append(f.streamQueue[:i], f.streamQueue[i+1:]...)
+			break
+		}
+	}
+	f.queueStream(id)
+}
+
+// RemoveStream forgets the priority of a stream.
+// It is called when a stream is completed.
+func (f *framer) RemoveStream(id protocol.StreamID) {
+	f.streamQueueMutex.Lock()
+	delete(f.priorities, id)
+	f.streamQueueMutex.Unlock()
+}
+
+// queueStream inserts a stream after all streams with the same or a higher priority.
+// The caller must hold the streamQueueMutex.
+func (f *framer) queueStream(id protocol.StreamID) {
+	priority := f.priorities[id]
+	i := len(f.streamQueue)
+	for i > 0 && f.priorities[f.streamQueue[i-1]] < priority {
+		i--
+	}
+	f.streamQueue = append(f.streamQueue, 0)
+	copy(f.streamQueue[i+1:], f.streamQueue[i:])
+	f.streamQueue[i] = id
+}
+
 func (f *framer) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCount) []wire.Frame {
 	var length protocol.ByteCount
+	var requeue []protocol.StreamID
 	f.streamQueueMutex.Lock()
 	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
 	numActiveStreams := len(f.streamQueue)
@@ -87,8 +137,8 @@ func (f *framer) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCou
 			continue
 		}
 		frame, hasMoreData := str.popStreamFrame(maxLen - length)
-		if hasMoreData { // put the stream back in the queue (at the end)
-			f.streamQueue = append(f.streamQueue, id)
+		if hasMoreData { // put the stream back in the queue, after this packet has been packed
+			requeue = append(requeue, id)
 		} else { // no more data to send. Stream is not active any more
 			delete(f.activeStreams, id)
 		}
@@ -98,6 +148,9 @@ func (f *framer) AppendStreamFrames(frames []wire.Frame, maxLen protocol.ByteCou
 		frames = append(frames, frame)
 		length += frame.Length(f.version)
 	}
+	for _, id := range requeue {
+		f.queueStream(id)
+	}
 	f.streamQueueMutex.Unlock()
 	return frames
 }
@@ -86,6 +86,17 @@ func (s *mockSession) ConnectionState() quic.ConnectionState        { panic("not
 func (s *mockSession) AcceptUniStream() (quic.ReceiveStream, error) { panic("not implemented") }
 func (s *mockSession) OpenUniStream() (quic.SendStream, error)      { panic("not implemented") }
 func (s *mockSession) OpenUniStreamSync() (quic.SendStream, error)  { panic("not implemented") }
+func (s *mockSession) UpdateKeys() error                            { panic("not implemented") }
+func (s *mockSession) SetConnectionDeadline(time.Time)              { panic("not implemented") }
+func (s *mockSession) SetStreamPriority(protocol.StreamID, uint8)   { panic("not implemented") }
+func (s *mockSession) PeerGoneAway() bool                           { panic("not implemented") }
+func (s *mockSession) CloseReason() (qerr.ErrorCode, string)        { panic("not implemented") }
+func (s *mockSession) RTTStats() quic.RTTStats                      { panic("not implemented") }
+func (s *mockSession) BytesInFlight() quic.ByteCount                { panic("not implemented") }
+func (s *mockSession) SendDatagram([]byte) error                    { panic("not implemented") }
+func (s *mockSession) ConnectionParameters() quic.ConnectionParameters {
+	panic("not implemented")
+}
 
 var _ = Describe("H2 server", func() {
 	var (
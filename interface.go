@@ -8,6 +8,7 @@ import (
 
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/qerr"
 )
 
 // The StreamID is the ID of a QUIC stream.
@@ -34,6 +35,102 @@ type ConnectionState = handshake.ConnectionState
 // An ErrorCode is an application-defined error code.
 type ErrorCode = protocol.ApplicationErrorCode
 
+// A ByteCount is a number of bytes.
+type ByteCount = protocol.ByteCount
+
+// A PacketNumber is a QUIC packet number.
+type PacketNumber = protocol.PacketNumber
+
+// An EncryptionLevel is the encryption level of the packet that data was received in.
+type EncryptionLevel = protocol.EncryptionLevel
+
+const (
+	// EncryptionUnspecified is returned if no data was received yet.
+	EncryptionUnspecified = protocol.EncryptionUnspecified
+	// EncryptionSecure is used for data received before the handshake completed, i.e. 0-RTT data.
+	EncryptionSecure = protocol.EncryptionSecure
+	// EncryptionForwardSecure is used for data received with the forward-secure keys.
+	EncryptionForwardSecure = protocol.EncryptionForwardSecure
+)
+
+// A CongestionControl decides if a packet may be sent.
+// It is informed about every packet that is sent, acknowledged or declared lost.
+// Packets sent using a CongestionControl are paced in bursts of a few packets.
+// Warning: This API should not be considered stable and might change soon.
+type CongestionControl interface {
+	// OnPacketSent is called for every packet that is sent.
+	OnPacketSent(sentTime time.Time, bytesInFlight ByteCount, packetNumber PacketNumber, bytes ByteCount, isRetransmittable bool)
+	// OnPacketAcked is called for every retransmittable packet that is acknowledged.
+	OnPacketAcked(number PacketNumber, ackedBytes ByteCount, priorInFlight ByteCount, eventTime time.Time)
+	// OnPacketLost is called for every retransmittable packet that is declared lost.
+	OnPacketLost(number PacketNumber, lostBytes ByteCount, priorInFlight ByteCount)
+	// CanSend says if a new packet may be sent.
+	CanSend(bytesInFlight ByteCount) bool
+}
+
+// ConnectionParameters are the transport parameters that the peer sent during the handshake.
+type ConnectionParameters struct {
+	// StreamFlowControlWindow is the initial stream-level flow control window for sending data.
+	StreamFlowControlWindow ByteCount
+	// ConnectionFlowControlWindow is the initial connection-level flow control window for sending data.
+	ConnectionFlowControlWindow ByteCount
+	// MaxPacketSize is the maximum size of packets that the peer accepts.
+	// It is 0 if the peer didn't send a limit.
+	MaxPacketSize ByteCount
+	// MaxStreams is the number of (bidirectional) streams that the peer allows to be opened.
+	MaxStreams int
+	// MaxUniStreams is the number of unidirectional streams that the peer allows to be opened.
+	// It is only used for IETF QUIC.
+	MaxUniStreams int
+	// IdleTimeout is the idle timeout that the peer sent.
+	IdleTimeout time.Duration
+	// OmitConnectionID says if the peer requested the omission of the connection ID.
+	// It is only used for gQUIC.
+	OmitConnectionID bool
+}
+
+// RTTStats are the round-trip time estimates of a connection.
+// All values are 0 until the first RTT sample was taken.
+type RTTStats struct {
+	// SmoothedRTT is the exponentially weighted moving average of the RTT samples.
+	SmoothedRTT time.Duration
+	// LatestRTT is the most recent RTT sample.
+	LatestRTT time.Duration
+	// MeanDeviation is the mean deviation of the RTT samples.
+	MeanDeviation time.Duration
+}
+
+// A Direction is the direction of a packet passed to the PacketSink.
+type Direction int
+
+const (
+	// DirectionSent is used for packets sent by this endpoint
+	DirectionSent Direction = iota + 1
+	// DirectionReceived is used for packets received from the peer
+	DirectionReceived
+)
+
+// A Tracer is informed about events in a session, e.g. to export metrics.
+// A new Tracer is created for every session, see Config.Tracer.
+// Most methods are called from the session's run loop and must not block.
+// OpenedStream can also be called from the go routine that opens a stream.
+// Warning: This API should not be considered stable and might change soon.
+type Tracer interface {
+	// SentPacket is called for every packet sent.
+	SentPacket(packetNumber PacketNumber, size ByteCount, encLevel EncryptionLevel)
+	// ReceivedPacket is called for every packet that was successfully decrypted.
+	ReceivedPacket(packetNumber PacketNumber, size ByteCount, encLevel EncryptionLevel)
+	// LostPacket is called when loss recovery declares a packet lost.
+	LostPacket(packetNumber PacketNumber, size ByteCount)
+	// OpenedStream is called for every stream opened, by us or by the peer.
+	OpenedStream(id StreamID)
+	// CompletedHandshake is called when the handshake completes.
+	CompletedHandshake()
+	// ClosedConnection is called when the session is closed.
+	// The error is nil if the session was closed by calling Close.
+	ClosedConnection(err error)
+}
+
 // Stream is the interface implemented by QUIC streams
 type Stream interface {
 	// StreamID returns the stream ID.
@@ -41,6 +138,8 @@ type Stream interface {
 	// Read reads data from the stream.
 	// Read can be made to time out and return a net.Error with Timeout() == true
 	// after a fixed time limit; see SetDeadline and SetReadDeadline.
+	// Data that was already received is returned before the timeout error,
+	// as well as when the session is closed.
 	// If the stream was canceled by the peer, the error implements the StreamError
 	// interface, and Canceled() == true.
 	io.Reader
@@ -55,6 +154,11 @@ type Stream interface {
 	// It must not be called concurrently with Write.
 	// It must not be called after calling CancelWrite.
 	io.Closer
+	// Flush sends the data written to the stream without waiting for the WriteCoalescingDelay.
+	// It blocks until that data has been packed and written to the connection at least once,
+	// which doesn't mean that it was acknowledged by the peer.
+	// Small writes are buffered while the WriteCoalescingDelay is used, so Flush can be called after these writes returned.
+	Flush() error
 	// CancelWrite aborts sending on this stream.
 	// It must not be called after Close.
 	// Data already written, but not yet delivered to the peer is not guaranteed to be delivered reliably.
@@ -82,6 +186,11 @@ type Stream interface {
 	// with the connection. It is equivalent to calling both
 	// SetReadDeadline and SetWriteDeadline.
 	SetDeadline(t time.Time) error
+	// EncryptionLevel returns the lowest encryption level of the data received on this stream so far.
+	// It is EncryptionSecure if any data arrived before the handshake completed (0-RTT data),
+	// which the application might not want to act on, since it can be replayed.
+	// Warning: This API should not be considered stable and might change soon.
+	EncryptionLevel() EncryptionLevel
 }
 
 // A ReceiveStream is a unidirectional Receive Stream.
@@ -94,6 +203,8 @@ type ReceiveStream interface {
 	CancelRead(ErrorCode) error
 	// see Stream.SetReadDealine
 	SetReadDeadline(t time.Time) error
+	// see Stream.EncryptionLevel
+	EncryptionLevel() EncryptionLevel
 }
 
 // A SendStream is a unidirectional Send Stream.
@@ -104,6 +215,8 @@ type SendStream interface {
 	io.Writer
 	// see Stream.Close
 	io.Closer
+	// see Stream.Flush
+	Flush() error
 	// see Stream.CancelWrite
 	CancelWrite(ErrorCode) error
 	// see Stream.Context
@@ -156,6 +269,44 @@ type Session interface {
 	// ConnectionState returns basic details about the QUIC connection.
 	// Warning: This API should not be considered stable and might change soon.
 	ConnectionState() ConnectionState
+	// ConnectionParameters returns the transport parameters that the peer sent during the handshake.
+	// Before they are received, all values are zero.
+	// Warning: This API should not be considered stable and might change soon.
+	ConnectionParameters() ConnectionParameters
+	// RTTStats returns the current round-trip time estimates.
+	// They are updated every time an ACK is received.
+	// Warning: This API should not be considered stable and might change soon.
+	RTTStats() RTTStats
+	// BytesInFlight returns the number of bytes sent in retransmittable packets that have not yet been acknowledged.
+	// Warning: This API should not be considered stable and might change soon.
+	BytesInFlight() ByteCount
+	// SendDatagram sends the data in a DATAGRAM frame, in a packet of its own.
+	// Datagrams are neither retransmitted nor ordered, and are dropped if the session is closed before they are sent.
+	// An error is returned if the data doesn't fit into a single packet,
+	// if Config.EnableDatagrams is not set, or if the peer didn't announce support for DATAGRAM frames.
+	// Datagrams sent before the handshake completes are dropped if the peer turns out not to support them.
+	// Warning: This API should not be considered stable and might change soon.
+	SendDatagram([]byte) error
+	// SetConnectionDeadline sets a deadline for the whole connection.
+	// When the deadline passes, the connection is closed, regardless of any activity.
+	// This is independent of the idle timeout.
+	// A zero value for t removes the deadline.
+	SetConnectionDeadline(t time.Time)
+	// PeerGoneAway returns whether the peer sent a GOAWAY frame.
+	// Opening new streams fails afterwards. Streams that the peer won't process anymore are closed,
+	// all other streams that are already open can still be used.
+	PeerGoneAway() bool
+	// CloseReason returns the error code and the reason phrase that the peer sent when closing the connection.
+	// If the connection wasn't closed by the peer, it returns 0 and an empty reason phrase.
+	CloseReason() (code qerr.ErrorCode, reason string)
+	// UpdateKeys initiates a 1-RTT key update.
+	// It is only supported for IETF QUIC, after the handshake completed.
+	// Warning: This API should not be considered stable and might change soon.
+	UpdateKeys() error
+	// SetStreamPriority sets the priority of a stream.
+	// When packing packets, data of streams with a higher priority is sent first.
+	// Streams with the same priority share the bandwidth. The default priority is 0.
+	SetStreamPriority(id StreamID, priority uint8)
 }
 
 // Config contains all configuration data needed for a QUIC server or client.
@@ -208,6 +359,73 @@ type Config struct {
 	MaxIncomingUniStreams int
 	// KeepAlive defines whether this peer will periodically send PING frames to keep the connection alive.
 	KeepAlive bool
+	// IdleTimeoutRetransmittableOnly defines whether only packets containing retransmittable frames
+	// (e.g. STREAM frames, but not ACK frames) reset the idle timeout.
+	// This prevents a peer from keeping the connection alive by just sending ACKs.
+	// Note that the ACKs sent in response to a KeepAlive PING then don't keep the connection alive either.
+	// If not set, every received packet resets the idle timeout.
+	IdleTimeoutRetransmittableOnly bool
+	// PublicResetOnCryptoError makes a server send a PUBLIC_RESET instead of a CONNECTION_CLOSE
+	// when processing the data on the crypto stream fails (e.g. when a client doesn't send a CHLO).
+	// This gives peers that don't speak QUIC, like port scanners, an unambiguous signal.
+	// It only applies to gQUIC.
+	PublicResetOnCryptoError bool
+	// MaxAckDelay is the maximum time that the acknowledgement of a retransmittable packet is delayed.
+	// Delaying ACKs allows acknowledging multiple packets with a single ACK frame.
+	// Packets that arrive out of order are acknowledged immediately.
+	// If not set, it defaults to 25ms.
+	MaxAckDelay time.Duration
+	// WriteCoalescingDelay is the time that stream data is held back after a Write,
+	// so that small writes on multiple streams can be sent in a single packet.
+	// Small writes return as soon as the data was buffered, so that subsequent writes on the same stream can be coalesced as well.
+	// Stream.Flush sends the data immediately.
+	// If not set, stream data is sent as soon as possible.
+	WriteCoalescingDelay time.Duration
+	// MaxPacketSize is the maximum size of packets sent, in bytes.
+	// It must be between 1200 and 1452 bytes.
+	// If not set, it depends on the remote address: 1252 bytes for IPv4, and 1232 bytes for IPv6.
+	MaxPacketSize ByteCount
+	// PacketNumberLength forces the length of the packet number in the header of every packet sent, in bytes.
+	// The only exception are the long header packets of gQUIC 44, which always use a 4 byte packet number.
+	// It must be 1, 2 or 4. 6 byte packet numbers are not supported.
+	// Short packet numbers can't be decoded by the peer if too many packets are in flight.
+	// This option is intended for interoperability testing.
+	// If not set, the length is chosen depending on the number of packets in flight.
+	PacketNumberLength int
+	// MaxBytesInFlight is the maximum number of bytes in retransmittable packets that have not yet been acknowledged.
+	// When it is reached, no new data is sent until an ACK is received, while retransmissions and ACKs are still sent.
+	// If not set, the number of bytes in flight is only limited by the congestion controller.
+	MaxBytesInFlight ByteCount
+	// CongestionControl creates the congestion controller for a new session.
+	// It is called once for every session.
+	// If not set, CUBIC is used.
+	// Warning: This API should not be considered stable and might change soon.
+	CongestionControl func() CongestionControl
+	// OnConnectionWindowUpdate is called when the peer increases the connection-level flow control window,
+	// i.e. when a MAX_DATA frame (or a gQUIC WINDOW_UPDATE frame for stream 0) is received.
+	// It is called from the session's run loop and must not block.
+	OnConnectionWindowUpdate func(offset ByteCount)
+	// OnStreamClosed is called when both directions of a stream are done.
+	// The error is nil if the stream was closed cleanly, i.e. all data was sent and received.
+	// If the stream was reset by either peer, it is the cancelation error.
+	// If the stream was still open when the session was closed, it is the error the session was closed with.
+	// It may be called from different go routines and must not block.
+	OnStreamClosed func(id StreamID, err error)
+	// EnableDatagrams announces support for DATAGRAM frames during the handshake.
+	// Datagrams can only be sent and received if both peers enable them.
+	EnableDatagrams bool
+	// OnDatagram is called with the data of every DATAGRAM frame received.
+	// If not set, received DATAGRAM frames are dropped.
+	// It is called from the session's run loop and must not block. The data must not be retained after the call returns.
+	OnDatagram func(data []byte)
+	// PacketSink is called with every packet sent and received by a session, e.g. to write a packet capture for debugging.
+	// Received packets are passed before they are decrypted. The packet must not be retained after the call returns.
+	// It is called from the session's run loop and must not block.
+	PacketSink func(direction Direction, packet []byte)
+	// Tracer creates the Tracer for a new session.
+	// It is called once for every session.
+	// If not set, no events are traced.
+	Tracer func() Tracer
 }
 
 // A Listener for incoming QUIC connections
@@ -219,3 +437,12 @@ type Listener interface {
 	// Accept returns new sessions. It should be called in a loop.
 	Accept() (Session, error)
 }
+
+// A ServerConfigRotator rotates the gQUIC server config.
+// The Listener returned by Listen and ListenAddr implements this interface.
+type ServerConfigRotator interface {
+	// RotateServerConfig generates a new gQUIC server config, which is sent to clients from now on.
+	// Clients that cached one of the previous server configs can still use it for a 0-RTT handshake,
+	// until it is dropped after a few more rotations.
+	RotateServerConfig() error
+}
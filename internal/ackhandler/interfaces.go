@@ -32,6 +32,8 @@ type SentPacketHandler interface {
 	DequeuePacketForRetransmission() *Packet
 	DequeueProbePacket() (*Packet, error)
 	GetPacketNumberLen(protocol.PacketNumber) protocol.PacketNumberLen
+	// GetBytesInFlight returns the number of bytes sent in retransmittable packets that are not yet acknowledged or declared lost.
+	GetBytesInFlight() protocol.ByteCount
 
 	GetAlarmTimeout() time.Time
 	OnAlarm() error
@@ -40,6 +42,9 @@ type SentPacketHandler interface {
 // ReceivedPacketHandler handles ACKs needed to send for incoming packets
 type ReceivedPacketHandler interface {
 	ReceivedPacket(packetNumber protocol.PacketNumber, rcvTime time.Time, shouldInstigateAck bool) error
+	// QueueAck makes sure that an ACK is sent right away, instead of waiting for the ACK timer.
+	// It must be called after ReceivedPacket.
+	QueueAck()
 	IgnoreBelow(protocol.PacketNumber)
 
 	GetAlarmTimeout() time.Time
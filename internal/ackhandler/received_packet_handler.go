@@ -25,14 +25,15 @@ type receivedPacketHandler struct {
 	ackAlarm                                   time.Time
 	lastAck                                    *wire.AckFrame
 
+	clock  utils.Clock
 	logger utils.Logger
 
 	version protocol.VersionNumber
 }
 
 const (
-	// maximum delay that can be applied to an ACK for a retransmittable packet
-	ackSendDelay = 25 * time.Millisecond
+	// default maximum delay that can be applied to an ACK for a retransmittable packet
+	defaultAckSendDelay = 25 * time.Millisecond
 	// initial maximum number of retransmittable packets received before sending an ack.
 	initialRetransmittablePacketsBeforeAck = 2
 	// number of retransmittable that an ACK is sent for
@@ -54,15 +55,23 @@ const (
 )
 
 // NewReceivedPacketHandler creates a new receivedPacketHandler
+// ackSendDelay is the maximum delay that can be applied to an ACK for a retransmittable packet.
+// If it is 0, a default delay of 25ms is used.
 func NewReceivedPacketHandler(
 	rttStats *congestion.RTTStats,
+	ackSendDelay time.Duration,
+	clock utils.Clock,
 	logger utils.Logger,
 	version protocol.VersionNumber,
 ) ReceivedPacketHandler {
+	if ackSendDelay <= 0 {
+		ackSendDelay = defaultAckSendDelay
+	}
 	return &receivedPacketHandler{
 		packetHistory: newReceivedPacketHistory(),
 		ackSendDelay:  ackSendDelay,
 		rttStats:      rttStats,
+		clock:         clock,
 		logger:        logger,
 		version:       version,
 	}
@@ -86,6 +95,15 @@ func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumbe
 	return nil
 }
 
+func (h *receivedPacketHandler) QueueAck() {
+	if h.ackQueued {
+		return
+	}
+	h.logger.Debugf("\tQueueing ACK because an immediate ACK was requested.")
+	h.ackQueued = true
+	h.ackAlarm = time.Time{}
+}
+
 // IgnoreBelow sets a lower limit for acking packets.
 // Packets with packet numbers smaller than p will not be acked.
 func (h *receivedPacketHandler) IgnoreBelow(p protocol.PacketNumber) {
@@ -150,10 +168,10 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 				}
 			} else if h.ackAlarm.IsZero() {
 				// wait for the minimum of the ack decimation delay or the delayed ack time before sending an ack
-				ackDelay := utils.MinDuration(ackSendDelay, time.Duration(float64(h.rttStats.MinRTT())*float64(ackDecimationDelay)))
+				ackDelay := utils.MinDuration(h.ackSendDelay, time.Duration(float64(h.rttStats.MinRTT())*float64(ackDecimationDelay)))
 				h.ackAlarm = rcvTime.Add(ackDelay)
 				if h.logger.Debug() {
-					h.logger.Debugf("\tSetting ACK timer to min(1/4 min-RTT, max ack delay): %s (%s from now)", ackDelay, time.Until(h.ackAlarm))
+					h.logger.Debugf("\tSetting ACK timer to min(1/4 min-RTT, max ack delay): %s (%s from now)", ackDelay, h.ackAlarm.Sub(h.clock.Now()))
 				}
 			}
 		} else {
@@ -165,9 +183,9 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 				h.ackQueued = true
 			} else if h.ackAlarm.IsZero() {
 				if h.logger.Debug() {
-					h.logger.Debugf("\tSetting ACK timer to max ack delay: %s", ackSendDelay)
+					h.logger.Debugf("\tSetting ACK timer to max ack delay: %s", h.ackSendDelay)
 				}
-				h.ackAlarm = rcvTime.Add(ackSendDelay)
+				h.ackAlarm = rcvTime.Add(h.ackSendDelay)
 			}
 		}
 		// If there are new missing packets to report, set a short timer to send an ACK.
@@ -178,7 +196,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 			if h.ackAlarm.IsZero() || h.ackAlarm.After(ackTime) {
 				h.ackAlarm = ackTime
 				if h.logger.Debug() {
-					h.logger.Debugf("\tSetting ACK timer to 1/8 min-RTT: %s (%s from now)", ackDelay, time.Until(h.ackAlarm))
+					h.logger.Debugf("\tSetting ACK timer to 1/8 min-RTT: %s (%s from now)", ackDelay, h.ackAlarm.Sub(h.clock.Now()))
 				}
 			}
 		}
@@ -191,7 +209,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 }
 
 func (h *receivedPacketHandler) GetAckFrame() *wire.AckFrame {
-	now := time.Now()
+	now := h.clock.Now()
 	if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(now)) {
 		return nil
 	}
@@ -12,6 +12,20 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type mockClock time.Time
+
+func (c *mockClock) Now() time.Time {
+	return time.Time(*c)
+}
+
+func (c *mockClock) NewTimer(d time.Duration) utils.ClockTimer {
+	return utils.DefaultClock{}.NewTimer(d)
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	*c = mockClock(time.Time(*c).Add(d))
+}
+
 var _ = Describe("receivedPacketHandler", func() {
 	var (
 		handler  *receivedPacketHandler
@@ -20,7 +34,7 @@ var _ = Describe("receivedPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats = &congestion.RTTStats{}
-		handler = NewReceivedPacketHandler(rttStats, utils.DefaultLogger, protocol.VersionWhatever).(*receivedPacketHandler)
+		handler = NewReceivedPacketHandler(rttStats, 0, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*receivedPacketHandler)
 	})
 
 	Context("accepting packets", func() {
@@ -151,7 +165,66 @@ var _ = Describe("receivedPacketHandler", func() {
 				err = handler.ReceivedPacket(12, rcvTime, true)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.ackQueued).To(BeFalse())
-				Expect(handler.GetAlarmTimeout()).To(Equal(rcvTime.Add(ackSendDelay)))
+				Expect(handler.GetAlarmTimeout()).To(Equal(rcvTime.Add(defaultAckSendDelay)))
+			})
+
+			It("queues an ACK when requested, instead of setting the timer", func() {
+				receiveAndAck10Packets()
+				err := handler.ReceivedPacket(11, time.Now(), true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler.ackQueued).To(BeFalse())
+				Expect(handler.GetAlarmTimeout()).ToNot(BeZero())
+				handler.QueueAck()
+				Expect(handler.ackQueued).To(BeTrue())
+				Expect(handler.GetAlarmTimeout()).To(BeZero())
+				ack := handler.GetAckFrame()
+				Expect(ack).ToNot(BeNil())
+				Expect(ack.LargestAcked()).To(Equal(protocol.PacketNumber(11)))
+			})
+
+			It("uses the configured max ACK delay", func() {
+				handler = NewReceivedPacketHandler(rttStats, 100*time.Millisecond, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*receivedPacketHandler)
+				receiveAndAck10Packets()
+				rcvTime := time.Now().Add(10 * time.Millisecond)
+				err := handler.ReceivedPacket(11, rcvTime, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler.ackQueued).To(BeFalse())
+				Expect(handler.GetAlarmTimeout()).To(Equal(rcvTime.Add(100 * time.Millisecond)))
+			})
+
+			It("uses the clock to decide when the ACK alarm expires", func() {
+				clock := mockClock(time.Now())
+				handler = NewReceivedPacketHandler(rttStats, 0, &clock, utils.DefaultLogger, protocol.VersionWhatever).(*receivedPacketHandler)
+				receiveAndAck10Packets()
+				err := handler.ReceivedPacket(11, clock.Now(), true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler.GetAlarmTimeout()).To(Equal(clock.Now().Add(defaultAckSendDelay)))
+				clock.Advance(defaultAckSendDelay - time.Nanosecond)
+				Expect(handler.GetAckFrame()).To(BeNil())
+				clock.Advance(time.Nanosecond)
+				ack := handler.GetAckFrame()
+				Expect(ack).ToNot(BeNil())
+				Expect(ack.LargestAcked()).To(Equal(protocol.PacketNumber(11)))
+				Expect(ack.DelayTime).To(Equal(defaultAckSendDelay))
+			})
+
+			It("acknowledges multiple in-order packets with a single ACK", func() {
+				now := time.Now().Add(-time.Hour)
+				rttStats.UpdateRTT(time.Second, 0, now)
+				handler = NewReceivedPacketHandler(rttStats, 50*time.Millisecond, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*receivedPacketHandler)
+				receiveAndAckPacketsUntilAckDecimation()
+				p := protocol.PacketNumber(minReceivedBeforeAckDecimation + 1)
+				for i := p; i < p+5; i++ {
+					err := handler.ReceivedPacket(i, now, true)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(handler.ackQueued).To(BeFalse())
+				}
+				Expect(handler.GetAlarmTimeout()).To(Equal(now.Add(50 * time.Millisecond)))
+				ack := handler.GetAckFrame()
+				Expect(ack).ToNot(BeNil())
+				Expect(ack.LargestAcked()).To(Equal(p + 4))
+				Expect(ack.HasMissingRanges()).To(BeFalse())
+				Expect(handler.GetAckFrame()).To(BeNil())
 			})
 
 			It("queues an ACK if it was reported missing before", func() {
@@ -20,6 +20,13 @@ func IsFrameRetransmittable(f wire.Frame) bool {
 		return false
 	case *wire.AckFrame:
 		return false
+	case *wire.DatagramFrame:
+		return false
+	// A lost PATH_CHALLENGE is replaced by a new one with new data, and a PATH_RESPONSE answers one specific PATH_CHALLENGE.
+	case *wire.PathChallengeFrame:
+		return false
+	case *wire.PathResponseFrame:
+		return false
 	default:
 		return true
 	}
@@ -12,6 +12,9 @@ var _ = Describe("retransmittable frames", func() {
 	for fl, el := range map[wire.Frame]bool{
 		&wire.AckFrame{}:             false,
 		&wire.StopWaitingFrame{}:     false,
+		&wire.DatagramFrame{}:        false,
+		&wire.PathChallengeFrame{}:   false,
+		&wire.PathResponseFrame{}:    false,
 		&wire.BlockedFrame{}:         true,
 		&wire.ConnectionCloseFrame{}: true,
 		&wire.GoawayFrame{}:          true,
@@ -74,26 +74,46 @@ type sentPacketHandler struct {
 	// The alarm timeout
 	alarm time.Time
 
+	// called for every packet that is declared lost, may be nil
+	onPacketLost func(protocol.PacketNumber, protocol.ByteCount)
+
+	clock  utils.Clock
 	logger utils.Logger
 
 	version protocol.VersionNumber
 }
 
 // NewSentPacketHandler creates a new sentPacketHandler
-func NewSentPacketHandler(rttStats *congestion.RTTStats, logger utils.Logger, version protocol.VersionNumber) SentPacketHandler {
-	congestion := congestion.NewCubicSender(
-		congestion.DefaultClock{},
-		rttStats,
-		false, /* don't use reno since chromium doesn't (why?) */
-		protocol.InitialCongestionWindow,
-		protocol.DefaultMaxCongestionWindow,
-	)
+// If congestionControl is nil, CUBIC is used.
+// If onPacketLost is set, it is called for every packet that is declared lost.
+func NewSentPacketHandler(
+	rttStats *congestion.RTTStats,
+	congestionControl congestion.CongestionControl,
+	onPacketLost func(protocol.PacketNumber, protocol.ByteCount),
+	clock utils.Clock,
+	logger utils.Logger,
+	version protocol.VersionNumber,
+) SentPacketHandler {
+	var sendAlgorithm congestion.SendAlgorithm
+	if congestionControl == nil {
+		sendAlgorithm = congestion.NewCubicSender(
+			clock,
+			rttStats,
+			false, /* don't use reno since chromium doesn't (why?) */
+			protocol.InitialCongestionWindow,
+			protocol.DefaultMaxCongestionWindow,
+		)
+	} else {
+		sendAlgorithm = congestion.NewSendAlgorithm(congestionControl)
+	}
 
 	return &sentPacketHandler{
 		packetHistory:      newSentPacketHistory(),
 		stopWaitingManager: stopWaitingManager{},
 		rttStats:           rttStats,
-		congestion:         congestion,
+		congestion:         sendAlgorithm,
+		onPacketLost:       onPacketLost,
+		clock:              clock,
 		logger:             logger,
 		version:            version,
 	}
@@ -358,6 +378,9 @@ func (h *sentPacketHandler) detectLostPackets(now time.Time, priorInFlight proto
 	}
 
 	for _, p := range lostPackets {
+		if h.onPacketLost != nil {
+			h.onPacketLost(p.PacketNumber, p.Length)
+		}
 		// the bytes in flight need to be reduced no matter if this packet will be retransmitted
 		if p.includedInBytesInFlight {
 			h.bytesInFlight -= p.Length
@@ -401,7 +424,7 @@ func (h *sentPacketHandler) onVerifiedAlarm() error {
 			h.logger.Debugf("Loss detection alarm fired in loss timer mode. Loss time: %s", h.lossTime)
 		}
 		// Early retransmit or time loss detection
-		err = h.detectLostPackets(time.Now(), h.bytesInFlight)
+		err = h.detectLostPackets(h.clock.Now(), h.bytesInFlight)
 	} else if h.tlpCount < maxTLPs { // TLP
 		if h.logger.Debug() {
 			h.logger.Debugf("Loss detection alarm fired in TLP mode. TLP count: %d", h.tlpCount)
@@ -526,6 +549,10 @@ func (h *sentPacketHandler) GetPacketNumberLen(p protocol.PacketNumber) protocol
 	return protocol.GetPacketNumberLengthForHeader(p, h.lowestUnacked(), h.version)
 }
 
+func (h *sentPacketHandler) GetBytesInFlight() protocol.ByteCount {
+	return h.bytesInFlight
+}
+
 func (h *sentPacketHandler) GetStopWaitingFrame(force bool) *wire.StopWaitingFrame {
 	return h.stopWaitingManager.GetStopWaitingFrame(force)
 }
@@ -550,9 +577,9 @@ func (h *sentPacketHandler) SendMode() SendMode {
 		return SendRTO
 	}
 	// Only send ACKs if we're congestion limited.
-	if cwnd := h.congestion.GetCongestionWindow(); h.bytesInFlight > cwnd {
+	if !h.congestion.CanSend(h.bytesInFlight) {
 		if h.logger.Debug() {
-			h.logger.Debugf("Congestion limited: bytes in flight %d, window %d", h.bytesInFlight, cwnd)
+			h.logger.Debugf("Congestion limited: bytes in flight %d", h.bytesInFlight)
 		}
 		return SendAck
 	}
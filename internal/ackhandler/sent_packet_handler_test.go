@@ -49,7 +49,7 @@ var _ = Describe("SentPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
-		handler = NewSentPacketHandler(rttStats, utils.DefaultLogger, protocol.VersionWhatever).(*sentPacketHandler)
+		handler = NewSentPacketHandler(rttStats, nil, nil, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*sentPacketHandler)
 		handler.SetHandshakeComplete()
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
@@ -135,6 +135,16 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.bytesInFlight).To(BeZero())
 		})
 
+		It("returns the bytes in flight", func() {
+			handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 1, Length: 100}))
+			handler.SentPacket(nonRetransmittablePacket(&Packet{PacketNumber: 2, Length: 10}))
+			handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 3, Length: 200}))
+			Expect(handler.GetBytesInFlight()).To(Equal(protocol.ByteCount(300)))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, time.Now())).To(Succeed())
+			Expect(handler.GetBytesInFlight()).To(Equal(protocol.ByteCount(200)))
+		})
+
 		Context("skipped packet numbers", func() {
 			It("works with non-consecutive packet numbers", func() {
 				handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 1}))
@@ -542,6 +552,29 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("custom congestion control", func() {
+		It("uses CUBIC by default", func() {
+			Expect(handler.congestion.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow))
+		})
+
+		It("uses the congestion controller it was created with", func() {
+			handler = NewSentPacketHandler(&congestion.RTTStats{}, congestion.NewUnlimitedCongestionControl(), nil, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*sentPacketHandler)
+			handler.bytesInFlight = protocol.MaxByteCount
+			Expect(handler.SendMode()).To(Equal(SendAny))
+		})
+
+		It("sends multiple packets at once when using a congestion controller without pacing", func() {
+			handler = NewSentPacketHandler(&congestion.RTTStats{}, congestion.NewUnlimitedCongestionControl(), nil, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*sentPacketHandler)
+			Expect(handler.ShouldSendNumPackets()).To(Equal(10))
+		})
+
+		It("uses a SendAlgorithm directly", func() {
+			cong := mocks.NewMockSendAlgorithm(mockCtrl)
+			handler = NewSentPacketHandler(&congestion.RTTStats{}, cong, nil, utils.DefaultClock{}, utils.DefaultLogger, protocol.VersionWhatever).(*sentPacketHandler)
+			Expect(handler.congestion).To(Equal(cong))
+		})
+	})
+
 	Context("congestion", func() {
 		var cong *mocks.MockSendAlgorithm
 
@@ -694,14 +727,14 @@ var _ = Describe("SentPacketHandler", func() {
 
 		It("only allows sending of ACKs when congestion limited", func() {
 			handler.bytesInFlight = 100
-			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(200))
+			cong.EXPECT().CanSend(protocol.ByteCount(100)).Return(true)
 			Expect(handler.SendMode()).To(Equal(SendAny))
-			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(75))
+			cong.EXPECT().CanSend(protocol.ByteCount(100)).Return(false)
 			Expect(handler.SendMode()).To(Equal(SendAck))
 		})
 
 		It("only allows sending of ACKs when we're keeping track of MaxOutstandingSentPackets packets", func() {
-			cong.EXPECT().GetCongestionWindow().Return(protocol.MaxByteCount).AnyTimes()
+			cong.EXPECT().CanSend(gomock.Any()).Return(true).AnyTimes()
 			cong.EXPECT().TimeUntilSend(gomock.Any()).AnyTimes()
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 			for i := protocol.PacketNumber(1); i < protocol.MaxOutstandingSentPackets; i++ {
@@ -715,18 +748,18 @@ var _ = Describe("SentPacketHandler", func() {
 		It("doesn't allow retransmission if congestion limited", func() {
 			handler.bytesInFlight = 100
 			handler.retransmissionQueue = []*Packet{{PacketNumber: 3}}
-			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(50))
+			cong.EXPECT().CanSend(protocol.ByteCount(100)).Return(false)
 			Expect(handler.SendMode()).To(Equal(SendAck))
 		})
 
 		It("allows sending retransmissions", func() {
-			cong.EXPECT().GetCongestionWindow().Return(protocol.MaxByteCount)
+			cong.EXPECT().CanSend(gomock.Any()).Return(true)
 			handler.retransmissionQueue = []*Packet{{PacketNumber: 3}}
 			Expect(handler.SendMode()).To(Equal(SendRetransmission))
 		})
 
 		It("allow retransmissions, if we're keeping track of between MaxOutstandingSentPackets and MaxTrackedSentPackets packets", func() {
-			cong.EXPECT().GetCongestionWindow().Return(protocol.MaxByteCount)
+			cong.EXPECT().CanSend(gomock.Any()).Return(true)
 			Expect(protocol.MaxOutstandingSentPackets).To(BeNumerically("<", protocol.MaxTrackedSentPackets))
 			handler.retransmissionQueue = make([]*Packet, protocol.MaxOutstandingSentPackets+10)
 			Expect(handler.SendMode()).To(Equal(SendRetransmission))
@@ -735,7 +768,7 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 
 		It("allows RTOs, even when congestion limited", func() {
-			// note that we don't EXPECT a call to GetCongestionWindow
+			// note that we don't EXPECT a call to CanSend
 			// that means retransmissions are sent without considering the congestion window
 			handler.numRTOs = 1
 			handler.retransmissionQueue = []*Packet{{PacketNumber: 3}}
@@ -1017,6 +1050,23 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.bytesInFlight).To(BeZero())
 		})
 
+		It("reports lost packets to the callback", func() {
+			type lostPacket struct {
+				pn   protocol.PacketNumber
+				size protocol.ByteCount
+			}
+			var lost []lostPacket
+			handler.onPacketLost = func(pn protocol.PacketNumber, size protocol.ByteCount) {
+				lost = append(lost, lostPacket{pn: pn, size: size})
+			}
+			now := time.Now()
+			handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 1, Length: 1000, SendTime: now.Add(-time.Hour)}))
+			handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 2, Length: 1000, SendTime: now.Add(-time.Second)}))
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, now)).To(Succeed())
+			Expect(lost).To(Equal([]lostPacket{{pn: 1, size: 1000}}))
+		})
+
 		It("sets the early retransmit alarm", func() {
 			now := time.Now()
 			handler.SentPacket(retransmittablePacket(&Packet{PacketNumber: 1, SendTime: now.Add(-2 * time.Second)}))
@@ -0,0 +1,54 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// unpacedBurstSize is the number of packets that are sent in one burst when using a CongestionControl.
+const unpacedBurstSize = 10
+
+// NewSendAlgorithm turns a CongestionControl into a SendAlgorithm.
+// If cc already is a SendAlgorithm, it is returned unchanged.
+// Otherwise, packets are sent in bursts of unpacedBurstSize packets every protocol.MinPacingDelay,
+// as long as cc allows sending. All callbacks that are not part of the CongestionControl are ignored.
+func NewSendAlgorithm(cc CongestionControl) SendAlgorithm {
+	if sa, ok := cc.(SendAlgorithm); ok {
+		return sa
+	}
+	return &congestionControlSender{CongestionControl: cc}
+}
+
+type congestionControlSender struct {
+	CongestionControl
+}
+
+var _ SendAlgorithm = &congestionControlSender{}
+
+func (s *congestionControlSender) TimeUntilSend(protocol.ByteCount) time.Duration {
+	return protocol.MinPacingDelay / unpacedBurstSize
+}
+func (s *congestionControlSender) GetCongestionWindow() protocol.ByteCount {
+	return protocol.MaxByteCount
+}
+func (s *congestionControlSender) MaybeExitSlowStart()             {}
+func (s *congestionControlSender) SetNumEmulatedConnections(int)   {}
+func (s *congestionControlSender) OnRetransmissionTimeout(bool)    {}
+func (s *congestionControlSender) OnConnectionMigration()          {}
+func (s *congestionControlSender) SetSlowStartLargeReduction(bool) {}
+
+// NewUnlimitedCongestionControl creates a CongestionControl that always allows sending
+func NewUnlimitedCongestionControl() CongestionControl {
+	return &unlimitedCongestionControl{}
+}
+
+type unlimitedCongestionControl struct{}
+
+func (unlimitedCongestionControl) OnPacketSent(time.Time, protocol.ByteCount, protocol.PacketNumber, protocol.ByteCount, bool) {
+}
+func (unlimitedCongestionControl) OnPacketAcked(protocol.PacketNumber, protocol.ByteCount, protocol.ByteCount, time.Time) {
+}
+func (unlimitedCongestionControl) OnPacketLost(protocol.PacketNumber, protocol.ByteCount, protocol.ByteCount) {
+}
+func (unlimitedCongestionControl) CanSend(protocol.ByteCount) bool { return true }
@@ -0,0 +1,43 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type blockingCongestionControl struct {
+	unlimitedCongestionControl
+	sent int
+}
+
+func (c *blockingCongestionControl) OnPacketSent(time.Time, protocol.ByteCount, protocol.PacketNumber, protocol.ByteCount, bool) {
+	c.sent++
+}
+func (c *blockingCongestionControl) CanSend(protocol.ByteCount) bool { return false }
+
+var _ = Describe("Congestion Control", func() {
+	It("returns a SendAlgorithm unchanged", func() {
+		sender := NewCubicSender(utils.DefaultClock{}, NewRTTStats(), false, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+		Expect(NewSendAlgorithm(sender)).To(Equal(sender))
+	})
+
+	It("wraps a CongestionControl", func() {
+		cc := &blockingCongestionControl{}
+		sender := NewSendAlgorithm(cc)
+		sender.OnPacketSent(time.Now(), 0, 1, 1000, true)
+		Expect(cc.sent).To(Equal(1))
+		Expect(sender.CanSend(0)).To(BeFalse())
+		Expect(sender.TimeUntilSend(0)).To(Equal(protocol.MinPacingDelay / unpacedBurstSize))
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.MaxByteCount))
+	})
+
+	It("never limits sending with the unlimited congestion controller", func() {
+		cc := NewUnlimitedCongestionControl()
+		Expect(cc.CanSend(0)).To(BeTrue())
+		Expect(cc.CanSend(protocol.MaxByteCount)).To(BeTrue())
+	})
+})
@@ -66,7 +66,7 @@ var _ SendAlgorithm = &cubicSender{}
 var _ SendAlgorithmWithDebugInfo = &cubicSender{}
 
 // NewCubicSender makes a new cubic sender
-func NewCubicSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.ByteCount) SendAlgorithmWithDebugInfo {
+func NewCubicSender(clock utils.Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.ByteCount) SendAlgorithmWithDebugInfo {
 	return &cubicSender{
 		rttStats:                   rttStats,
 		initialCongestionWindow:    initialCongestionWindow,
@@ -118,6 +118,10 @@ func (c *cubicSender) InSlowStart() bool {
 	return c.GetCongestionWindow() < c.GetSlowStartThreshold()
 }
 
+func (c *cubicSender) CanSend(bytesInFlight protocol.ByteCount) bool {
+	return bytesInFlight <= c.GetCongestionWindow()
+}
+
 func (c *cubicSender) GetCongestionWindow() protocol.ByteCount {
 	return c.congestionWindow
 }
@@ -18,6 +18,10 @@ func (c *mockClock) Now() time.Time {
 	return time.Time(*c)
 }
 
+func (c *mockClock) NewTimer(d time.Duration) utils.ClockTimer {
+	return utils.DefaultClock{}.NewTimer(d)
+}
+
 func (c *mockClock) Advance(d time.Duration) {
 	*c = mockClock(time.Time(*c).Add(d))
 }
@@ -101,6 +105,13 @@ var _ = Describe("Cubic Sender", func() {
 		Expect(canSend()).To(BeFalse())
 	})
 
+	It("allows sending as long as the congestion window is not exceeded", func() {
+		cwnd := sender.GetCongestionWindow()
+		Expect(sender.CanSend(0)).To(BeTrue())
+		Expect(sender.CanSend(cwnd)).To(BeTrue())
+		Expect(sender.CanSend(cwnd + 1)).To(BeFalse())
+	})
+
 	It("paces", func() {
 		clock.Advance(time.Hour)
 		// Fill the send window with data, then verify that we can't send.
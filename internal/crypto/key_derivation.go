@@ -8,6 +8,8 @@ import (
 const (
 	clientExporterLabel = "EXPORTER-QUIC client 1rtt"
 	serverExporterLabel = "EXPORTER-QUIC server 1rtt"
+
+	keyUpdateLabel = "key update"
 )
 
 // A TLSExporter gets the negotiated ciphersuite and computes exporter
@@ -16,7 +18,27 @@ type TLSExporter interface {
 	ComputeExporter(label string, context []byte, keyLength int) ([]byte, error)
 }
 
+// An UpdatableAEAD is an AEAD used for 1-RTT packets.
+// It can derive the AEAD that is used after a key update.
+type UpdatableAEAD interface {
+	AEAD
+	// Next derives the AEAD for the next key phase
+	Next() (UpdatableAEAD, error)
+}
+
+type aeadAESGCMWithSecrets struct {
+	AEAD
+
+	mySecret    []byte
+	otherSecret []byte
+	keyLen      int
+	ivLen       int
+}
+
+var _ UpdatableAEAD = &aeadAESGCMWithSecrets{}
+
 // DeriveAESKeys derives the AES keys and creates a matching AES-GCM AEAD instance
+// The returned AEAD is an UpdatableAEAD.
 func DeriveAESKeys(tls TLSExporter, pers protocol.Perspective) (AEAD, error) {
 	var myLabel, otherLabel string
 	if pers == protocol.PerspectiveClient {
@@ -26,24 +48,45 @@ func DeriveAESKeys(tls TLSExporter, pers protocol.Perspective) (AEAD, error) {
 		myLabel = serverExporterLabel
 		otherLabel = clientExporterLabel
 	}
-	myKey, myIV, err := computeKeyAndIV(tls, myLabel)
+	cs := tls.ConnectionState().CipherSuite
+	mySecret, err := tls.ComputeExporter(myLabel, nil, cs.Hash.Size())
 	if err != nil {
 		return nil, err
 	}
-	otherKey, otherIV, err := computeKeyAndIV(tls, otherLabel)
+	otherSecret, err := tls.ComputeExporter(otherLabel, nil, cs.Hash.Size())
 	if err != nil {
 		return nil, err
 	}
-	return NewAEADAESGCM(otherKey, myKey, otherIV, myIV)
+	return newAEADAESGCMWithSecrets(mySecret, otherSecret, cs.KeyLen, cs.IvLen)
 }
 
-func computeKeyAndIV(tls TLSExporter, label string) (key, iv []byte, err error) {
-	cs := tls.ConnectionState().CipherSuite
-	secret, err := tls.ComputeExporter(label, nil, cs.Hash.Size())
+func newAEADAESGCMWithSecrets(mySecret, otherSecret []byte, keyLen, ivLen int) (*aeadAESGCMWithSecrets, error) {
+	myKey, myIV := computeKeyAndIV(mySecret, keyLen, ivLen)
+	otherKey, otherIV := computeKeyAndIV(otherSecret, keyLen, ivLen)
+	aead, err := NewAEADAESGCM(otherKey, myKey, otherIV, myIV)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	key = qhkdfExpand(secret, "key", cs.KeyLen)
-	iv = qhkdfExpand(secret, "iv", cs.IvLen)
-	return key, iv, nil
+	return &aeadAESGCMWithSecrets{
+		AEAD:        aead,
+		mySecret:    mySecret,
+		otherSecret: otherSecret,
+		keyLen:      keyLen,
+		ivLen:       ivLen,
+	}, nil
+}
+
+func (a *aeadAESGCMWithSecrets) Next() (UpdatableAEAD, error) {
+	return newAEADAESGCMWithSecrets(
+		qhkdfExpand(a.mySecret, keyUpdateLabel, len(a.mySecret)),
+		qhkdfExpand(a.otherSecret, keyUpdateLabel, len(a.otherSecret)),
+		a.keyLen,
+		a.ivLen,
+	)
+}
+
+func computeKeyAndIV(secret []byte, keyLen, ivLen int) (key, iv []byte) {
+	key = qhkdfExpand(secret, "key", keyLen)
+	iv = qhkdfExpand(secret, "iv", ivLen)
+	return key, iv
 }
@@ -48,6 +48,24 @@ var _ = Describe("Key Derivation", func() {
 		Expect(data).To(Equal([]byte("foobar")))
 	})
 
+	It("derives the keys for the next key phase", func() {
+		clientAEAD, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveClient)
+		Expect(err).ToNot(HaveOccurred())
+		serverAEAD, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveServer)
+		Expect(err).ToNot(HaveOccurred())
+		nextClientAEAD, err := clientAEAD.(UpdatableAEAD).Next()
+		Expect(err).ToNot(HaveOccurred())
+		nextServerAEAD, err := serverAEAD.(UpdatableAEAD).Next()
+		Expect(err).ToNot(HaveOccurred())
+		ciphertext := nextClientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
+		data, err := nextServerAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foobar")))
+		// the keys of the previous key phase can't be used
+		_, err = serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("fails when computing the exporter fails", func() {
 		testErr := errors.New("test error")
 		_, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256, computerError: testErr}, protocol.PerspectiveClient)
@@ -27,7 +27,8 @@ type cryptoSetupServer struct {
 
 	connID               protocol.ConnectionID
 	remoteAddr           net.Addr
-	scfg                 *ServerConfig
+	scfg                 *ServerConfig   // the current server config, sent in the REJ
+	scfgs                []*ServerConfig // all server configs that a CHLO may reference
 	diversificationNonce []byte
 
 	version           protocol.VersionNumber
@@ -64,14 +65,16 @@ var _ CryptoSetup = &cryptoSetupServer{}
 // This is an experiment implemented by Chrome in QUIC 38, which we don't support at this point.
 var ErrNSTPExperiment = qerr.Error(qerr.InvalidCryptoMessageParameter, "NSTP experiment. Unsupported")
 
-// NewCryptoSetup creates a new CryptoSetup instance for a server
+// NewCryptoSetup creates a new CryptoSetup instance for a server.
+// The first server config is the current one, and is sent to the client in the REJ.
+// The others are still accepted if the client references them in the CHLO, e.g. when rotating server configs.
 func NewCryptoSetup(
 	cryptoStream io.ReadWriter,
 	connID protocol.ConnectionID,
 	remoteAddr net.Addr,
 	version protocol.VersionNumber,
 	divNonce []byte,
-	scfg *ServerConfig,
+	scfgs []*ServerConfig,
 	params *TransportParameters,
 	supportedVersions []protocol.VersionNumber,
 	acceptSTK func(net.Addr, *Cookie) bool,
@@ -79,6 +82,9 @@ func NewCryptoSetup(
 	handshakeEvent chan<- struct{},
 	logger utils.Logger,
 ) (CryptoSetup, error) {
+	if len(scfgs) == 0 {
+		return nil, errors.New("CryptoSetupServer: no server config")
+	}
 	nullAEAD, err := crypto.NewNullAEAD(protocol.PerspectiveServer, connID, version)
 	if err != nil {
 		return nil, err
@@ -90,7 +96,8 @@ func NewCryptoSetup(
 		version:              version,
 		supportedVersions:    supportedVersions,
 		diversificationNonce: divNonce,
-		scfg:                 scfg,
+		scfg:                 scfgs[0],
+		scfgs:                scfgs,
 		keyDerivation:        crypto.DeriveQuicCryptoAESKeys,
 		keyExchange:          getEphermalKEX,
 		nullAEAD:             nullAEAD,
@@ -159,7 +166,7 @@ func (h *cryptoSetupServer) handleMessage(chloData []byte, cryptoData map[Tag][]
 	var reply []byte
 	var err error
 
-	certUncompressed, err := h.scfg.certChain.GetLeafCert(sni)
+	certUncompressed, err := h.getServerConfig(cryptoData[TagSCID]).certChain.GetLeafCert(sni)
 	if err != nil {
 		return false, err
 	}
@@ -280,7 +287,11 @@ func (h *cryptoSetupServer) isInchoateCHLO(cryptoData map[Tag][]byte, cert []byt
 		return true
 	}
 	scid, ok := cryptoData[TagSCID]
-	if !ok || !bytes.Equal(h.scfg.ID, scid) {
+	if !ok {
+		return true
+	}
+	scfg := h.getServerConfig(scid)
+	if !bytes.Equal(scfg.ID, scid) {
 		return true
 	}
 	xlctTag, ok := cryptoData[TagXLCT]
@@ -291,11 +302,22 @@ func (h *cryptoSetupServer) isInchoateCHLO(cryptoData map[Tag][]byte, cert []byt
 	if crypto.HashCert(cert) != xlct {
 		return true
 	}
-	return !h.acceptSTK(cryptoData[TagSTK])
+	return !h.acceptSTK(scfg, cryptoData[TagSTK])
+}
+
+// getServerConfig returns the server config with the given SCID.
+// If there's no such server config, the current server config is returned.
+func (h *cryptoSetupServer) getServerConfig(scid []byte) *ServerConfig {
+	for _, scfg := range h.scfgs {
+		if bytes.Equal(scfg.ID, scid) {
+			return scfg
+		}
+	}
+	return h.scfg
 }
 
-func (h *cryptoSetupServer) acceptSTK(token []byte) bool {
-	stk, err := h.scfg.cookieGenerator.DecodeToken(token)
+func (h *cryptoSetupServer) acceptSTK(scfg *ServerConfig, token []byte) bool {
+	stk, err := scfg.cookieGenerator.DecodeToken(token)
 	if err != nil {
 		h.logger.Debugf("STK invalid: %s", err.Error())
 		return false
@@ -315,7 +337,7 @@ func (h *cryptoSetupServer) handleInchoateCHLO(sni string, chlo []byte, cryptoDa
 		TagSVID: []byte("quic-go"),
 	}
 
-	if h.acceptSTK(cryptoData[TagSTK]) {
+	if h.acceptSTK(h.scfg, cryptoData[TagSTK]) {
 		proof, err := h.scfg.Sign(sni, chlo)
 		if err != nil {
 			return nil, err
@@ -345,8 +367,9 @@ func (h *cryptoSetupServer) handleInchoateCHLO(sni string, chlo []byte, cryptoDa
 }
 
 func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[Tag][]byte) ([]byte, error) {
-	// We have a CHLO matching our server config, we can continue with the 0-RTT handshake
-	sharedSecret, err := h.scfg.kex.CalculateSharedKey(cryptoData[TagPUBS])
+	// We have a CHLO matching one of our server configs, we can continue with the 0-RTT handshake
+	scfg := h.getServerConfig(cryptoData[TagSCID])
+	sharedSecret, err := scfg.kex.CalculateSharedKey(cryptoData[TagPUBS])
 	if err != nil {
 		return nil, err
 	}
@@ -354,7 +377,7 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	certUncompressed, err := h.scfg.certChain.GetLeafCert(sni)
+	certUncompressed, err := scfg.certChain.GetLeafCert(sni)
 	if err != nil {
 		return nil, err
 	}
@@ -365,7 +388,7 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 	}
 
 	clientNonce := cryptoData[TagNONC]
-	err = h.validateClientNonce(clientNonce)
+	err = h.validateClientNonce(scfg, clientNonce)
 	if err != nil {
 		return nil, err
 	}
@@ -386,7 +409,7 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 		clientNonce,
 		h.connID,
 		data,
-		h.scfg.Get(),
+		scfg.Get(),
 		certUncompressed,
 		h.diversificationNonce,
 		protocol.PerspectiveServer,
@@ -416,7 +439,7 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 		fsNonce.Bytes(),
 		h.connID,
 		data,
-		h.scfg.Get(),
+		scfg.Get(),
 		certUncompressed,
 		nil,
 		protocol.PerspectiveServer,
@@ -456,11 +479,11 @@ func (h *cryptoSetupServer) ConnectionState() ConnectionState {
 	}
 }
 
-func (h *cryptoSetupServer) validateClientNonce(nonce []byte) error {
+func (h *cryptoSetupServer) validateClientNonce(scfg *ServerConfig, nonce []byte) error {
 	if len(nonce) != 32 {
 		return qerr.Error(qerr.InvalidCryptoMessageParameter, "invalid client nonce length")
 	}
-	if !bytes.Equal(nonce[4:12], h.scfg.obit) {
+	if !bytes.Equal(nonce[4:12], scfg.obit) {
 		return qerr.Error(qerr.InvalidCryptoMessageParameter, "OBIT not matching")
 	}
 	return nil
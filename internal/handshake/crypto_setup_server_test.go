@@ -163,7 +163,7 @@ var _ = Describe("Server Crypto Setup", func() {
 			remoteAddr,
 			version,
 			make([]byte, 32), // div nonce
-			scfg,
+			[]*ServerConfig{scfg},
 			&TransportParameters{IdleTimeout: protocol.DefaultIdleTimeout},
 			supportedVersions,
 			nil,
@@ -389,6 +389,57 @@ var _ = Describe("Server Crypto Setup", func() {
 			Expect(cs.isInchoateCHLO(fullCHLO, cert)).To(BeFalse())
 		})
 
+		Context("with multiple server configs", func() {
+			var scfg2 *ServerConfig
+
+			BeforeEach(func() {
+				var err error
+				scfg2, err = NewServerConfig(&mockKEX{}, signer)
+				Expect(err).ToNot(HaveOccurred())
+				scfg2.cookieGenerator.cookieProtector = &mockCookieProtector{}
+				cs.scfgs = append(cs.scfgs, scfg2)
+			})
+
+			It("handles a CHLO referencing another server config with that config", func() {
+				var usedScfgs [][]byte
+				cs.keyDerivation = func(_ bool, _, _ []byte, _ protocol.ConnectionID, _ []byte, scfgData []byte, _ []byte, _ []byte, _ protocol.Perspective) (crypto.AEAD, error) {
+					usedScfgs = append(usedScfgs, scfgData)
+					return mockcrypto.NewMockAEAD(mockCtrl), nil
+				}
+				stk, err := scfg2.cookieGenerator.NewToken(cs.remoteAddr)
+				Expect(err).ToNot(HaveOccurred())
+				nonce := make([]byte, 32)
+				copy(nonce[4:12], scfg2.obit)
+				fullCHLO[TagSCID] = scfg2.ID
+				fullCHLO[TagSTK] = stk
+				fullCHLO[TagNONC] = nonce
+				Expect(cs.isInchoateCHLO(fullCHLO, cert)).To(BeFalse())
+				done, err := cs.handleMessage(bytes.Repeat([]byte{'a'}, protocol.MinClientHelloSize), fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeTrue())
+				Expect(stream.dataWritten.Bytes()).To(HavePrefix("SHLO"))
+				Expect(usedScfgs).To(Equal([][]byte{scfg2.Get(), scfg2.Get()}))
+			})
+
+			It("rejects a CHLO using the OBIT of another server config", func() {
+				fullCHLO[TagSCID] = scfg2.ID
+				fullCHLO[TagSTK], _ = scfg2.cookieGenerator.NewToken(cs.remoteAddr)
+				_, err := cs.handleMessage(bytes.Repeat([]byte{'a'}, protocol.MinClientHelloSize), fullCHLO)
+				Expect(err).To(MatchError("InvalidCryptoMessageParameter: OBIT not matching"))
+			})
+
+			It("sends the current server config in the REJ", func() {
+				fullCHLO[TagSCID] = []byte("unknown SCID")
+				done, err := cs.handleMessage(bytes.Repeat([]byte{'a'}, protocol.MinClientHelloSize), fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+				message, err := ParseHandshakeMessage(&stream.dataWritten)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(message.Tag).To(Equal(TagREJ))
+				Expect(message.Data[TagSCFG]).To(Equal(scfg.Get()))
+			})
+		})
+
 		It("rejects CHLOs without the version tag", func() {
 			HandshakeMessage{
 				Tag: TagCHLO,
@@ -22,6 +22,12 @@ type cryptoSetupTLS struct {
 	keyDerivation KeyDerivationFunction
 	nullAEAD      crypto.AEAD
 	aead          crypto.AEAD
+	// the AEAD used before the last key update
+	// The peer might still use it for reordered packets.
+	prevAEAD crypto.AEAD
+	keyPhase int
+	// set when we initiated a key update, until the peer sends a packet in the new key phase
+	keyUpdateInProgress bool
 
 	tls            mintTLS
 	conn           *cryptoStreamConn
@@ -30,6 +36,15 @@ type cryptoSetupTLS struct {
 
 var _ CryptoSetupTLS = &cryptoSetupTLS{}
 
+// A keyPhaseSealer is a Sealer for 1-RTT packets.
+// It reports the key phase that the packet header has to carry.
+type keyPhaseSealer struct {
+	Sealer
+	keyPhase int
+}
+
+func (s *keyPhaseSealer) KeyPhase() int { return s.keyPhase }
+
 // NewCryptoSetupTLSServer creates a new TLS CryptoSetup instance for a server
 func NewCryptoSetupTLSServer(
 	cryptoStream io.ReadWriter,
@@ -109,14 +124,85 @@ func (h *cryptoSetupTLS) OpenHandshake(dst, src []byte, packetNumber protocol.Pa
 	return h.nullAEAD.Open(dst, src, packetNumber, associatedData)
 }
 
-func (h *cryptoSetupTLS) Open1RTT(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+func (h *cryptoSetupTLS) Open1RTT(dst, src []byte, packetNumber protocol.PacketNumber, keyPhase int, associatedData []byte) ([]byte, error) {
 	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
 	if h.aead == nil {
+		h.mutex.RUnlock()
 		return nil, errors.New("no 1-RTT sealer")
 	}
-	return h.aead.Open(dst, src, packetNumber, associatedData)
+	if keyPhase == h.keyPhase && !h.keyUpdateInProgress {
+		defer h.mutex.RUnlock()
+		return h.aead.Open(dst, src, packetNumber, associatedData)
+	}
+	h.mutex.RUnlock()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	// the key phase might have changed while we didn't hold the lock
+	if keyPhase == h.keyPhase {
+		data, err := h.aead.Open(dst, src, packetNumber, associatedData)
+		if err == nil {
+			// the peer is now using the keys we updated to
+			h.keyUpdateInProgress = false
+		}
+		return data, err
+	}
+	// This is either a reordered packet sent before the last key update,
+	// or the peer initiated a key update.
+	if h.prevAEAD != nil {
+		if data, err := h.prevAEAD.Open(dst, src, packetNumber, associatedData); err == nil {
+			return data, nil
+		}
+	}
+	next, err := h.nextAEAD()
+	if err != nil {
+		return nil, err
+	}
+	data, err := next.Open(dst, src, packetNumber, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	h.rotateKeys(next)
+	return data, nil
+}
+
+// UpdateKeys initiates a key update.
+// All subsequent 1-RTT packets are sealed using the next key phase.
+// Another key update can only be initiated after a packet sent by the peer
+// using the new key phase was received.
+func (h *cryptoSetupTLS) UpdateKeys() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.aead == nil {
+		return errors.New("cannot update keys before the handshake completes")
+	}
+	if h.keyUpdateInProgress {
+		return errors.New("a key update is already in progress")
+	}
+	next, err := h.nextAEAD()
+	if err != nil {
+		return err
+	}
+	h.rotateKeys(next)
+	h.keyUpdateInProgress = true
+	return nil
+}
+
+// must be called with the mutex held
+func (h *cryptoSetupTLS) nextAEAD() (crypto.AEAD, error) {
+	aead, ok := h.aead.(crypto.UpdatableAEAD)
+	if !ok {
+		return nil, errors.New("AEAD doesn't support key updates")
+	}
+	return aead.Next()
+}
+
+// must be called with the mutex held
+func (h *cryptoSetupTLS) rotateKeys(next crypto.AEAD) {
+	h.prevAEAD = h.aead
+	h.aead = next
+	h.keyPhase ^= 1
 }
 
 func (h *cryptoSetupTLS) GetSealer() (protocol.EncryptionLevel, Sealer) {
@@ -124,7 +210,7 @@ func (h *cryptoSetupTLS) GetSealer() (protocol.EncryptionLevel, Sealer) {
 	defer h.mutex.RUnlock()
 
 	if h.aead != nil {
-		return protocol.EncryptionForwardSecure, h.aead
+		return protocol.EncryptionForwardSecure, &keyPhaseSealer{Sealer: h.aead, keyPhase: h.keyPhase}
 	}
 	return protocol.EncryptionUnencrypted, h.nullAEAD
 }
@@ -141,7 +227,7 @@ func (h *cryptoSetupTLS) GetSealerWithEncryptionLevel(encLevel protocol.Encrypti
 		if h.aead == nil {
 			return nil, errNoSealer
 		}
-		return h.aead, nil
+		return &keyPhaseSealer{Sealer: h.aead, keyPhase: h.keyPhase}, nil
 	default:
 		return nil, errNoSealer
 	}
@@ -2,6 +2,7 @@ package handshake
 
 import (
 	"bytes"
+	gocrypto "crypto"
 	"errors"
 	"fmt"
 
@@ -18,6 +19,19 @@ func mockKeyDerivation(crypto.TLSExporter, protocol.Perspective) (crypto.AEAD, e
 	return mockcrypto.NewMockAEAD(mockCtrl), nil
 }
 
+// keyUpdateTestExporter is used to derive real 1-RTT keys, which are needed for key updates
+type keyUpdateTestExporter struct{}
+
+func (keyUpdateTestExporter) ConnectionState() mint.ConnectionState {
+	return mint.ConnectionState{
+		CipherSuite: mint.CipherSuiteParams{Hash: gocrypto.SHA256, KeyLen: 16, IvLen: 12},
+	}
+}
+
+func (keyUpdateTestExporter) ComputeExporter(label string, _ []byte, _ int) ([]byte, error) {
+	return []byte(label), nil
+}
+
 var _ = Describe("TLS Crypto Setup", func() {
 	var (
 		cs             *cryptoSetupTLS
@@ -144,12 +158,113 @@ var _ = Describe("TLS Crypto Setup", func() {
 			It("is used for opening", func() {
 				doHandshake()
 				cs.aead.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("encrypted"), protocol.PacketNumber(6), []byte{}).Return([]byte("decrypted"), nil)
-				d, err := cs.Open1RTT(nil, []byte("encrypted"), 6, []byte{})
+				d, err := cs.Open1RTT(nil, []byte("encrypted"), 6, 0, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
 			})
 		})
 
+		Context("key updates", func() {
+			var peer crypto.UpdatableAEAD
+
+			BeforeEach(func() {
+				aead, err := crypto.DeriveAESKeys(keyUpdateTestExporter{}, protocol.PerspectiveServer)
+				Expect(err).ToNot(HaveOccurred())
+				cs.aead = aead
+				peerAEAD, err := crypto.DeriveAESKeys(keyUpdateTestExporter{}, protocol.PerspectiveClient)
+				Expect(err).ToNot(HaveOccurred())
+				peer = peerAEAD.(crypto.UpdatableAEAD)
+			})
+
+			getKeyPhase := func() int {
+				enc, sealer := cs.GetSealer()
+				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
+				return sealer.(*keyPhaseSealer).KeyPhase()
+			}
+
+			It("errors before the handshake completes", func() {
+				cs.aead = nil
+				Expect(cs.UpdateKeys()).To(MatchError("cannot update keys before the handshake completes"))
+			})
+
+			It("errors if the AEAD doesn't support key updates", func() {
+				doHandshake()
+				Expect(cs.UpdateKeys()).To(MatchError("AEAD doesn't support key updates"))
+			})
+
+			It("starts with key phase 0", func() {
+				Expect(getKeyPhase()).To(BeZero())
+				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionForwardSecure)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sealer.(*keyPhaseSealer).KeyPhase()).To(BeZero())
+			})
+
+			It("updates the keys", func() {
+				Expect(cs.UpdateKeys()).To(Succeed())
+				Expect(getKeyPhase()).To(Equal(1))
+				_, sealer := cs.GetSealer()
+				sealed := sealer.Seal(nil, []byte("foobar"), 10, []byte("aad"))
+				// the peer can't decrypt this packet with the old keys
+				_, err := peer.Open(nil, sealed, 10, []byte("aad"))
+				Expect(err).To(HaveOccurred())
+				next, err := peer.Next()
+				Expect(err).ToNot(HaveOccurred())
+				data, err := next.Open(nil, sealed, 10, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+				// the peer answers with the new keys
+				data, err = cs.Open1RTT(nil, next.Seal(nil, []byte("raboof"), 11, []byte("aad")), 11, 1, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("raboof")))
+				Expect(getKeyPhase()).To(Equal(1))
+			})
+
+			It("doesn't allow another key update before the peer used the new keys", func() {
+				Expect(cs.UpdateKeys()).To(Succeed())
+				Expect(cs.UpdateKeys()).To(MatchError("a key update is already in progress"))
+				Expect(getKeyPhase()).To(Equal(1))
+				// a reordered packet using the old keys doesn't complete the key update
+				_, err := cs.Open1RTT(nil, peer.Seal(nil, []byte("foobar"), 10, []byte("aad")), 10, 0, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cs.UpdateKeys()).To(MatchError("a key update is already in progress"))
+				// the peer answers with the new keys
+				next, err := peer.Next()
+				Expect(err).ToNot(HaveOccurred())
+				_, err = cs.Open1RTT(nil, next.Seal(nil, []byte("raboof"), 11, []byte("aad")), 11, 1, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cs.UpdateKeys()).To(Succeed())
+				Expect(getKeyPhase()).To(BeZero())
+			})
+
+			It("still opens reordered packets using the old keys after a key update", func() {
+				sealed := peer.Seal(nil, []byte("foobar"), 10, []byte("aad"))
+				Expect(cs.UpdateKeys()).To(Succeed())
+				data, err := cs.Open1RTT(nil, sealed, 10, 0, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(getKeyPhase()).To(Equal(1))
+			})
+
+			It("updates the keys when the peer initiates a key update", func() {
+				next, err := peer.Next()
+				Expect(err).ToNot(HaveOccurred())
+				data, err := cs.Open1RTT(nil, next.Seal(nil, []byte("foobar"), 10, []byte("aad")), 10, 1, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(getKeyPhase()).To(Equal(1))
+				_, sealer := cs.GetSealer()
+				data, err = next.Open(nil, sealer.Seal(nil, []byte("raboof"), 11, []byte("aad")), 11, []byte("aad"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("raboof")))
+			})
+
+			It("doesn't update the keys if a packet with the other key phase can't be decrypted", func() {
+				_, err := cs.Open1RTT(nil, []byte("not a valid ciphertext"), 10, 1, []byte("aad"))
+				Expect(err).To(HaveOccurred())
+				Expect(getKeyPhase()).To(BeZero())
+			})
+		})
+
 		Context("forcing encryption levels", func() {
 			It("forces null encryption", func() {
 				doHandshake()
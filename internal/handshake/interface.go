@@ -49,7 +49,8 @@ type CryptoSetupTLS interface {
 	baseCryptoSetup
 
 	OpenHandshake(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error)
-	Open1RTT(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error)
+	Open1RTT(dst, src []byte, packetNumber protocol.PacketNumber, keyPhase int, associatedData []byte) ([]byte, error)
+	UpdateKeys() error
 }
 
 // ConnectionState records basic details about the QUIC connection.
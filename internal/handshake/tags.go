@@ -27,8 +27,12 @@ const (
 	TagMIDS Tag = 'M' + 'I'<<8 + 'D'<<16 + 'S'<<24
 	// TagUAID is the user agent ID
 	TagUAID Tag = 'U' + 'A'<<8 + 'I'<<16 + 'D'<<24
+	// TagALPN is the application layer protocol
+	TagALPN Tag = 'A' + 'L'<<8 + 'P'<<16 + 'N'<<24
 	// TagSVID is the server ID (unofficial tag by us :)
 	TagSVID Tag = 'S' + 'V'<<8 + 'I'<<16 + 'D'<<24
+	// TagDGRM is the support for DATAGRAM frames (unofficial tag by us)
+	TagDGRM Tag = 'D' + 'G'<<8 + 'R'<<16 + 'M'<<24
 	// TagTCID is truncation of the connection ID
 	TagTCID Tag = 'T' + 'C'<<8 + 'I'<<16 + 'D'<<24
 	// TagPDMD is the proof demand
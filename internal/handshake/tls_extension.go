@@ -24,6 +24,7 @@ const (
 	statelessResetTokenParameterID   transportParameterID = 0x6
 	initialMaxUniStreamsParameterID  transportParameterID = 0x8
 	disableMigrationParameterID      transportParameterID = 0x9
+	datagramsParameterID             transportParameterID = 0x20 // unofficial, for our DATAGRAM frames
 )
 
 type clientHelloTransportParameters struct {
@@ -37,6 +37,15 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(params.OmitConnectionID).To(BeTrue())
 			})
 
+			It("reads if DATAGRAM frames are supported", func() {
+				params, err := readHelloMap(map[Tag][]byte{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.SupportsDatagrams).To(BeFalse())
+				params, err = readHelloMap(map[Tag][]byte{TagDGRM: {}})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.SupportsDatagrams).To(BeTrue())
+			})
+
 			It("doesn't allow idle timeouts below the minimum remote idle timeout", func() {
 				t := 2 * time.Second
 				Expect(t).To(BeNumerically("<", protocol.MinRemoteIdleTimeout))
@@ -101,6 +110,12 @@ var _ = Describe("Transport Parameters", func() {
 				entryMap := params.getHelloMap()
 				Expect(entryMap).To(HaveKeyWithValue(TagTCID, []byte{0, 0, 0, 0}))
 			})
+
+			It("announces support for DATAGRAM frames", func() {
+				Expect((&TransportParameters{}).getHelloMap()).ToNot(HaveKey(TagDGRM))
+				params := &TransportParameters{SupportsDatagrams: true}
+				Expect(params.getHelloMap()).To(HaveKeyWithValue(TagDGRM, []byte{}))
+			})
 		})
 	})
 
@@ -145,6 +160,7 @@ var _ = Describe("Transport Parameters", func() {
 					maxPacketSizeParameterID:         {0x73, 0x31},
 					disableMigrationParameterID:      {},
 					statelessResetTokenParameterID:   statelessResetToken,
+					datagramsParameterID:             {},
 				}
 			})
 
@@ -160,6 +176,7 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(params.MaxPacketSize).To(Equal(protocol.ByteCount(0x7331)))
 				Expect(params.DisableMigration).To(BeTrue())
 				Expect(params.StatelessResetToken).To(Equal(statelessResetToken))
+				Expect(params.SupportsDatagrams).To(BeTrue())
 			})
 
 			It("errors if a parameter is sent twice", func() {
@@ -229,6 +246,12 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(err).To(MatchError("wrong length for disable_migration: 1 (expected empty)"))
 			})
 
+			It("rejects the parameters if datagrams has the wrong length", func() {
+				parameters[datagramsParameterID] = []byte{0x1} // should empty
+				err := params.unmarshal(marshal(parameters))
+				Expect(err).To(MatchError("wrong length for datagrams: 1 (expected empty)"))
+			})
+
 			It("rejects the parameters if the stateless_reset_token has the wrong length", func() {
 				parameters[statelessResetTokenParameterID] = statelessResetToken[1:]
 				err := params.unmarshal(marshal(parameters))
@@ -252,6 +275,7 @@ var _ = Describe("Transport Parameters", func() {
 					MaxUniStreams:               0x4321,
 					DisableMigration:            true,
 					StatelessResetToken:         bytes.Repeat([]byte{100}, 16),
+					SupportsDatagrams:           true,
 				}
 				b := &bytes.Buffer{}
 				params.marshal(b)
@@ -265,6 +289,7 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(p.IdleTimeout).To(Equal(params.IdleTimeout))
 				Expect(p.DisableMigration).To(Equal(params.DisableMigration))
 				Expect(p.StatelessResetToken).To(Equal(params.StatelessResetToken))
+				Expect(p.SupportsDatagrams).To(Equal(params.SupportsDatagrams))
 			})
 		})
 	})
@@ -30,6 +30,8 @@ type TransportParameters struct {
 	IdleTimeout         time.Duration
 	DisableMigration    bool   // only used for IETF QUIC
 	StatelessResetToken []byte // only used for IETF QUIC
+
+	SupportsDatagrams bool
 }
 
 // readHelloMap reads the transport parameters from the tags sent in a gQUIC handshake message
@@ -70,6 +72,9 @@ func readHelloMap(tags map[Tag][]byte) (*TransportParameters, error) {
 		}
 		params.ConnectionFlowControlWindow = protocol.ByteCount(v)
 	}
+	if _, ok := tags[TagDGRM]; ok {
+		params.SupportsDatagrams = true
+	}
 	return params, nil
 }
 
@@ -93,6 +98,9 @@ func (p *TransportParameters) getHelloMap() map[Tag][]byte {
 	if p.OmitConnectionID {
 		tags[TagTCID] = []byte{0, 0, 0, 0}
 	}
+	if p.SupportsDatagrams {
+		tags[TagDGRM] = []byte{}
+	}
 	return tags
 }
 
@@ -153,6 +161,11 @@ func (p *TransportParameters) unmarshal(data []byte) error {
 				return fmt.Errorf("wrong length for stateless_reset_token: %d (expected 16)", paramLen)
 			}
 			p.StatelessResetToken = data[:16]
+		case datagramsParameterID:
+			if paramLen != 0 {
+				return fmt.Errorf("wrong length for datagrams: %d (expected empty)", paramLen)
+			}
+			p.SupportsDatagrams = true
 		}
 		data = data[paramLen:]
 	}
@@ -206,6 +219,10 @@ func (p *TransportParameters) marshal(b *bytes.Buffer) {
 		utils.BigEndian.WriteUint16(b, uint16(len(p.StatelessResetToken))) // should always be 16 bytes
 		b.Write(p.StatelessResetToken)
 	}
+	if p.SupportsDatagrams {
+		utils.BigEndian.WriteUint16(b, uint16(datagramsParameterID))
+		utils.BigEndian.WriteUint16(b, 0)
+	}
 }
 
 // String returns a string representation, intended for logging.
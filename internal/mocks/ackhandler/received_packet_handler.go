@@ -70,6 +70,16 @@ func (mr *MockReceivedPacketHandlerMockRecorder) IgnoreBelow(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IgnoreBelow", reflect.TypeOf((*MockReceivedPacketHandler)(nil).IgnoreBelow), arg0)
 }
 
+// QueueAck mocks base method
+func (m *MockReceivedPacketHandler) QueueAck() {
+	m.ctrl.Call(m, "QueueAck")
+}
+
+// QueueAck indicates an expected call of QueueAck
+func (mr *MockReceivedPacketHandlerMockRecorder) QueueAck() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueAck", reflect.TypeOf((*MockReceivedPacketHandler)(nil).QueueAck))
+}
+
 // ReceivedPacket mocks base method
 func (m *MockReceivedPacketHandler) ReceivedPacket(arg0 protocol.PacketNumber, arg1 time.Time, arg2 bool) error {
 	ret := m.ctrl.Call(m, "ReceivedPacket", arg0, arg1, arg2)
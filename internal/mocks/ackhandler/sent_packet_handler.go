@@ -74,6 +74,18 @@ func (mr *MockSentPacketHandlerMockRecorder) GetAlarmTimeout() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlarmTimeout", reflect.TypeOf((*MockSentPacketHandler)(nil).GetAlarmTimeout))
 }
 
+// GetBytesInFlight mocks base method
+func (m *MockSentPacketHandler) GetBytesInFlight() protocol.ByteCount {
+	ret := m.ctrl.Call(m, "GetBytesInFlight")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// GetBytesInFlight indicates an expected call of GetBytesInFlight
+func (mr *MockSentPacketHandlerMockRecorder) GetBytesInFlight() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBytesInFlight", reflect.TypeOf((*MockSentPacketHandler)(nil).GetBytesInFlight))
+}
+
 // GetLowestPacketNotConfirmedAcked mocks base method
 func (m *MockSentPacketHandler) GetLowestPacketNotConfirmedAcked() protocol.PacketNumber {
 	ret := m.ctrl.Call(m, "GetLowestPacketNotConfirmedAcked")
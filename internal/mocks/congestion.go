@@ -35,6 +35,18 @@ func (m *MockSendAlgorithm) EXPECT() *MockSendAlgorithmMockRecorder {
 	return m.recorder
 }
 
+// CanSend mocks base method
+func (m *MockSendAlgorithm) CanSend(arg0 protocol.ByteCount) bool {
+	ret := m.ctrl.Call(m, "CanSend", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanSend indicates an expected call of CanSend
+func (mr *MockSendAlgorithmMockRecorder) CanSend(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanSend", reflect.TypeOf((*MockSendAlgorithm)(nil).CanSend), arg0)
+}
+
 // GetCongestionWindow mocks base method
 func (m *MockSendAlgorithm) GetCongestionWindow() protocol.ByteCount {
 	ret := m.ctrl.Call(m, "GetCongestionWindow")
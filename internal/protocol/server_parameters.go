@@ -8,6 +8,10 @@ const MaxPacketSizeIPv4 = 1252
 // MaxPacketSizeIPv6 is the maximum packet size that we use for sending IPv6 packets.
 const MaxPacketSizeIPv6 = 1232
 
+// MaxCoalescedWriteSize is the maximum number of bytes that Stream.Write buffers when the WriteCoalescingDelay is used.
+// Larger writes block until the data was sent, as they would without the delay.
+const MaxCoalescedWriteSize = MaxReceivePacketSize
+
 // NonForwardSecurePacketSizeReduction is the number of bytes a non forward-secure packet has to be smaller than a forward-secure packet
 // This makes sure that those packets can always be retransmitted without splitting the contained StreamFrames
 const NonForwardSecurePacketSizeReduction = 50
@@ -20,6 +24,10 @@ const DefaultMaxCongestionWindow ByteCount = defaultMaxCongestionWindowPackets *
 // InitialCongestionWindow is the initial congestion window in QUIC packets
 const InitialCongestionWindow ByteCount = 32 * DefaultTCPMSS
 
+// MaxServerConfigs is the maximum number of gQUIC server configs a server accepts CHLOs for.
+// When rotating the server config, the oldest one is dropped.
+const MaxServerConfigs = 3
+
 // MaxUndecryptablePackets limits the number of undecryptable packets that a
 // session queues for later until it sends a public reset.
 const MaxUndecryptablePackets = 10
@@ -74,6 +82,9 @@ const MaxStreamsMinimumIncrement = 10
 // MaxSessionUnprocessedPackets is the max number of packets stored in each session that are not yet processed.
 const MaxSessionUnprocessedPackets = defaultMaxCongestionWindowPackets
 
+// MaxDatagramQueueLen is the max number of DATAGRAM frames queued for sending in each session.
+const MaxDatagramQueueLen = 32
+
 // SkipPacketAveragePeriodLength is the average period length in which one packet number is skipped to prevent an Optimistic ACK attack
 const SkipPacketAveragePeriodLength PacketNumber = 500
 
@@ -169,11 +169,17 @@ func GetGreasedVersions(supported []VersionNumber) []VersionNumber {
 	return greased
 }
 
+// IsReservedVersion says if a version number is a reserved version number (v & 0x0f0f0f0f == 0x0a0a0a0a).
+// Reserved versions are used to grease the version negotiation.
+func IsReservedVersion(v VersionNumber) bool {
+	return v&0x0f0f0f0f == 0x0a0a0a0a
+}
+
 // StripGreasedVersions strips all greased versions from a slice of versions
 func StripGreasedVersions(versions []VersionNumber) []VersionNumber {
 	realVersions := make([]VersionNumber, 0, len(versions))
 	for _, v := range versions {
-		if v&0x0f0f0f0f != 0x0a0a0a0a {
+		if !IsReservedVersion(v) {
 			realVersions = append(realVersions, v)
 		}
 	}
@@ -179,6 +179,16 @@ var _ = Describe("Version", func() {
 			Expect(isReservedVersion(greased[0])).To(BeTrue())
 		})
 
+		It("recognizes reserved versions", func() {
+			Expect(IsReservedVersion(0x0a0a0a0a)).To(BeTrue())
+			Expect(IsReservedVersion(0x1a2a3a4a)).To(BeTrue())
+			Expect(IsReservedVersion(0x0a0a0a0b)).To(BeFalse())
+			for _, v := range SupportedVersions {
+				Expect(IsReservedVersion(v)).To(BeFalse())
+			}
+			Expect(IsReservedVersion(generateReservedVersion())).To(BeTrue())
+		})
+
 		It("strips greased versions", func() {
 			v := SupportedVersions[0]
 			greased := GetGreasedVersions([]VersionNumber{v})
@@ -0,0 +1,41 @@
+package utils
+
+import "time"
+
+// A Clock returns the current time and creates timers.
+// It allows tests to control the passage of time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// A ClockTimer is a timer created by a Clock.
+// Its methods behave like the methods of a time.Timer.
+type ClockTimer interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// DefaultClock implements the Clock interface using the Go stdlib clock.
+type DefaultClock struct{}
+
+var _ Clock = DefaultClock{}
+
+// Now gets the current time
+func (DefaultClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a time.Timer
+func (DefaultClock) NewTimer(d time.Duration) ClockTimer {
+	return &stdlibTimer{time.NewTimer(d)}
+}
+
+type stdlibTimer struct {
+	*time.Timer
+}
+
+func (t *stdlibTimer) Chan() <-chan time.Time {
+	return t.C
+}
@@ -7,19 +7,28 @@ import (
 
 // A Timer wrapper that behaves correctly when resetting
 type Timer struct {
-	t        *time.Timer
+	clock    Clock
+	t        ClockTimer
 	read     bool
 	deadline time.Time
 }
 
 // NewTimer creates a new timer that is not set
 func NewTimer() *Timer {
-	return &Timer{t: time.NewTimer(time.Duration(math.MaxInt64))}
+	return NewTimerWithClock(DefaultClock{})
+}
+
+// NewTimerWithClock creates a new timer that is not set, using the provided clock
+func NewTimerWithClock(clock Clock) *Timer {
+	return &Timer{
+		clock: clock,
+		t:     clock.NewTimer(time.Duration(math.MaxInt64)),
+	}
 }
 
 // Chan returns the channel of the wrapped timer
 func (t *Timer) Chan() <-chan time.Time {
-	return t.t.C
+	return t.t.Chan()
 }
 
 // Reset the timer, no matter whether the value was read or not
@@ -32,10 +41,10 @@ func (t *Timer) Reset(deadline time.Time) {
 	// We need to drain the timer if the value from its channel was not read yet.
 	// See https://groups.google.com/forum/#!topic/golang-dev/c9UUfASVPoU
 	if !t.t.Stop() && !t.read {
-		<-t.t.C
+		<-t.t.Chan()
 	}
 	if !deadline.IsZero() {
-		t.t.Reset(time.Until(deadline))
+		t.t.Reset(deadline.Sub(t.clock.Now()))
 	}
 
 	t.read = false
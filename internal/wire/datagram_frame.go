@@ -0,0 +1,65 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A DatagramFrame is a DATAGRAM frame, as defined by the DATAGRAM extension.
+// It carries unreliable application data, and is never retransmitted.
+type DatagramFrame struct {
+	DataLenPresent bool
+	Data           []byte
+}
+
+func parseDatagramFrame(r *bytes.Reader, _ protocol.VersionNumber) (*DatagramFrame, error) {
+	typeByte, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := &DatagramFrame{DataLenPresent: typeByte&0x1 > 0}
+	var dataLen uint64
+	if frame.DataLenPresent {
+		dataLen, err = utils.ReadVarInt(r)
+		if err != nil {
+			return nil, err
+		}
+		if dataLen > uint64(r.Len()) {
+			return nil, io.EOF
+		}
+	} else {
+		// The rest of the packet is data
+		dataLen = uint64(r.Len())
+	}
+	frame.Data = make([]byte, dataLen)
+	if _, err := io.ReadFull(r, frame.Data); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (f *DatagramFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	typeByte := uint8(0x30)
+	if f.DataLenPresent {
+		typeByte ^= 0x1
+	}
+	b.WriteByte(typeByte)
+	if f.DataLenPresent {
+		utils.WriteVarInt(b, uint64(len(f.Data)))
+	}
+	b.Write(f.Data)
+	return nil
+}
+
+// Length of a written frame
+func (f *DatagramFrame) Length(_ protocol.VersionNumber) protocol.ByteCount {
+	length := 1 + protocol.ByteCount(len(f.Data))
+	if f.DataLenPresent {
+		length += utils.VarIntLen(uint64(len(f.Data)))
+	}
+	return length
+}
@@ -171,6 +171,14 @@ var _ = Describe("Frame parsing", func() {
 			Expect(frame.(*AckFrame).LargestAcked()).To(Equal(protocol.PacketNumber(0x13)))
 		})
 
+		It("unpacks DATAGRAM frames", func() {
+			f := &DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			Expect(f.Write(buf, versionBigEndian)).To(Succeed())
+			frame, err := ParseNextFrame(bytes.NewReader(buf.Bytes()), nil, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(f))
+		})
+
 		It("errors on invalid type", func() {
 			_, err := ParseNextFrame(bytes.NewReader([]byte{0xf}), nil, versionBigEndian)
 			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0xf"))
@@ -186,6 +194,7 @@ var _ = Describe("Frame parsing", func() {
 				0x04: qerr.InvalidWindowUpdateData,
 				0x05: qerr.InvalidBlockedData,
 				0x06: qerr.InvalidStopWaitingData,
+				0x31: qerr.InvalidFrameData,
 			} {
 				_, err := ParseNextFrame(bytes.NewReader([]byte{b}), &Header{PacketNumberLen: 2}, versionBigEndian)
 				Expect(err).To(HaveOccurred())
@@ -328,6 +337,14 @@ var _ = Describe("Frame parsing", func() {
 			Expect(frame.(*PathResponseFrame).Data).To(Equal([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
 		})
 
+		It("unpacks DATAGRAM frames", func() {
+			f := &DatagramFrame{Data: []byte("foobar")}
+			Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+			frame, err := ParseNextFrame(bytes.NewReader(buf.Bytes()), nil, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(f))
+		})
+
 		It("errors on invalid type", func() {
 			_, err := ParseNextFrame(bytes.NewReader([]byte{0x42}), nil, versionIETFFrames)
 			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0x42"))
@@ -349,6 +366,7 @@ var _ = Describe("Frame parsing", func() {
 				0x10: qerr.InvalidStreamData,
 				0x1a: qerr.InvalidAckData,
 				0x1b: qerr.InvalidAckData,
+				0x31: qerr.InvalidFrameData,
 			} {
 				_, err := ParseNextFrame(bytes.NewReader([]byte{b}), nil, versionIETFFrames)
 				Expect(err).To(HaveOccurred())
@@ -0,0 +1,100 @@
+package quic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// mockClock is a utils.Clock that only advances when Advance is called.
+// Timers created by it fire when the clock is advanced past their deadline.
+type mockClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*mockClockTimer
+}
+
+var _ utils.Clock = &mockClock{}
+
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Unix(1000000, 0)}
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *mockClock) NewTimer(d time.Duration) utils.ClockTimer {
+	t := &mockClockTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mutex.Lock()
+	c.timers = append(c.timers, t)
+	c.mutex.Unlock()
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward and fires all timers that expired.
+func (c *mockClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		t.maybeFire()
+	}
+}
+
+// nextDeadline returns the earliest deadline of all active timers.
+// It returns the zero time if no timer is active.
+func (c *mockClock) nextDeadline() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var deadline time.Time
+	for _, t := range c.timers {
+		if t.active && (deadline.IsZero() || t.deadline.Before(deadline)) {
+			deadline = t.deadline
+		}
+	}
+	return deadline
+}
+
+type mockClockTimer struct {
+	clock    *mockClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *mockClockTimer) Chan() <-chan time.Time { return t.c }
+
+func (t *mockClockTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	wasActive := t.active
+	t.active = true
+	t.deadline = t.clock.now.Add(d)
+	t.maybeFire()
+	return wasActive
+}
+
+func (t *mockClockTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+// maybeFire must be called with the clock's mutex held
+func (t *mockClockTimer) maybeFire() {
+	if !t.active || t.deadline.After(t.clock.now) {
+		return
+	}
+	t.active = false
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
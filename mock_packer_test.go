@@ -57,6 +57,18 @@ func (mr *MockPackerMockRecorder) HandleTransportParameters(arg0 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleTransportParameters", reflect.TypeOf((*MockPacker)(nil).HandleTransportParameters), arg0)
 }
 
+// MaxDatagramSize mocks base method
+func (m *MockPacker) MaxDatagramSize() protocol.ByteCount {
+	ret := m.ctrl.Call(m, "MaxDatagramSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// MaxDatagramSize indicates an expected call of MaxDatagramSize
+func (mr *MockPackerMockRecorder) MaxDatagramSize() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaxDatagramSize", reflect.TypeOf((*MockPacker)(nil).MaxDatagramSize))
+}
+
 // MaybePackAckPacket mocks base method
 func (m *MockPacker) MaybePackAckPacket() (*packedPacket, error) {
 	ret := m.ctrl.Call(m, "MaybePackAckPacket")
@@ -83,6 +95,19 @@ func (mr *MockPackerMockRecorder) PackConnectionClose(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackConnectionClose", reflect.TypeOf((*MockPacker)(nil).PackConnectionClose), arg0)
 }
 
+// PackDatagram mocks base method
+func (m *MockPacker) PackDatagram(arg0 *wire.DatagramFrame) (*packedPacket, error) {
+	ret := m.ctrl.Call(m, "PackDatagram", arg0)
+	ret0, _ := ret[0].(*packedPacket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PackDatagram indicates an expected call of PackDatagram
+func (mr *MockPackerMockRecorder) PackDatagram(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackDatagram", reflect.TypeOf((*MockPacker)(nil).PackDatagram), arg0)
+}
+
 // PackPacket mocks base method
 func (m *MockPacker) PackPacket() (*packedPacket, error) {
 	ret := m.ctrl.Call(m, "PackPacket")
@@ -96,6 +121,19 @@ func (mr *MockPackerMockRecorder) PackPacket() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackPacket", reflect.TypeOf((*MockPacker)(nil).PackPacket))
 }
 
+// PackPathChallenge mocks base method
+func (m *MockPacker) PackPathChallenge(arg0 *wire.PathChallengeFrame) (*packedPacket, error) {
+	ret := m.ctrl.Call(m, "PackPathChallenge", arg0)
+	ret0, _ := ret[0].(*packedPacket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PackPathChallenge indicates an expected call of PackPathChallenge
+func (mr *MockPackerMockRecorder) PackPathChallenge(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PackPathChallenge", reflect.TypeOf((*MockPacker)(nil).PackPathChallenge), arg0)
+}
+
 // PackRetransmission mocks base method
 func (m *MockPacker) PackRetransmission(arg0 *ackhandler.Packet) ([]*packedPacket, error) {
 	ret := m.ctrl.Call(m, "PackRetransmission", arg0)
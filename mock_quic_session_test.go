@@ -8,10 +8,12 @@ import (
 	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	handshake "github.com/lucas-clemente/quic-go/internal/handshake"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
+	qerr "github.com/lucas-clemente/quic-go/qerr"
 )
 
 // MockQuicSession is a mock of QuicSession interface
@@ -63,6 +65,18 @@ func (mr *MockQuicSessionMockRecorder) AcceptUniStream() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockQuicSession)(nil).AcceptUniStream))
 }
 
+// BytesInFlight mocks base method
+func (m *MockQuicSession) BytesInFlight() protocol.ByteCount {
+	ret := m.ctrl.Call(m, "BytesInFlight")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// BytesInFlight indicates an expected call of BytesInFlight
+func (mr *MockQuicSessionMockRecorder) BytesInFlight() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BytesInFlight", reflect.TypeOf((*MockQuicSession)(nil).BytesInFlight))
+}
+
 // Close mocks base method
 func (m *MockQuicSession) Close() error {
 	ret := m.ctrl.Call(m, "Close")
@@ -75,6 +89,19 @@ func (mr *MockQuicSessionMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockQuicSession)(nil).Close))
 }
 
+// CloseReason mocks base method
+func (m *MockQuicSession) CloseReason() (qerr.ErrorCode, string) {
+	ret := m.ctrl.Call(m, "CloseReason")
+	ret0, _ := ret[0].(qerr.ErrorCode)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// CloseReason indicates an expected call of CloseReason
+func (mr *MockQuicSessionMockRecorder) CloseReason() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseReason", reflect.TypeOf((*MockQuicSession)(nil).CloseReason))
+}
+
 // CloseWithError mocks base method
 func (m *MockQuicSession) CloseWithError(arg0 protocol.ApplicationErrorCode, arg1 error) error {
 	ret := m.ctrl.Call(m, "CloseWithError", arg0, arg1)
@@ -99,6 +126,18 @@ func (mr *MockQuicSessionMockRecorder) ConnectionState() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectionState", reflect.TypeOf((*MockQuicSession)(nil).ConnectionState))
 }
 
+// ConnectionParameters mocks base method
+func (m *MockQuicSession) ConnectionParameters() ConnectionParameters {
+	ret := m.ctrl.Call(m, "ConnectionParameters")
+	ret0, _ := ret[0].(ConnectionParameters)
+	return ret0
+}
+
+// ConnectionParameters indicates an expected call of ConnectionParameters
+func (mr *MockQuicSessionMockRecorder) ConnectionParameters() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectionParameters", reflect.TypeOf((*MockQuicSession)(nil).ConnectionParameters))
+}
+
 // Context mocks base method
 func (m *MockQuicSession) Context() context.Context {
 	ret := m.ctrl.Call(m, "Context")
@@ -187,6 +226,18 @@ func (mr *MockQuicSessionMockRecorder) OpenUniStreamSync() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockQuicSession)(nil).OpenUniStreamSync))
 }
 
+// PeerGoneAway mocks base method
+func (m *MockQuicSession) PeerGoneAway() bool {
+	ret := m.ctrl.Call(m, "PeerGoneAway")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// PeerGoneAway indicates an expected call of PeerGoneAway
+func (mr *MockQuicSessionMockRecorder) PeerGoneAway() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeerGoneAway", reflect.TypeOf((*MockQuicSession)(nil).PeerGoneAway))
+}
+
 // RemoteAddr mocks base method
 func (m *MockQuicSession) RemoteAddr() net.Addr {
 	ret := m.ctrl.Call(m, "RemoteAddr")
@@ -199,6 +250,62 @@ func (mr *MockQuicSessionMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockQuicSession)(nil).RemoteAddr))
 }
 
+// RTTStats mocks base method
+func (m *MockQuicSession) RTTStats() RTTStats {
+	ret := m.ctrl.Call(m, "RTTStats")
+	ret0, _ := ret[0].(RTTStats)
+	return ret0
+}
+
+// RTTStats indicates an expected call of RTTStats
+func (mr *MockQuicSessionMockRecorder) RTTStats() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RTTStats", reflect.TypeOf((*MockQuicSession)(nil).RTTStats))
+}
+
+// SendDatagram mocks base method
+func (m *MockQuicSession) SendDatagram(arg0 []byte) error {
+	ret := m.ctrl.Call(m, "SendDatagram", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendDatagram indicates an expected call of SendDatagram
+func (mr *MockQuicSessionMockRecorder) SendDatagram(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendDatagram", reflect.TypeOf((*MockQuicSession)(nil).SendDatagram), arg0)
+}
+
+// SetConnectionDeadline mocks base method
+func (m *MockQuicSession) SetConnectionDeadline(arg0 time.Time) {
+	m.ctrl.Call(m, "SetConnectionDeadline", arg0)
+}
+
+// SetConnectionDeadline indicates an expected call of SetConnectionDeadline
+func (mr *MockQuicSessionMockRecorder) SetConnectionDeadline(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetConnectionDeadline", reflect.TypeOf((*MockQuicSession)(nil).SetConnectionDeadline), arg0)
+}
+
+// SetStreamPriority mocks base method
+func (m *MockQuicSession) SetStreamPriority(arg0 protocol.StreamID, arg1 byte) {
+	m.ctrl.Call(m, "SetStreamPriority", arg0, arg1)
+}
+
+// SetStreamPriority indicates an expected call of SetStreamPriority
+func (mr *MockQuicSessionMockRecorder) SetStreamPriority(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStreamPriority", reflect.TypeOf((*MockQuicSession)(nil).SetStreamPriority), arg0, arg1)
+}
+
+// UpdateKeys mocks base method
+func (m *MockQuicSession) UpdateKeys() error {
+	ret := m.ctrl.Call(m, "UpdateKeys")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateKeys indicates an expected call of UpdateKeys
+func (mr *MockQuicSessionMockRecorder) UpdateKeys() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKeys", reflect.TypeOf((*MockQuicSession)(nil).UpdateKeys))
+}
+
 // closeRemote mocks base method
 func (m *MockQuicSession) closeRemote(arg0 error) {
 	m.ctrl.Call(m, "closeRemote", arg0)
@@ -240,3 +347,15 @@ func (m *MockQuicSession) run() error {
 func (mr *MockQuicSessionMockRecorder) run() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "run", reflect.TypeOf((*MockQuicSession)(nil).run))
 }
+
+// runContext mocks base method
+func (m *MockQuicSession) runContext(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "runContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// runContext indicates an expected call of runContext
+func (mr *MockQuicSessionMockRecorder) runContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "runContext", reflect.TypeOf((*MockQuicSession)(nil).runContext), arg0)
+}
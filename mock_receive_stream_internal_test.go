@@ -48,6 +48,18 @@ func (mr *MockReceiveStreamIMockRecorder) CancelRead(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRead", reflect.TypeOf((*MockReceiveStreamI)(nil).CancelRead), arg0)
 }
 
+// EncryptionLevel mocks base method
+func (m *MockReceiveStreamI) EncryptionLevel() protocol.EncryptionLevel {
+	ret := m.ctrl.Call(m, "EncryptionLevel")
+	ret0, _ := ret[0].(protocol.EncryptionLevel)
+	return ret0
+}
+
+// EncryptionLevel indicates an expected call of EncryptionLevel
+func (mr *MockReceiveStreamIMockRecorder) EncryptionLevel() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EncryptionLevel", reflect.TypeOf((*MockReceiveStreamI)(nil).EncryptionLevel))
+}
+
 // Read mocks base method
 func (m *MockReceiveStreamI) Read(arg0 []byte) (int, error) {
 	ret := m.ctrl.Call(m, "Read", arg0)
@@ -85,6 +97,18 @@ func (mr *MockReceiveStreamIMockRecorder) StreamID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamID", reflect.TypeOf((*MockReceiveStreamI)(nil).StreamID))
 }
 
+// closeError mocks base method
+func (m *MockReceiveStreamI) closeError() error {
+	ret := m.ctrl.Call(m, "closeError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// closeError indicates an expected call of closeError
+func (mr *MockReceiveStreamIMockRecorder) closeError() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "closeError", reflect.TypeOf((*MockReceiveStreamI)(nil).closeError))
+}
+
 // closeForShutdown mocks base method
 func (m *MockReceiveStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.Call(m, "closeForShutdown", arg0)
@@ -130,3 +154,13 @@ func (m *MockReceiveStreamI) handleStreamFrame(arg0 *wire.StreamFrame) error {
 func (mr *MockReceiveStreamIMockRecorder) handleStreamFrame(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handleStreamFrame", reflect.TypeOf((*MockReceiveStreamI)(nil).handleStreamFrame), arg0)
 }
+
+// setEncryptionLevel mocks base method
+func (m *MockReceiveStreamI) setEncryptionLevel(arg0 protocol.EncryptionLevel) {
+	m.ctrl.Call(m, "setEncryptionLevel", arg0)
+}
+
+// setEncryptionLevel indicates an expected call of setEncryptionLevel
+func (mr *MockReceiveStreamIMockRecorder) setEncryptionLevel(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "setEncryptionLevel", reflect.TypeOf((*MockReceiveStreamI)(nil).setEncryptionLevel), arg0)
+}
@@ -73,6 +73,18 @@ func (mr *MockSendStreamIMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockSendStreamI)(nil).Context))
 }
 
+// Flush mocks base method
+func (m *MockSendStreamI) Flush() error {
+	ret := m.ctrl.Call(m, "Flush")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Flush indicates an expected call of Flush
+func (mr *MockSendStreamIMockRecorder) Flush() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flush", reflect.TypeOf((*MockSendStreamI)(nil).Flush))
+}
+
 // SetWriteDeadline mocks base method
 func (m *MockSendStreamI) SetWriteDeadline(arg0 time.Time) error {
 	ret := m.ctrl.Call(m, "SetWriteDeadline", arg0)
@@ -110,6 +122,18 @@ func (mr *MockSendStreamIMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendStreamI)(nil).Write), arg0)
 }
 
+// closeError mocks base method
+func (m *MockSendStreamI) closeError() error {
+	ret := m.ctrl.Call(m, "closeError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// closeError indicates an expected call of closeError
+func (mr *MockSendStreamIMockRecorder) closeError() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "closeError", reflect.TypeOf((*MockSendStreamI)(nil).closeError))
+}
+
 // closeForShutdown mocks base method
 func (m *MockSendStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.Call(m, "closeForShutdown", arg0)
@@ -164,3 +188,15 @@ func (m *MockSendStreamI) popStreamFrame(arg0 protocol.ByteCount) (*wire.StreamF
 func (mr *MockSendStreamIMockRecorder) popStreamFrame(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "popStreamFrame", reflect.TypeOf((*MockSendStreamI)(nil).popStreamFrame), arg0)
 }
+
+// writeCanceled mocks base method
+func (m *MockSendStreamI) writeCanceled() bool {
+	ret := m.ctrl.Call(m, "writeCanceled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// writeCanceled indicates an expected call of writeCanceled
+func (mr *MockSendStreamIMockRecorder) writeCanceled() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "writeCanceled", reflect.TypeOf((*MockSendStreamI)(nil).writeCanceled))
+}
@@ -85,6 +85,30 @@ func (mr *MockStreamIMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockStreamI)(nil).Context))
 }
 
+// EncryptionLevel mocks base method
+func (m *MockStreamI) EncryptionLevel() protocol.EncryptionLevel {
+	ret := m.ctrl.Call(m, "EncryptionLevel")
+	ret0, _ := ret[0].(protocol.EncryptionLevel)
+	return ret0
+}
+
+// EncryptionLevel indicates an expected call of EncryptionLevel
+func (mr *MockStreamIMockRecorder) EncryptionLevel() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EncryptionLevel", reflect.TypeOf((*MockStreamI)(nil).EncryptionLevel))
+}
+
+// Flush mocks base method
+func (m *MockStreamI) Flush() error {
+	ret := m.ctrl.Call(m, "Flush")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Flush indicates an expected call of Flush
+func (mr *MockStreamIMockRecorder) Flush() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flush", reflect.TypeOf((*MockStreamI)(nil).Flush))
+}
+
 // Read mocks base method
 func (m *MockStreamI) Read(arg0 []byte) (int, error) {
 	ret := m.ctrl.Call(m, "Read", arg0)
@@ -159,6 +183,18 @@ func (mr *MockStreamIMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockStreamI)(nil).Write), arg0)
 }
 
+// closeError mocks base method
+func (m *MockStreamI) closeError() error {
+	ret := m.ctrl.Call(m, "closeError")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// closeError indicates an expected call of closeError
+func (mr *MockStreamIMockRecorder) closeError() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "closeError", reflect.TypeOf((*MockStreamI)(nil).closeError))
+}
+
 // closeForShutdown mocks base method
 func (m *MockStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.Call(m, "closeForShutdown", arg0)
@@ -249,3 +285,25 @@ func (m *MockStreamI) popStreamFrame(arg0 protocol.ByteCount) (*wire.StreamFrame
 func (mr *MockStreamIMockRecorder) popStreamFrame(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "popStreamFrame", reflect.TypeOf((*MockStreamI)(nil).popStreamFrame), arg0)
 }
+
+// setEncryptionLevel mocks base method
+func (m *MockStreamI) setEncryptionLevel(arg0 protocol.EncryptionLevel) {
+	m.ctrl.Call(m, "setEncryptionLevel", arg0)
+}
+
+// setEncryptionLevel indicates an expected call of setEncryptionLevel
+func (mr *MockStreamIMockRecorder) setEncryptionLevel(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "setEncryptionLevel", reflect.TypeOf((*MockStreamI)(nil).setEncryptionLevel), arg0)
+}
+
+// writeCanceled mocks base method
+func (m *MockStreamI) writeCanceled() bool {
+	ret := m.ctrl.Call(m, "writeCanceled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// writeCanceled indicates an expected call of writeCanceled
+func (mr *MockStreamIMockRecorder) writeCanceled() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "writeCanceled", reflect.TypeOf((*MockStreamI)(nil).writeCanceled))
+}
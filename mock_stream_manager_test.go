@@ -62,6 +62,18 @@ func (mr *MockStreamManagerMockRecorder) AcceptUniStream() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockStreamManager)(nil).AcceptUniStream))
 }
 
+// CloseStreamsAbove mocks base method
+func (m *MockStreamManager) CloseStreamsAbove(arg0 protocol.StreamID, arg1 error) []protocol.StreamID {
+	ret := m.ctrl.Call(m, "CloseStreamsAbove", arg0, arg1)
+	ret0, _ := ret[0].([]protocol.StreamID)
+	return ret0
+}
+
+// CloseStreamsAbove indicates an expected call of CloseStreamsAbove
+func (mr *MockStreamManagerMockRecorder) CloseStreamsAbove(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseStreamsAbove", reflect.TypeOf((*MockStreamManager)(nil).CloseStreamsAbove), arg0, arg1)
+}
+
 // CloseWithError mocks base method
 func (m *MockStreamManager) CloseWithError(arg0 error) {
 	m.ctrl.Call(m, "CloseWithError", arg0)
@@ -110,6 +122,18 @@ func (mr *MockStreamManagerMockRecorder) GetOrOpenSendStream(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrOpenSendStream", reflect.TypeOf((*MockStreamManager)(nil).GetOrOpenSendStream), arg0)
 }
 
+// GetSendStream mocks base method
+func (m *MockStreamManager) GetSendStream(arg0 protocol.StreamID) sendStreamI {
+	ret := m.ctrl.Call(m, "GetSendStream", arg0)
+	ret0, _ := ret[0].(sendStreamI)
+	return ret0
+}
+
+// GetSendStream indicates an expected call of GetSendStream
+func (mr *MockStreamManagerMockRecorder) GetSendStream(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSendStream", reflect.TypeOf((*MockStreamManager)(nil).GetSendStream), arg0)
+}
+
 // HandleMaxStreamIDFrame mocks base method
 func (m *MockStreamManager) HandleMaxStreamIDFrame(arg0 *wire.MaxStreamIDFrame) error {
 	ret := m.ctrl.Call(m, "HandleMaxStreamIDFrame", arg0)
@@ -35,6 +35,30 @@ func (m *MockStreamSender) EXPECT() *MockStreamSenderMockRecorder {
 	return m.recorder
 }
 
+// coalescesWrites mocks base method
+func (m *MockStreamSender) coalescesWrites() bool {
+	ret := m.ctrl.Call(m, "coalescesWrites")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// coalescesWrites indicates an expected call of coalescesWrites
+func (mr *MockStreamSenderMockRecorder) coalescesWrites() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "coalescesWrites", reflect.TypeOf((*MockStreamSender)(nil).coalescesWrites))
+}
+
+// flush mocks base method
+func (m *MockStreamSender) flush() <-chan struct{} {
+	ret := m.ctrl.Call(m, "flush")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// flush indicates an expected call of flush
+func (mr *MockStreamSenderMockRecorder) flush() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "flush", reflect.TypeOf((*MockStreamSender)(nil).flush))
+}
+
 // onHasStreamData mocks base method
 func (m *MockStreamSender) onHasStreamData(arg0 protocol.StreamID) {
 	m.ctrl.Call(m, "onHasStreamData", arg0)
@@ -19,6 +19,9 @@ type packer interface {
 	MaybePackAckPacket() (*packedPacket, error)
 	PackRetransmission(packet *ackhandler.Packet) ([]*packedPacket, error)
 	PackConnectionClose(*wire.ConnectionCloseFrame) (*packedPacket, error)
+	PackPathChallenge(*wire.PathChallengeFrame) (*packedPacket, error)
+	PackDatagram(*wire.DatagramFrame) (*packedPacket, error)
+	MaxDatagramSize() protocol.ByteCount
 
 	HandleTransportParameters(*handshake.TransportParameters)
 	ChangeDestConnectionID(protocol.ConnectionID)
@@ -59,12 +62,60 @@ func getMaxPacketSize(addr net.Addr) protocol.ByteCount {
 	return maxSize
 }
 
+// validateMaxPacketSize checks the MaxPacketSize set in the quic.Config.
+// Packets are written to and read into buffers of MaxReceivePacketSize, so larger packets are not supported.
+func validateMaxPacketSize(size protocol.ByteCount) error {
+	if size == 0 {
+		return nil
+	}
+	if size < protocol.MinInitialPacketSize || size > protocol.MaxReceivePacketSize {
+		return fmt.Errorf("invalid MaxPacketSize: %d (must be between %d and %d)", size, protocol.MinInitialPacketSize, protocol.MaxReceivePacketSize)
+	}
+	return nil
+}
+
+// validatePacketNumberLength checks the PacketNumberLength set in the quic.Config.
+func validatePacketNumberLength(l int) error {
+	switch protocol.PacketNumberLen(l) {
+	case 0, protocol.PacketNumberLen1, protocol.PacketNumberLen2, protocol.PacketNumberLen4:
+		return nil
+	default:
+		return fmt.Errorf("invalid PacketNumberLength: %d (must be 1, 2 or 4)", l)
+	}
+}
+
+// maxAEADOverhead is the largest overhead of the AEADs used for forward-secure packets
+const maxAEADOverhead = 16
+
+var errDatagramBeforeHandshake = errors.New("DATAGRAM frames can only be sent after the handshake completed")
+
+// maxDatagramSize calculates the maximum size of the data of a DATAGRAM frame sent in a packet of its own.
+// The packet number length and the sealer might change before the packet is sent,
+// so this uses the longest packet number and the largest AEAD overhead.
+func maxDatagramSize(maxPacketSize protocol.ByteCount, header *wire.Header, version protocol.VersionNumber) protocol.ByteCount {
+	header.PacketNumberLen = protocol.PacketNumberLen4
+	headerLen, err := header.GetLength(version)
+	if err != nil {
+		return 0
+	}
+	overhead := headerLen + maxAEADOverhead + (&wire.DatagramFrame{}).Length(version)
+	if maxPacketSize <= overhead {
+		return 0
+	}
+	return maxPacketSize - overhead
+}
+
 type sealingManager interface {
 	GetSealer() (protocol.EncryptionLevel, handshake.Sealer)
 	GetSealerForCryptoStream() (protocol.EncryptionLevel, handshake.Sealer)
 	GetSealerWithEncryptionLevel(protocol.EncryptionLevel) (handshake.Sealer, error)
 }
 
+// keyPhaser is implemented by the sealer used for 1-RTT packets in IETF QUIC
+type keyPhaser interface {
+	KeyPhase() int
+}
+
 type frameSource interface {
 	AppendStreamFrames([]wire.Frame, protocol.ByteCount) []wire.Frame
 	AppendControlFrames([]wire.Frame, protocol.ByteCount) ([]wire.Frame, protocol.ByteCount)
@@ -103,7 +154,7 @@ func newPacketPacker(
 	srcConnID protocol.ConnectionID,
 	initialPacketNumber protocol.PacketNumber,
 	getPacketNumberLen func(protocol.PacketNumber) protocol.PacketNumberLen,
-	remoteAddr net.Addr, // only used for determining the max packet size
+	maxPacketSize protocol.ByteCount,
 	token []byte,
 	cryptoStream cryptoStream,
 	cryptoSetup sealingManager,
@@ -124,7 +175,7 @@ func newPacketPacker(
 		acks:                  acks,
 		getPacketNumberLen:    getPacketNumberLen,
 		packetNumberGenerator: newPacketNumberGenerator(initialPacketNumber, protocol.SkipPacketAveragePeriodLength),
-		maxPacketSize:         getMaxPacketSize(remoteAddr),
+		maxPacketSize:         maxPacketSize,
 	}
 }
 
@@ -142,6 +193,42 @@ func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame) (*pac
 	}, err
 }
 
+// PackPathChallenge packs a packet that ONLY contains a PathChallengeFrame
+func (p *packetPacker) PackPathChallenge(pcf *wire.PathChallengeFrame) (*packedPacket, error) {
+	frames := []wire.Frame{pcf}
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	header := p.getHeader(encLevel)
+	raw, err := p.writeAndSealPacket(header, frames, sealer)
+	return &packedPacket{
+		header:          header,
+		raw:             raw,
+		frames:          frames,
+		encryptionLevel: encLevel,
+	}, err
+}
+
+// PackDatagram packs a packet that ONLY contains a DatagramFrame
+func (p *packetPacker) PackDatagram(f *wire.DatagramFrame) (*packedPacket, error) {
+	frames := []wire.Frame{f}
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	if encLevel != protocol.EncryptionForwardSecure {
+		return nil, errDatagramBeforeHandshake
+	}
+	header := p.getHeader(encLevel)
+	raw, err := p.writeAndSealPacket(header, frames, sealer)
+	return &packedPacket{
+		header:          header,
+		raw:             raw,
+		frames:          frames,
+		encryptionLevel: encLevel,
+	}, err
+}
+
+// MaxDatagramSize returns the maximum size of the data of a DATAGRAM frame
+func (p *packetPacker) MaxDatagramSize() protocol.ByteCount {
+	return maxDatagramSize(p.maxPacketSize, p.getHeader(protocol.EncryptionForwardSecure), p.version)
+}
+
 func (p *packetPacker) MaybePackAckPacket() (*packedPacket, error) {
 	ack := p.acks.GetAckFrame()
 	if ack == nil {
@@ -427,6 +514,8 @@ func (p *packetPacker) writeAndSealPacket(
 			}
 			header.PayloadLen = payloadLen
 		}
+	} else if kp, ok := sealer.(keyPhaser); ok {
+		header.KeyPhase = kp.KeyPhase()
 	}
 
 	if err := header.Write(buffer, p.perspective, p.version); err != nil {
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"net"
 
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
@@ -48,7 +47,7 @@ func newPacketPackerLegacy(
 	destConnID protocol.ConnectionID,
 	srcConnID protocol.ConnectionID,
 	getPacketNumberLen func(protocol.PacketNumber) protocol.PacketNumberLen,
-	remoteAddr net.Addr, // only used for determining the max packet size
+	maxPacketSize protocol.ByteCount,
 	divNonce []byte,
 	cryptoStream cryptoStream,
 	cryptoSetup sealingManager,
@@ -69,7 +68,7 @@ func newPacketPackerLegacy(
 		acks:                  acks,
 		getPacketNumberLen:    getPacketNumberLen,
 		packetNumberGenerator: newPacketNumberGenerator(1, protocol.SkipPacketAveragePeriodLength),
-		maxPacketSize:         getMaxPacketSize(remoteAddr),
+		maxPacketSize:         maxPacketSize,
 	}
 }
 
@@ -87,6 +86,33 @@ func (p *packetPackerLegacy) PackConnectionClose(ccf *wire.ConnectionCloseFrame)
 	}, err
 }
 
+// PackPathChallenge is not supported, gQUIC doesn't have PATH_CHALLENGE frames
+func (p *packetPackerLegacy) PackPathChallenge(*wire.PathChallengeFrame) (*packedPacket, error) {
+	return nil, errors.New("gQUIC doesn't support PATH_CHALLENGE frames")
+}
+
+// PackDatagram packs a packet that ONLY contains a DatagramFrame
+func (p *packetPackerLegacy) PackDatagram(f *wire.DatagramFrame) (*packedPacket, error) {
+	frames := []wire.Frame{f}
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	if encLevel != protocol.EncryptionForwardSecure {
+		return nil, errDatagramBeforeHandshake
+	}
+	header := p.getHeader(encLevel)
+	raw, err := p.writeAndSealPacket(header, frames, sealer)
+	return &packedPacket{
+		header:          header,
+		raw:             raw,
+		frames:          frames,
+		encryptionLevel: encLevel,
+	}, err
+}
+
+// MaxDatagramSize returns the maximum size of the data of a DATAGRAM frame
+func (p *packetPackerLegacy) MaxDatagramSize() protocol.ByteCount {
+	return maxDatagramSize(p.maxPacketSize, p.getHeader(protocol.EncryptionForwardSecure), p.version)
+}
+
 func (p *packetPackerLegacy) MaybePackAckPacket() (*packedPacket, error) {
 	ack := p.acks.GetAckFrame()
 	if ack == nil {
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
-	"net"
 
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
@@ -65,7 +64,7 @@ var _ = Describe("Packet packer (legacy)", func() {
 			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
 			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
 			func(protocol.PacketNumber) protocol.PacketNumberLen { return protocol.PacketNumberLen2 },
-			&net.TCPAddr{},
+			protocol.MinInitialPacketSize,
 			divNonce,
 			cryptoStream,
 			sealingManager,
@@ -256,6 +255,33 @@ var _ = Describe("Packet packer (legacy)", func() {
 		Expect(p.frames).To(Equal([]wire.Frame{ccf}))
 	})
 
+	Context("DATAGRAM frames", func() {
+		It("packs a DATAGRAM frame", func() {
+			f := &wire.DatagramFrame{Data: []byte("foobar")}
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
+			p, err := packer.PackDatagram(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.frames).To(Equal([]wire.Frame{f}))
+			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
+		})
+
+		It("doesn't pack DATAGRAM frames before the handshake completes", func() {
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionSecure, sealer)
+			_, err := packer.PackDatagram(&wire.DatagramFrame{Data: []byte("foobar")})
+			Expect(err).To(MatchError(errDatagramBeforeHandshake))
+		})
+
+		It("calculates the maximum size of a DATAGRAM frame", func() {
+			headerLen := protocol.ByteCount(1 /* public flags */ + 8 /* connection ID */ + 4 /* packet number */)
+			maxSize := packer.MaxDatagramSize()
+			Expect(maxSize).To(Equal(maxPacketSize - headerLen - maxAEADOverhead - 1))
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
+			p, err := packer.PackDatagram(&wire.DatagramFrame{Data: make([]byte, maxSize)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(protocol.ByteCount(len(p.raw))).To(BeNumerically("<=", maxPacketSize))
+		})
+	})
+
 	It("packs control frames", func() {
 		sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
 		cryptoStream.EXPECT().hasData()
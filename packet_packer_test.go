@@ -15,6 +15,13 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type testKeyPhaseSealer struct {
+	handshake.Sealer
+	keyPhase int
+}
+
+func (s *testKeyPhaseSealer) KeyPhase() int { return s.keyPhase }
+
 var _ = Describe("Packet packer", func() {
 	const maxPacketSize protocol.ByteCount = 1357
 	var (
@@ -74,7 +81,7 @@ var _ = Describe("Packet packer", func() {
 			protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
 			1,
 			func(protocol.PacketNumber) protocol.PacketNumberLen { return protocol.PacketNumberLen2 },
-			&net.TCPAddr{},
+			protocol.MinInitialPacketSize,
 			token, // token
 			cryptoStream,
 			sealingManager,
@@ -214,6 +221,19 @@ var _ = Describe("Packet packer", func() {
 		Expect(p.frames[0]).To(Equal(&ccf))
 	})
 
+	It("sets the key phase for 1-RTT packets", func() {
+		sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, &testKeyPhaseSealer{Sealer: sealer, keyPhase: 1})
+		p, err := packer.PackConnectionClose(&wire.ConnectionCloseFrame{ErrorCode: 0x1337})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.header.KeyPhase).To(Equal(1))
+		r := bytes.NewReader(p.raw)
+		iHdr, err := wire.ParseInvariantHeader(r, 8)
+		Expect(err).ToNot(HaveOccurred())
+		hdr, err := iHdr.Parse(r, protocol.PerspectiveServer, versionIETFFrames)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hdr.KeyPhase).To(Equal(1))
+	})
+
 	It("doesn't send any other frames when sending a CONNECTION_CLOSE", func() {
 		// expect no framer.PopStreamFrames
 		ccf := &wire.ConnectionCloseFrame{
@@ -226,6 +246,33 @@ var _ = Describe("Packet packer", func() {
 		Expect(p.frames).To(Equal([]wire.Frame{ccf}))
 	})
 
+	Context("DATAGRAM frames", func() {
+		It("packs a DATAGRAM frame", func() {
+			f := &wire.DatagramFrame{Data: []byte("foobar")}
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
+			p, err := packer.PackDatagram(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.frames).To(Equal([]wire.Frame{f}))
+			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
+		})
+
+		It("doesn't pack DATAGRAM frames before the handshake completes", func() {
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionSecure, sealer)
+			_, err := packer.PackDatagram(&wire.DatagramFrame{Data: []byte("foobar")})
+			Expect(err).To(MatchError(errDatagramBeforeHandshake))
+		})
+
+		It("calculates the maximum size of a DATAGRAM frame", func() {
+			headerLen := protocol.ByteCount(1 /* type byte */ + 8 /* connection ID */ + 4 /* packet number */)
+			maxSize := packer.MaxDatagramSize()
+			Expect(maxSize).To(Equal(maxPacketSize - headerLen - maxAEADOverhead - 1))
+			sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
+			p, err := packer.PackDatagram(&wire.DatagramFrame{Data: make([]byte, maxSize)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(protocol.ByteCount(len(p.raw))).To(BeNumerically("<=", maxPacketSize))
+		})
+	})
+
 	It("packs control frames", func() {
 		sealingManager.EXPECT().GetSealer().Return(protocol.EncryptionForwardSecure, sealer)
 		cryptoStream.EXPECT().hasData()
@@ -52,6 +52,16 @@ var _ = Describe("Packet Unpacker (for gQUIC)", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(packet.frames).To(Equal([]wire.Frame{&wire.PingFrame{}, &wire.BlockedFrame{}}))
 	})
+
+	It("errors on unknown frame types", func() {
+		// gQUIC frames don't carry their length, so the STREAM frame following the unknown frame can't be parsed
+		buf := &bytes.Buffer{}
+		buf.Write([]byte{0x1f, 0x13, 0x37}) // unknown frame type
+		Expect((&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}).Write(buf, versionGQUICFrames)).To(Succeed())
+		aead.EXPECT().Open(gomock.Any(), gomock.Any(), hdr.PacketNumber, hdr.Raw).Return(buf.Bytes(), protocol.EncryptionForwardSecure, nil)
+		_, err := unpacker.Unpack(hdr.Raw, hdr, nil)
+		Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0x1f"))
+	})
 })
 
 var _ = Describe("Packet Unpacker (for IETF QUIC)", func() {
@@ -73,7 +83,7 @@ var _ = Describe("Packet Unpacker (for IETF QUIC)", func() {
 
 	It("errors if the packet doesn't contain any payload", func() {
 		data := []byte("foobar")
-		aead.EXPECT().Open1RTT(gomock.Any(), []byte("foobar"), hdr.PacketNumber, hdr.Raw).Return([]byte{}, nil)
+		aead.EXPECT().Open1RTT(gomock.Any(), []byte("foobar"), hdr.PacketNumber, 0, hdr.Raw).Return([]byte{}, nil)
 		_, err := unpacker.Unpack(hdr.Raw, hdr, data)
 		Expect(err).To(MatchError(qerr.MissingPayload))
 	})
@@ -90,9 +100,17 @@ var _ = Describe("Packet Unpacker (for IETF QUIC)", func() {
 		buf := &bytes.Buffer{}
 		(&wire.PingFrame{}).Write(buf, versionIETFFrames)
 		(&wire.BlockedFrame{}).Write(buf, versionIETFFrames)
-		aead.EXPECT().Open1RTT(gomock.Any(), gomock.Any(), hdr.PacketNumber, hdr.Raw).Return(buf.Bytes(), nil)
+		aead.EXPECT().Open1RTT(gomock.Any(), gomock.Any(), hdr.PacketNumber, 0, hdr.Raw).Return(buf.Bytes(), nil)
 		packet, err := unpacker.Unpack(hdr.Raw, hdr, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(packet.frames).To(Equal([]wire.Frame{&wire.PingFrame{}, &wire.BlockedFrame{}}))
 	})
+
+	It("passes the key phase to the AEAD", func() {
+		hdr.KeyPhase = 1
+		aead.EXPECT().Open1RTT(gomock.Any(), gomock.Any(), hdr.PacketNumber, 1, hdr.Raw).Return([]byte{0}, nil)
+		packet, err := unpacker.Unpack(hdr.Raw, hdr, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
+	})
 })
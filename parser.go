@@ -2,59 +2,1180 @@ package quic
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
 	"io"
+	"sort"
 )
 
+// errSNISpansFrames ：SNI 不完整地位于同一个 STREAM 帧中，无法原地改写
+var errSNISpansFrames = errors.New("SNI spans a frame boundary, it can't be rewritten in place")
+
+// errHandshakeMessageTruncated ：握手消息的标签表不完整
+var errHandshakeMessageTruncated = errors.New("handshake message truncated")
+
+// errTooManyFrames ：数据包中的帧数超过 ParserOptions.MaxFrames
+var errTooManyFrames = errors.New("too many frames")
+
+// ErrTruncatedPacket ：数据包在头部或帧声明的长度之前结束，通常是数据包被截断或者是畸形输入
+var ErrTruncatedPacket = errors.New("truncated packet")
+
+// ErrNoCHLO ：数据包中没有 CHLO
+var ErrNoCHLO = errors.New("no CHLO found")
+
+// ErrInvalidSNI ：SNI 不是合法的 DNS 主机名
+var ErrInvalidSNI = errors.New("invalid SNI")
+
+// ErrCHLOTooLarge ：拼接后的 CHLO 超过 ParserOptions.MaxReassemblyBytes
+var ErrCHLOTooLarge = errors.New("CHLO exceeds the maximum reassembly size")
+
+// errPacketTooLarge ：io.Reader 中的数据超过一个 UDP 数据包的最大长度
+var errPacketTooLarge = errors.New("packet too large")
+
+// maxHostnameLen ：DNS 主机名的最大长度，不包括末尾的点
+const maxHostnameLen = 253
+
+// maxHostnameLabelLen ：DNS 主机名中单个标签的最大长度
+const maxHostnameLabelLen = 63
+
+// DefaultMaxFrames ：解析单个数据包时默认最多遍历的帧数
+const DefaultMaxFrames = 1000
+
+// DefaultMaxReassemblyBytes ：拼接 CHLO 时默认最多分配的字节数。
+// gquic 的 CHLO 必须放在一个数据包中发送，因此合法的 CHLO 不会超过最大的数据包大小
+const DefaultMaxReassemblyBytes = int(protocol.MaxReceivePacketSize)
+
+// ParserOptions ：解析选项，零值表示全部使用默认值
+type ParserOptions struct {
+	// MaxFrames ：解析单个数据包时最多遍历的帧数，超过后放弃解析并返回错误，
+	// 用于限制精心构造的、包含大量小帧的数据包带来的开销。为 0 时使用 DefaultMaxFrames
+	MaxFrames int
+	// MaxReassemblyBytes ：拼接分布在多个 STREAM 帧中的 CHLO 时最多分配的字节数，
+	// CHLO 超过该大小时返回 ErrCHLOTooLarge。为 0 时使用 DefaultMaxReassemblyBytes
+	MaxReassemblyBytes int
+}
+
+func (o *ParserOptions) maxFrames() int {
+	if o == nil || o.MaxFrames <= 0 {
+		return DefaultMaxFrames
+	}
+	return o.MaxFrames
+}
+
+func (o *ParserOptions) maxReassemblyBytes() int {
+	if o == nil || o.MaxReassemblyBytes <= 0 {
+		return DefaultMaxReassemblyBytes
+	}
+	return o.MaxReassemblyBytes
+}
+
+// gquicVersionFECRemoved ：Q032 起移除了 FEC 以及私有头部
+const gquicVersionFECRemoved = protocol.VersionNumber(0x51303332)
+
+// 私有头部的标志位
+const (
+	privateFlagEntropy  = 0x01
+	privateFlagFECGroup = 0x02
+	privateFlagFEC      = 0x04
+)
+
+// FECGroupInfo ：gquic 数据包的 FEC 分组信息，仅 Q032 之前的版本支持
+type FECGroupInfo struct {
+	// InFECGroup ：数据包是否属于某个 FEC 分组
+	InFECGroup bool
+	// FECGroup ：FEC 分组号，即该分组中第一个数据包的包序号
+	FECGroup PacketNumber
+	// IsFECPacket ：是否为携带冗余数据的 FEC 包，否则为受保护的数据包
+	IsFECPacket bool
+}
+
+// ServerHelloInfo ：服务端发送的 REJ 或 SHLO 数据包中的信息
+type ServerHelloInfo struct {
+	// Rejected ：为 true 时是 REJ，否则是 SHLO
+	Rejected bool
+	// ConnectionID ：公共头部中的连接ID，服务端省略连接ID时为空
+	ConnectionID []byte
+	// Versions ：SHLO 的 VER 标签中服务端支持的版本列表
+	Versions []VersionNumber
+	// SNI ：握手消息中回显的 SNI，通常为空
+	SNI string
+	// ServerConfigID ：REJ 携带的服务端配置（SCFG）中的 SCID
+	ServerConfigID []byte
+}
+
+// ServerConfigInfo ：服务端发送的 REJ 中与服务端配置相关的标签的原始值，不存在的标签为 nil
+type ServerConfigInfo struct {
+	// SCFG ：服务端配置，本身也是一个握手消息
+	SCFG []byte
+	// STK ：源地址令牌
+	STK []byte
+	// SCID ：SCFG 中的服务端配置ID
+	SCID []byte
+}
+
+// CHLOCertInfo ：CHLO 中与证书相关的标签的原始值，不存在的标签为 nil
+type CHLOCertInfo struct {
+	// CCS ：客户端支持的公共证书集合的哈希
+	CCS []byte
+	// PDMD ：客户端要求的证明类型，例如 "X509"
+	PDMD []byte
+	// PROF ：证明，通常只出现在服务端的 REJ 中
+	PROF []byte
+	// CERT ：证书链，通常只出现在服务端的 REJ 中
+	CERT []byte
+}
+
+// CHLOPaddingInfo ：CHLO 的填充信息，用于分析客户端为防止放大攻击而填充的字节数
+type CHLOPaddingInfo struct {
+	// PaddingLen ：PAD 标签的值的长度，CHLO 没有填充时为 0
+	PaddingLen int
+	// CHLOLen ：完整的 CHLO 消息的长度，包括消息标签、标签表以及所有的值
+	CHLOLen int
+	// XLCT ：XLCT 标签（客户端期望的叶子证书的哈希）的原始值，不存在时为 nil
+	XLCT []byte
+}
+
+// ClientHelloInfo ：客户端发送的 CHLO 数据包中的信息，不存在的字段为零值
+type ClientHelloInfo struct {
+	// SNI ：CHLO 中的 SNI
+	SNI string
+	// ALPNs ：CHLO 的 ALPN 标签中的应用层协议，gquic 中最多只有一个
+	ALPNs []string
+	// Version ：数据包使用的 gquic 版本
+	Version VersionNumber
+	// ConnectionID ：公共头部中的连接ID
+	ConnectionID []byte
+	// Tags ：CHLO 中所有标签的原始值，未找到 CHLO 时为 nil
+	Tags map[HandshakeTag][]byte
+}
+
+// serverHelloFrameVersion ：服务端数据包的公共头部不携带版本号，用于解析帧的 gquic 版本
+const serverHelloFrameVersion = protocol.Version43
+
+// gquicConnectionIDLen ：gquic 连接ID的长度
+const gquicConnectionIDLen = 8
+
+// minGQUICPacketLen ：公共头部（标志位 + 连接ID + 版本号 + 包序号）加上 12 字节 FNV-1a 哈希的最小长度
+const minGQUICPacketLen = 20
+
 // ParseSNIFromClientHelloGQUICPacket ：解析gquic 尤其针对Q043
 // 主要参考： https://github.com/quic-go/quic-go gquic分支
+// 解析过程不使用任何包级别的可变状态，也不会修改 packet，可以在多个 goroutine 中并发调用
 func ParseSNIFromClientHelloGQUICPacket(packet []byte) (string, error) {
+	return ParseSNIFromClientHelloGQUICPacketWithOptions(packet, nil)
+}
+
+// ParseSNIFromClientHelloGQUICPacketWithOptions ：与 ParseSNIFromClientHelloGQUICPacket 相同，但使用 opts 中的解析选项，opts 可以为 nil
+func ParseSNIFromClientHelloGQUICPacketWithOptions(packet []byte, opts *ParserOptions) (string, error) {
+	info, err := InspectGQUICClientHelloWithOptions(packet, opts)
+	if err != nil {
+		return "", err
+	}
+	return info.SNI, nil
+}
+
+// ParseAndValidateSNIFromClientHelloGQUICPacket ：与 ParseSNIFromClientHelloGQUICPacket 相同，但 SNI 不是合法的 DNS 主机名时返回 ErrInvalidSNI，
+// 例如包含控制字符或空字符、标签超过 63 字节、总长度超过 253 字节。CHLO 中没有 SNI 时返回空字符串
+func ParseAndValidateSNIFromClientHelloGQUICPacket(packet []byte) (string, error) {
+	sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+	if err != nil {
+		return "", err
+	}
+	if sni != "" && !isValidHostname(sni) {
+		return "", ErrInvalidSNI
+	}
+	return sni, nil
+}
+
+// isValidHostname ：按照 RFC 1123 检查主机名，标签只能包含字母、数字和连字符，且不能以连字符开头或结尾
+func isValidHostname(name string) bool {
+	if len(name) > maxHostnameLen {
+		return false
+	}
+	labelLen := 0
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '.':
+			if labelLen == 0 || name[i-1] == '-' {
+				return false
+			}
+			labelLen = 0
+			continue
+		case c == '-':
+			if labelLen == 0 {
+				return false
+			}
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		default:
+			return false
+		}
+		labelLen++
+		if labelLen > maxHostnameLabelLen {
+			return false
+		}
+	}
+	return labelLen > 0 && name[len(name)-1] != '-'
+}
+
+// InspectGQUICClientHello ：一次解析 gquic 客户端数据包，返回 SNI、ALPN、版本号、连接ID 以及 CHLO 中所有的标签。
+// 数据包中没有 CHLO 时不返回错误，而是返回只包含公共头部信息的结果。
+func InspectGQUICClientHello(packet []byte) (*ClientHelloInfo, error) {
+	return InspectGQUICClientHelloWithOptions(packet, nil)
+}
+
+// InspectGQUICClientHelloWithOptions ：与 InspectGQUICClientHello 相同，但使用 opts 中的解析选项，opts 可以为 nil
+func InspectGQUICClientHelloWithOptions(packet []byte, opts *ParserOptions) (*ClientHelloInfo, error) {
 	// packet_handler_map.go:141 handlePacket
-	if len(packet) < 20 {
-		return "", fmt.Errorf("packet too short")
+	if len(packet) < minGQUICPacketLen {
+		return nil, fmt.Errorf("packet too short")
+	}
+	return inspectClientHelloGQUIC(bytes.NewReader(packet), opts)
+}
+
+// InspectNegotiatedGQUICClientHello ：解析同一连接中客户端按顺序发送的多个数据包（例如抓包中的一个流）。
+// 经过版本协商后，客户端会使用协商后的版本重新发送 CHLO，因此使用最后一个 CHLO 所在数据包的版本：
+// 返回该版本的数据包中第一个携带 SNI 的 CHLO，若都不携带 SNI，则返回该版本的第一个 CHLO。
+// 无法解析的数据包被忽略；所有数据包中都没有 CHLO 时返回最后一个解析错误，或者 ErrNoCHLO。
+// gquic 数据包没有长度字段，拼接在一起的数据包无法拆分，需要逐个传入。
+func InspectNegotiatedGQUICClientHello(packets [][]byte) (*ClientHelloInfo, error) {
+	var chlo *ClientHelloInfo
+	var lastErr error
+	for _, p := range packets {
+		info, err := InspectGQUICClientHello(p)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if info.Tags == nil {
+			continue
+		}
+		if chlo == nil || info.Version != chlo.Version || (chlo.SNI == "" && info.SNI != "") {
+			chlo = info
+		}
+	}
+	if chlo != nil {
+		return chlo, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNoCHLO
+}
+
+// ParseSNIFromClientHelloGQUICReader ：与 ParseSNIFromClientHelloGQUICPacket 相同，但从 io.Reader 中读取一个数据包
+// r 中的数据必须恰好是一个 UDP 数据包（最多 1452 字节），函数会一直读取到 io.EOF，
+// 因此不能用于包含多个数据包的流。
+// 若 r 本身是 *bytes.Reader，则直接在其上解析，不做任何复制；
+// 否则先读取首字节判断是否为 gquic，再将剩余部分读入一个复用的缓冲区。
+// 数据超过 1452 字节时返回错误；数据不足时返回读取错误，而不是长度错误。
+func ParseSNIFromClientHelloGQUICReader(r io.Reader) (string, error) {
+	if br, ok := r.(*bytes.Reader); ok {
+		return parseSNIFromClientHelloGQUIC(br)
 	}
-	if packet[0]&0x80 > 0 || packet[0]&0x38 == 0x30 {
+	buf := getPacketBuffer()
+	defer putPacketBuffer(buf)
+	data := (*buf)[:protocol.MaxReceivePacketSize]
+	if _, err := io.ReadFull(r, data[:1]); err != nil {
+		return "", fmt.Errorf("error reading packet: %s", err)
+	}
+	if !isGQUICPublicHeaderTypeByte(data[0]) {
 		return "", fmt.Errorf("is not gquic")
 	}
+	n, err := io.ReadFull(r, data[1:])
+	switch err {
+	case nil:
+		// the buffer is full, make sure that the packet ends here
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != io.EOF {
+			if err != nil {
+				return "", fmt.Errorf("error reading packet: %s", err)
+			}
+			return "", errPacketTooLarge
+		}
+	case io.ErrUnexpectedEOF:
+	default:
+		return "", fmt.Errorf("error reading packet: %s", err)
+	}
+	if n+1 < minGQUICPacketLen {
+		return "", fmt.Errorf("error reading packet: %s", io.ErrUnexpectedEOF)
+	}
+	return parseSNIFromClientHelloGQUIC(bytes.NewReader(data[:n+1]))
+}
+
+// ParseSNIWithVersion ：在调用方已知 gquic 版本时（例如来自同一连接之前的数据包）解析 SNI，
+// 跳过版本探测，直接按该版本的格式解析，因此也能解析不携带版本号的数据包。
+// 数据包携带的版本号与 version 不一致时返回错误。
+func ParseSNIWithVersion(packet []byte, version VersionNumber) (string, error) {
+	if !protocol.IsValidVersion(version) || version.UsesTLS() {
+		return "", fmt.Errorf("%s is not a supported gQUIC version", version)
+	}
+	if len(packet) < minGQUICPacketLen {
+		return "", fmt.Errorf("packet too short")
+	}
+	r := bytes.NewReader(packet)
+	iHdr, err := wire.ParseInvariantHeader(r, protocol.ConnectionIDLenGQUIC)
+	if isTruncated(err) {
+		return "", ErrTruncatedPacket
+	}
+	if err != nil {
+		return "", fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	if iHdr.IsLongHeader && !version.UsesIETFHeaderFormat() {
+		return "", fmt.Errorf("packet header doesn't match %s", version)
+	}
+	hdr, err := iHdr.Parse(r, protocol.PerspectiveClient, version)
+	if isTruncated(err) {
+		return "", ErrTruncatedPacket
+	}
+	if err != nil {
+		return "", fmt.Errorf("error parsing header: %s", err)
+	}
+	if hdr.Version != 0 && hdr.Version != version {
+		return "", fmt.Errorf("version mismatch: packet uses %s, expected %s", hdr.Version, version)
+	}
+	if r.Len() < 16 {
+		return "", fmt.Errorf("no frame")
+	}
+	// internal/crypto/null_aead_fnv128a.go
+	_, _ = r.Seek(12, io.SeekCurrent)
+	info, err := inspectGQUICFrames(r, hdr, version, nil)
+	if err != nil {
+		return "", err
+	}
+	return info.SNI, nil
+}
+
+// IsGQUICClientHello ：快速判断数据包是否为携带 CHLO 的 gquic 握手包，用于在调用 ParseSNI 之前过滤。
+// 只检查公共头部以及 crypto stream 上握手消息的标签，不解析标签表和标签值。
+// 对任何畸形输入都返回 false。
+func IsGQUICClientHello(packet []byte) bool {
+	if len(packet) < minGQUICPacketLen {
+		return false
+	}
+	if kind, _ := ClassifyQUICPacket(packet); kind != KindGQUICLongHeader {
+		return false
+	}
+	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return false
+	}
+	for {
+		frame, err := parseNextFrame(r, hdr, hdr.Version)
+		if err != nil || frame == nil {
+			return false
+		}
+		sf, ok := frame.(*wire.StreamFrame)
+		if !ok || sf.StreamID != hdr.Version.CryptoStreamID() || sf.Offset != 0 || len(sf.Data) < 4 {
+			continue
+		}
+		return handshake.Tag(binary.LittleEndian.Uint32(sf.Data)) == handshake.TagCHLO
+	}
+}
+
+// ParseSNISpanFromClientHelloGQUICPacket ：返回 SNI 值在原始数据包中的位置 packet[start : start+length]，
+// 便于代理原地清零或改写 SNI，而无需重新编码整个握手消息。
+// 若 SNI 跨越了 STREAM 帧的边界，返回错误。
+// 注意：改写后数据包末尾的 FNV-1a 哈希不再匹配，调用方需要自行重新计算。
+func ParseSNISpanFromClientHelloGQUICPacket(packet []byte) (start, length int, err error) {
+	if len(packet) < minGQUICPacketLen {
+		return 0, 0, fmt.Errorf("packet too short")
+	}
+	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		frame, err := parseNextFrame(r, hdr, hdr.Version)
+		if err != nil {
+			return 0, 0, err
+		}
+		if frame == nil {
+			return 0, 0, ErrNoCHLO
+		}
+		sf, ok := frame.(*wire.StreamFrame)
+		if !ok || len(sf.Data) < 4 || handshake.Tag(binary.LittleEndian.Uint32(sf.Data)) != handshake.TagCHLO {
+			continue
+		}
+		// the data is the last field of a STREAM frame
+		dataStart := len(packet) - r.Len() - len(sf.Data)
+		start, length, err := findHandshakeTagSpan(sf.Data, handshake.TagSNI)
+		if err == errHandshakeMessageTruncated {
+			return 0, 0, errSNISpansFrames
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if start < 0 {
+			return 0, 0, fmt.Errorf("CHLO doesn't contain an SNI")
+		}
+		if start+length > len(sf.Data) {
+			return 0, 0, errSNISpansFrames
+		}
+		return dataStart + start, length, nil
+	}
+}
+
+// handshakeTagIndex ：握手消息标签表的迭代器，格式参考 internal/handshake/handshake_message.go
+type handshakeTagIndex struct {
+	msg       []byte
+	indexEnd  int
+	pos       int
+	dataStart uint32
+}
+
+// newHandshakeTagIndex ：检查握手消息的标签数量，标签表不完整地位于 msg 中时返回错误
+func newHandshakeTagIndex(msg []byte) (*handshakeTagIndex, error) {
+	if len(msg) < 8 {
+		return nil, errHandshakeMessageTruncated
+	}
+	nPairs := binary.LittleEndian.Uint32(msg[4:8])
+	if nPairs > protocol.CryptoMaxParams {
+		return nil, fmt.Errorf("too many entries in handshake message: %d", nPairs)
+	}
+	indexEnd := 8 + int(nPairs)*8
+	if len(msg) < indexEnd {
+		return nil, errHandshakeMessageTruncated
+	}
+	return &handshakeTagIndex{msg: msg, indexEnd: indexEnd, pos: 8}, nil
+}
+
+// next ：返回下一个标签及其值的位置 msg[start:end]，遍历结束后 ok 为 false。
+// 偏移量递减或者值过长时返回错误，不检查值是否完整地位于 msg 中。
+func (i *handshakeTagIndex) next() (tag handshake.Tag, start, end int, ok bool, err error) {
+	if i.pos >= i.indexEnd {
+		return 0, 0, 0, false, nil
+	}
+	tag = handshake.Tag(binary.LittleEndian.Uint32(i.msg[i.pos : i.pos+4]))
+	dataEnd := binary.LittleEndian.Uint32(i.msg[i.pos+4 : i.pos+8])
+	if dataEnd < i.dataStart {
+		return 0, 0, 0, false, fmt.Errorf("invalid handshake message index")
+	}
+	// 限制值的长度，避免在 32 位平台上 int 溢出
+	if dataEnd-i.dataStart > protocol.CryptoParameterMaxLength {
+		return 0, 0, 0, false, fmt.Errorf("handshake message value too long")
+	}
+	start, end = i.indexEnd+int(i.dataStart), i.indexEnd+int(dataEnd)
+	i.pos += 8
+	i.dataStart = dataEnd
+	return tag, start, end, true, nil
+}
+
+// valuesEnd ：已遍历的标签的值在 msg 中的结束位置，遍历完整个标签表后即为握手消息的长度
+func (i *handshakeTagIndex) valuesEnd() int {
+	return i.indexEnd + int(i.dataStart)
+}
+
+// findHandshakeTagSpan ：在握手消息中查找 tag 对应值的位置（相对于 msg），不复制数据。
+// tag 不存在时 start 为 -1。
+func findHandshakeTagSpan(msg []byte, tag handshake.Tag) (start, length int, err error) {
+	index, err := newHandshakeTagIndex(msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		t, valueStart, valueEnd, ok, err := index.next()
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			return -1, 0, nil
+		}
+		if t == tag {
+			return valueStart, valueEnd - valueStart, nil
+		}
+	}
+}
+
+// HandshakeTag ：gquic 握手消息中的标签，例如 SNI 为 'S' + 'N'<<8 + 'I'<<16
+type HandshakeTag = handshake.Tag
+
+// Frame ：WalkGQUICFrames 传给回调函数的帧
+type Frame = wire.Frame
+
+// StreamFrame ：STREAM 帧，CHLO 等握手消息位于 crypto stream 的 STREAM 帧中
+type StreamFrame = wire.StreamFrame
+
+// AckFrame ：ACK 帧
+type AckFrame = wire.AckFrame
+
+// DecodeGQUICTagValues ：解码一个完整的 gquic 握手消息（以 CHLO 等消息标签开头，即 crypto stream 上的数据），
+// 不需要构造数据包。返回的值直接引用 msg，不做复制。
+// 标签数量超过上限、标签未按升序排列、偏移量递减、值过长或者数据不完整时返回错误，msg 末尾多余的数据被忽略。
+func DecodeGQUICTagValues(msg []byte) (map[HandshakeTag][]byte, error) {
+	index, err := newHandshakeTagIndex(msg)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[HandshakeTag][]byte)
+	var lastTag handshake.Tag
+	for first := true; ; first = false {
+		tag, start, end, ok, err := index.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return values, nil
+		}
+		if !first && tag <= lastTag {
+			return nil, fmt.Errorf("handshake message tags not in ascending order")
+		}
+		if end > len(msg) {
+			return nil, errHandshakeMessageTruncated
+		}
+		values[tag] = msg[start:end:end]
+		lastTag = tag
+	}
+}
+
+// handshakeTagValue ：返回握手消息中 tag 对应的值，值不完整地位于 msg 中时视为不存在
+func handshakeTagValue(msg []byte, tag handshake.Tag) ([]byte, bool, error) {
+	start, length, err := findHandshakeTagSpan(msg, tag)
+	if err != nil || start < 0 || start+length > len(msg) {
+		return nil, false, err
+	}
+	return msg[start : start+length], true, nil
+}
+
+// ParseFECGroup ：解析客户端发送的 gquic 数据包私有头部中的 FEC 分组信息，用于分析抓包中的丢包恢复行为。
+// 数据包需要携带版本号，且版本低于 Q032，否则返回错误。
+func ParseFECGroup(packet []byte) (*FECGroupInfo, error) {
+	if len(packet) < minGQUICPacketLen {
+		return nil, fmt.Errorf("packet too short")
+	}
 	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Version >= gquicVersionFECRemoved {
+		return nil, fmt.Errorf("FEC is not supported by %s", hdr.Version)
+	}
+	privateFlags, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private header: %s", err)
+	}
+	info := &FECGroupInfo{IsFECPacket: privateFlags&privateFlagFEC > 0}
+	if privateFlags&privateFlagFECGroup == 0 {
+		if info.IsFECPacket {
+			return nil, fmt.Errorf("FEC packet without FEC group")
+		}
+		return info, nil
+	}
+	offset, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private header: %s", err)
+	}
+	if PacketNumber(offset) > hdr.PacketNumber {
+		return nil, fmt.Errorf("invalid FEC group offset %d for packet number %d", offset, hdr.PacketNumber)
+	}
+	info.InFECGroup = true
+	info.FECGroup = hdr.PacketNumber - PacketNumber(offset)
+	return info, nil
+}
+
+// ParseServerHelloInfo ：解析服务端发送的携带 REJ 或 SHLO 的 gquic 数据包，与客户端方向的 ParseSNI 对应。
+// 只能解析未加密的数据包：REJ 总是未加密的，SHLO 通常使用初始密钥加密，此时返回 "no REJ or SHLO found"。
+// REJ 通常跨越多个数据包，只返回完整位于该数据包中的标签值。
+func ParseServerHelloInfo(packet []byte) (*ServerHelloInfo, error) {
+	hdr, msg, err := parseServerHelloMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	info := &ServerHelloInfo{
+		Rejected:     handshake.Tag(binary.LittleEndian.Uint32(msg)) == handshake.TagREJ,
+		ConnectionID: hdr.DestConnectionID,
+	}
+	if sni, ok, err := handshakeTagValue(msg, handshake.TagSNI); err != nil {
+		return nil, err
+	} else if ok {
+		info.SNI = string(sni)
+	}
+	ver, ok, err := handshakeTagValue(msg, handshake.TagVER)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if len(ver)%4 != 0 {
+			return nil, fmt.Errorf("invalid version list")
+		}
+		for i := 0; i < len(ver); i += 4 {
+			info.Versions = append(info.Versions, VersionNumber(binary.BigEndian.Uint32(ver[i:])))
+		}
+	}
+	scfg, ok, err := handshakeTagValue(msg, handshake.TagSCFG)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		scid, ok, err := handshakeTagValue(scfg, handshake.TagSCID)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing server config: %s", err)
+		}
+		if ok {
+			info.ServerConfigID = scid
+		}
+	}
+	return info, nil
+}
+
+// ParseServerConfigFromREJGQUICPacket ：解析服务端发送的携带 REJ 的 gquic 数据包，返回 SCFG、STK 以及 SCFG 中的 SCID，
+// 用于只有服务端到客户端方向的抓包。数据包按服务端视角解析公共头部，即不携带版本号、连接ID可以省略。
+// 与 ParseServerHelloInfo 相同，只返回完整位于该数据包中的标签值；数据包携带 SHLO 时返回 "no REJ found"。
+func ParseServerConfigFromREJGQUICPacket(packet []byte) (*ServerConfigInfo, error) {
+	_, msg, err := parseServerHelloMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	if handshake.Tag(binary.LittleEndian.Uint32(msg)) != handshake.TagREJ {
+		return nil, fmt.Errorf("no REJ found")
+	}
+	info := &ServerConfigInfo{}
+	if info.STK, _, err = handshakeTagValue(msg, handshake.TagSTK); err != nil {
+		return nil, err
+	}
+	if info.SCFG, _, err = handshakeTagValue(msg, handshake.TagSCFG); err != nil {
+		return nil, err
+	}
+	if info.SCFG != nil {
+		if info.SCID, _, err = handshakeTagValue(info.SCFG, handshake.TagSCID); err != nil {
+			return nil, fmt.Errorf("error parsing server config: %s", err)
+		}
+	}
+	return info, nil
+}
+
+// parseServerHelloMessage ：解析服务端发送的 gquic 数据包，返回公共头部以及加密流上偏移为 0 的 REJ 或 SHLO 握手消息
+func parseServerHelloMessage(packet []byte) (*wire.Header, []byte, error) {
+	if len(packet) < minGQUICPacketLen {
+		return nil, nil, fmt.Errorf("packet too short")
+	}
+	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICServerHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		frame, err := parseNextFrame(r, hdr, serverHelloFrameVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		if frame == nil {
+			return nil, nil, fmt.Errorf("no REJ or SHLO found")
+		}
+		sf, ok := frame.(*wire.StreamFrame)
+		if !ok || sf.StreamID != serverHelloFrameVersion.CryptoStreamID() || sf.Offset != 0 || len(sf.Data) < 4 {
+			continue
+		}
+		tag := handshake.Tag(binary.LittleEndian.Uint32(sf.Data))
+		if tag != handshake.TagREJ && tag != handshake.TagSHLO {
+			continue
+		}
+		return hdr, sf.Data, nil
+	}
+}
+
+// ParseCHLOCertInfoGQUICPacket ：解析 CHLO 中与证书相关的标签，用于统计客户端的能力
+func ParseCHLOCertInfoGQUICPacket(packet []byte) (*CHLOCertInfo, error) {
+	tags, err := parseCHLOFromGQUICPacket(packet)
+	if err != nil {
+		return nil, err
+	}
+	return &CHLOCertInfo{
+		CCS:  tags[handshake.TagCCS],
+		PDMD: tags[handshake.TagPDMD],
+		PROF: tags[handshake.TagPROF],
+		CERT: tags[handshake.TagCERT],
+	}, nil
+}
+
+// ParseCHLOPaddingGQUICPacket ：返回 CHLO 中 PAD 标签的长度以及 CHLO 的总长度。
+// CHLO 分布在多个 STREAM 帧中时按偏移量拼接，与 ExtractCHLOFromGQUICPacket 相同；没有 CHLO 时返回 ErrNoCHLO。
+func ParseCHLOPaddingGQUICPacket(packet []byte) (*CHLOPaddingInfo, error) {
+	chlo, err := ExtractCHLOFromGQUICPacket(packet)
+	if err != nil {
+		return nil, err
+	}
+	pad, _, err := handshakeTagValue(chlo, handshake.TagPAD)
+	if err != nil {
+		return nil, err
+	}
+	xlct, _, err := handshakeTagValue(chlo, handshake.TagXLCT)
+	if err != nil {
+		return nil, err
+	}
+	return &CHLOPaddingInfo{
+		PaddingLen: len(pad),
+		CHLOLen:    len(chlo),
+		XLCT:       xlct,
+	}, nil
+}
+
+// ParseClientVersionsFromCHLOGQUICPacket ：解析 CHLO 的 VER 标签中客户端声明的版本，用于检测版本降级：
+// 经过版本协商后，公共头部中的版本与 VER 标签中客户端最初选择的版本不一致。
+// VER 标签不存在，或者长度不是 4 的整数倍时返回错误。
+func ParseClientVersionsFromCHLOGQUICPacket(packet []byte) ([]VersionNumber, error) {
+	tags, err := parseCHLOFromGQUICPacket(packet)
+	if err != nil {
+		return nil, err
+	}
+	ver, ok := tags[handshake.TagVER]
+	if !ok {
+		return nil, fmt.Errorf("CHLO doesn't contain a version tag")
+	}
+	if len(ver) == 0 || len(ver)%4 != 0 {
+		return nil, fmt.Errorf("invalid version tag")
+	}
+	versions := make([]VersionNumber, 0, len(ver)/4)
+	for i := 0; i < len(ver); i += 4 {
+		versions = append(versions, VersionNumber(binary.BigEndian.Uint32(ver[i:])))
+	}
+	return versions, nil
+}
+
+// ExtractCHLOFromGQUICPacket ：返回数据包中 crypto stream 上的完整 CHLO 消息（消息标签、标签表以及所有的值），不解析各个标签，
+// 用于交给外部的握手解析工具。CHLO 分布在多个 STREAM 帧中时按偏移量拼接；没有 CHLO 时返回 ErrNoCHLO。
+// 返回值引用 packet，不做复制（除非需要拼接）。
+func ExtractCHLOFromGQUICPacket(packet []byte) ([]byte, error) {
+	return ExtractCHLOFromGQUICPacketWithOptions(packet, nil)
+}
+
+// ExtractCHLOFromGQUICPacketWithOptions ：与 ExtractCHLOFromGQUICPacket 相同，但使用 opts 中的解析选项，opts 可以为 nil
+func ExtractCHLOFromGQUICPacketWithOptions(packet []byte, opts *ParserOptions) ([]byte, error) {
+	if len(packet) < minGQUICPacketLen {
+		return nil, fmt.Errorf("packet too short")
+	}
+	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	maxFrames := opts.maxFrames()
+	var frames []*wire.StreamFrame
+	for i := 0; ; i++ {
+		frame, err := parseNextFrame(r, hdr, hdr.Version)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			break
+		}
+		if i == maxFrames {
+			return nil, errTooManyFrames
+		}
+		if sf, ok := frame.(*wire.StreamFrame); ok && sf.StreamID == hdr.Version.CryptoStreamID() {
+			frames = append(frames, sf)
+		}
+	}
+	data, capped := assembleStreamData(frames, opts.maxReassemblyBytes())
+	if len(data) < 4 || handshake.Tag(binary.LittleEndian.Uint32(data)) != handshake.TagCHLO {
+		return nil, ErrNoCHLO
+	}
+	msgLen, err := handshakeMessageLen(data)
+	if err != nil {
+		// 数据在上限处被截断，CHLO 本身超过了上限
+		if capped && err == errHandshakeMessageTruncated {
+			return nil, ErrCHLOTooLarge
+		}
+		return nil, err
+	}
+	return data[:msgLen], nil
+}
+
+// assembleStreamData ：按偏移量拼接 STREAM 帧的数据，返回从偏移量 0 开始的连续部分。
+// 最多返回 maxLen 字节，连续部分超过 maxLen 时截断，并且 capped 为 true
+func assembleStreamData(frames []*wire.StreamFrame, maxLen int) (data []byte, capped bool) {
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].Offset < frames[j].Offset })
+	for _, f := range frames {
+		end := f.Offset + f.DataLen()
+		if f.Offset > protocol.ByteCount(len(data)) {
+			break
+		}
+		if end <= protocol.ByteCount(len(data)) {
+			continue
+		}
+		if end > protocol.ByteCount(maxLen) {
+			end = protocol.ByteCount(maxLen)
+			capped = true
+		}
+		if data == nil {
+			// 只有一个帧时直接引用其数据
+			data = f.Data[:end]
+		} else {
+			data = append(data[:len(data):len(data)], f.Data[protocol.ByteCount(len(data))-f.Offset:end-f.Offset]...)
+		}
+		if capped {
+			break
+		}
+	}
+	return data, capped
+}
+
+// handshakeMessageLen ：根据标签表计算握手消息的长度，msg 中的数据不足时返回错误
+func handshakeMessageLen(msg []byte) (int, error) {
+	index, err := newHandshakeTagIndex(msg)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		_, _, _, ok, err := index.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if len(msg) < index.valuesEnd() {
+		return 0, errHandshakeMessageTruncated
+	}
+	return index.valuesEnd(), nil
+}
+
+// ParseVersionNegotiation ：解析服务端发送的版本协商包（gquic 公共头部或 IETF 长包头）。
+// versions 为服务端真实支持的版本；客户端用于探测的保留（GREASE）版本会被过滤掉，单独通过 reserved 返回。
+func ParseVersionNegotiation(packet []byte) (versions, reserved []VersionNumber, err error) {
+	r := bytes.NewReader(packet)
+	iHdr, err := wire.ParseInvariantHeader(r, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	hdr, err := iHdr.Parse(r, protocol.PerspectiveServer, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing header: %s", err)
+	}
+	if !hdr.IsVersionNegotiation {
+		return nil, nil, fmt.Errorf("not a version negotiation packet")
+	}
+	for _, v := range hdr.SupportedVersions {
+		if protocol.IsReservedVersion(v) {
+			reserved = append(reserved, v)
+		} else {
+			versions = append(versions, v)
+		}
+	}
+	return versions, reserved, nil
+}
+
+// IsVersionNegotiationPacket ：判断服务端发送的数据包是否为版本协商包（gquic 公共头部或 IETF 长包头），并返回其中提供的版本（包括保留版本）。
+// gquic 客户端发送的数据包同样设置了版本标志位，因此只能用于服务端发送的数据包。
+// 不是版本协商包时返回 false 且不返回错误；版本列表被截断时返回 true、完整的版本以及错误。
+func IsVersionNegotiationPacket(packet []byte) (bool, []VersionNumber, error) {
+	r := bytes.NewReader(packet)
+	iHdr, err := wire.ParseInvariantHeader(r, 0)
+	if err != nil {
+		return false, nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	if iHdr.IsLongHeader {
+		if iHdr.Version != 0 {
+			return false, nil, nil
+		}
+	} else if !isGQUICPublicHeaderTypeByte(packet[0]) || packet[0]&0x1 == 0 || packet[0]&0x2 > 0 {
+		// not a Public Header, or no version flag, or a Public Reset
+		return false, nil, nil
+	}
+	versionList := packet[len(packet)-r.Len():]
+	if len(versionList) == 0 {
+		return true, nil, fmt.Errorf("empty version list")
+	}
+	versions := make([]VersionNumber, 0, len(versionList)/4)
+	for ; len(versionList) >= 4; versionList = versionList[4:] {
+		versions = append(versions, VersionNumber(binary.BigEndian.Uint32(versionList)))
+	}
+	if len(versionList) > 0 {
+		return true, versions, fmt.Errorf("truncated version list")
+	}
+	return true, versions, nil
+}
+
+// QUICKind ：数据包的类型，由 ClassifyQUICPacket 根据首字节的标志位以及版本号判断
+type QUICKind int
+
+const (
+	// KindUnknown ：无法识别的数据包
+	KindUnknown QUICKind = iota
+	// KindGQUICLongHeader ：携带版本号的 gquic 数据包，包括设置了版本标志位的公共头部和 Q044 的长包头
+	KindGQUICLongHeader
+	// KindGQUICShortHeader ：不携带版本号的 gquic 公共头部
+	KindGQUICShortHeader
+	// KindGQUICPublicReset ：gquic 的 Public Reset
+	KindGQUICPublicReset
+	// KindIETFInitial ：IETF QUIC 的 Initial 包
+	KindIETFInitial
+	// KindIETFLongHeader ：Initial 以外的 IETF QUIC 长包头，例如 Handshake、0-RTT 和 Retry
+	KindIETFLongHeader
+	// KindIETFShortHeader ：IETF QUIC 的短包头
+	KindIETFShortHeader
+	// KindVersionNegotiation ：IETF 长包头格式的版本协商包
+	KindVersionNegotiation
+)
+
+func (k QUICKind) String() string {
+	switch k {
+	case KindGQUICLongHeader:
+		return "gQUIC long header"
+	case KindGQUICShortHeader:
+		return "gQUIC short header"
+	case KindGQUICPublicReset:
+		return "gQUIC Public Reset"
+	case KindIETFInitial:
+		return "IETF Initial"
+	case KindIETFLongHeader:
+		return "IETF long header"
+	case KindIETFShortHeader:
+		return "IETF short header"
+	case KindVersionNegotiation:
+		return "Version Negotiation"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyQUICPacket ：只根据首字节和版本号判断数据包的类型，不解析包头的其余部分，用于统计。
+// gquic 的版本协商包与携带版本号的客户端数据包标志位相同，无法区分，返回 KindGQUICLongHeader。
+func ClassifyQUICPacket(packet []byte) (QUICKind, error) {
+	if len(packet) == 0 {
+		return KindUnknown, fmt.Errorf("empty packet")
+	}
+	typeByte := packet[0]
+	if typeByte&0x80 == 0 {
+		switch {
+		case !isGQUICPublicHeaderTypeByte(typeByte):
+			return KindIETFShortHeader, nil
+		case typeByte&0x2 > 0:
+			return KindGQUICPublicReset, nil
+		case typeByte&0x1 > 0:
+			return KindGQUICLongHeader, nil
+		default:
+			return KindGQUICShortHeader, nil
+		}
+	}
+	if len(packet) < 5 {
+		return KindUnknown, fmt.Errorf("packet too short")
+	}
+	version := protocol.VersionNumber(binary.BigEndian.Uint32(packet[1:5]))
+	if version == 0 {
+		return KindVersionNegotiation, nil
+	}
+	if !version.UsesTLS() {
+		return KindGQUICLongHeader, nil
+	}
+	switch protocol.PacketType(typeByte & 0x7f) {
+	case protocol.PacketTypeInitial:
+		return KindIETFInitial, nil
+	case protocol.PacketTypeHandshake, protocol.PacketType0RTT, protocol.PacketTypeRetry:
+		return KindIETFLongHeader, nil
+	default:
+		return KindUnknown, nil
+	}
+}
+
+// ParseConnectionIDFromShortHeaderGQUICPacket ：解析不携带版本号的 gquic 数据包中的连接ID，用于将已建立连接的数据包路由到对应的后端。
+// 公共头部的连接ID标志位（0x08）未设置时连接ID被省略，返回 nil；Q044 的短包头（首字节 & 0x38 == 0x30）总是携带 8 字节的连接ID。
+// 返回值引用 packet，不做复制。该函数无法区分 IETF QUIC 的短包头，调用方需要确定数据包是 gquic。
+func ParseConnectionIDFromShortHeaderGQUICPacket(packet []byte) ([]byte, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("empty packet")
+	}
+	typeByte := packet[0]
+	if typeByte&0x80 > 0 {
+		return nil, fmt.Errorf("not a short header packet")
+	}
+	if typeByte&0x8 == 0 && typeByte&0x38 != 0x30 {
+		return nil, nil
+	}
+	if len(packet) < 1+gquicConnectionIDLen {
+		return nil, ErrTruncatedPacket
+	}
+	return packet[1 : 1+gquicConnectionIDLen], nil
+}
+
+// isGQUICPublicHeaderTypeByte ：长包头（0x80）和 IETF 短包头（0x30）都不是 gquic 公共头部
+func isGQUICPublicHeaderTypeByte(typeByte byte) bool {
+	return typeByte&0x80 == 0 && typeByte&0x38 != 0x30
+}
+
+// parseGQUICServerHeader ：解析服务端发送的 gquic 包的公共头部，并跳过 12 字节的 FNV-1a 哈希。
+// 返回后 r 位于第一个帧的起始位置。
+func parseGQUICServerHeader(r *bytes.Reader) (*wire.Header, error) {
+	typeByte, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	if !isGQUICPublicHeaderTypeByte(typeByte) {
+		return nil, fmt.Errorf("is not gquic")
+	}
+	_ = r.UnreadByte()
+
+	iHdr, err := wire.ParseInvariantHeader(r, 8)
+	if isTruncated(err) {
+		return nil, ErrTruncatedPacket
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	hdr, err := iHdr.Parse(r, protocol.PerspectiveServer, serverHelloFrameVersion)
+	if isTruncated(err) {
+		return nil, ErrTruncatedPacket
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing header: %s", err)
+	}
+	if hdr.IsVersionNegotiation || hdr.ResetFlag || r.Len() < 16 {
+		return nil, fmt.Errorf("no frame")
+	}
+	_, _ = r.Seek(12, io.SeekCurrent)
+	return hdr, nil
+}
+
+// parseGQUICClientHeader ：解析客户端发送的 gquic 包的公共头部，并跳过 12 字节的 FNV-1a 哈希。
+// 返回后 r 位于第一个帧的起始位置。
+func parseGQUICClientHeader(r *bytes.Reader) (*wire.Header, error) {
+	typeByte, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	if !isGQUICPublicHeaderTypeByte(typeByte) {
+		return nil, fmt.Errorf("is not gquic")
+	}
+	_ = r.UnreadByte()
+
 	iHdr, err := wire.ParseInvariantHeader(r, 8)
 	// drop the packet if we can't parse the header
+	if isTruncated(err) {
+		return nil, ErrTruncatedPacket
+	}
 	if err != nil {
-		return "", fmt.Errorf("error parsing invariant header: %s", err)
+		return nil, fmt.Errorf("error parsing invariant header: %s", err)
 	}
 
 	hdr, err := iHdr.Parse(r, protocol.PerspectiveClient, 0)
+	if isTruncated(err) {
+		return nil, ErrTruncatedPacket
+	}
 	if err != nil {
-		return "", fmt.Errorf("error parsing header: %s", err)
+		return nil, fmt.Errorf("error parsing header: %s", err)
 	}
 
 	// internal/crypto/null_aead_fnv128a.go
 	if hdr.Version.UsesIETFFrameFormat() || r.Len() < 16 {
-		return "", fmt.Errorf("no frame")
+		return nil, fmt.Errorf("no frame")
 	}
 
 	_, _ = r.Seek(12, io.SeekCurrent)
-	for {
-		frame, err := wire.ParseNextFrame(r, hdr, hdr.Version)
+	return hdr, nil
+}
+
+func parseSNIFromClientHelloGQUIC(r *bytes.Reader) (string, error) {
+	info, err := inspectClientHelloGQUIC(r, nil)
+	if err != nil {
+		return "", err
+	}
+	return info.SNI, nil
+}
+
+func inspectClientHelloGQUIC(r *bytes.Reader, opts *ParserOptions) (*ClientHelloInfo, error) {
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return inspectGQUICFrames(r, hdr, hdr.Version, opts)
+}
+
+// WalkGQUICFrames ：解析客户端发送的 gquic 数据包的公共头部，并依次对每个帧调用 fn，用于实现自定义的帧分析，例如统计帧类型或提取 ACK 区间。
+// fn 返回 stop 为 true 时停止遍历并返回 nil；fn 返回错误时停止遍历并原样返回该错误。
+// PADDING 帧被跳过，帧数超过 DefaultMaxFrames 时返回错误。帧中的数据直接引用 packet，不做复制。
+func WalkGQUICFrames(packet []byte, fn func(Frame) (stop bool, err error)) error {
+	if len(packet) < minGQUICPacketLen {
+		return fmt.Errorf("packet too short")
+	}
+	r := bytes.NewReader(packet)
+	hdr, err := parseGQUICClientHeader(r)
+	if err != nil {
+		return err
+	}
+	return walkGQUICFrames(r, hdr, hdr.Version, nil, fn)
+}
+
+// walkGQUICFrames ：r 位于第一个帧的起始位置，依次对每个帧调用 fn，直到数据包结束、fn 要求停止或者出错
+func walkGQUICFrames(r *bytes.Reader, hdr *wire.Header, version protocol.VersionNumber, opts *ParserOptions, fn func(wire.Frame) (bool, error)) error {
+	maxFrames := opts.maxFrames()
+	for i := 0; ; i++ {
+		frame, err := parseNextFrame(r, hdr, version)
 		if err != nil {
-			return "", err
+			return err
 		}
 		if frame == nil {
-			return "", nil
-		}
-		if sf, is := frame.(*wire.StreamFrame); is {
-			// internal/handshake/handshake_message
-			message, err := handshake.ParseHandshakeMessage(bytes.NewReader(sf.Data))
-			if err == nil && message.Tag == handshake.TagCHLO {
-				for tag, value := range message.Data {
-					if tag == handshake.TagSNI && len(value) > 0 {
-						return string(value), nil
-					}
-				}
-			}
+			return nil
+		}
+		// PADDING 在 ParseNextFrame 中被跳过，不计入帧数
+		if i == maxFrames {
+			return errTooManyFrames
+		}
+		if stop, err := fn(frame); err != nil || stop {
+			return err
+		}
+	}
+}
+
+// inspectGQUICFrames ：r 位于第一个帧的起始位置。
+// 使用第一个携带 SNI 的 CHLO；若所有 CHLO 都不携带 SNI，则使用第一个 CHLO。
+func inspectGQUICFrames(r *bytes.Reader, hdr *wire.Header, version protocol.VersionNumber, opts *ParserOptions) (*ClientHelloInfo, error) {
+	info := &ClientHelloInfo{
+		Version:      version,
+		ConnectionID: hdr.DestConnectionID,
+	}
+	err := walkGQUICFrames(r, hdr, version, opts, func(frame wire.Frame) (bool, error) {
+		sf, is := frame.(*wire.StreamFrame)
+		if !is {
+			return false, nil
 		}
+		// internal/handshake/handshake_message
+		message, err := handshake.ParseHandshakeMessage(bytes.NewReader(sf.Data))
+		if err != nil || message.Tag != handshake.TagCHLO {
+			return false, nil
+		}
+		sni := message.Data[handshake.TagSNI]
+		if info.Tags != nil && len(sni) == 0 {
+			return false, nil
+		}
+		info.Tags = message.Data
+		info.SNI = string(sni)
+		info.ALPNs = nil
+		if alpn := message.Data[handshake.TagALPN]; len(alpn) > 0 {
+			info.ALPNs = []string{string(alpn)}
+		}
+		return len(sni) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// parseNextFrame ：与 wire.ParseNextFrame 相同，但帧在其声明的长度之前结束时返回 ErrTruncatedPacket
+func parseNextFrame(r *bytes.Reader, hdr *wire.Header, version protocol.VersionNumber) (wire.Frame, error) {
+	frame, err := wire.ParseNextFrame(r, hdr, version)
+	if isTruncated(err) {
+		return nil, ErrTruncatedPacket
+	}
+	return frame, err
+}
+
+// isTruncated ：wire 包在数据不足时返回 io.EOF，解析帧时该错误被包装为 QuicError
+func isTruncated(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if qErr, ok := err.(*qerr.QuicError); ok {
+		return qErr.ErrorMessage == io.EOF.Error() || qErr.ErrorMessage == io.ErrUnexpectedEOF.Error()
 	}
+	return false
 }
@@ -0,0 +1,67 @@
+package quic
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minParallelBatchLen ：数据包数量低于此值时，并行解析的调度开销大于收益，直接顺序解析
+const minParallelBatchLen = 64
+
+// SNIResult ：批量解析中单个数据包的结果
+type SNIResult struct {
+	// Index ：数据包在输入中的下标
+	Index int
+	// SNI ：解析出的 SNI，出错时为空
+	SNI string
+	// Err ：解析错误
+	Err error
+}
+
+// ParseSNIBatch ：依次对每个数据包调用 ParseSNIFromClientHelloGQUICPacket，适用于 recvmmsg 等批量读取的场景。
+// 返回的结果与输入一一对应，results[i].Index == i。
+func ParseSNIBatch(packets [][]byte) []SNIResult {
+	results := make([]SNIResult, len(packets))
+	for i, p := range packets {
+		results[i] = parseSNIResult(i, p)
+	}
+	return results
+}
+
+// ParseSNIBatchParallel ：与 ParseSNIBatch 相同，但使用 workers 个 goroutine 并行解析。
+// workers <= 0 时使用 runtime.GOMAXPROCS(0)。数据包较少时退化为顺序解析。
+func ParseSNIBatchParallel(packets [][]byte, workers int) []SNIResult {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || len(packets) < minParallelBatchLen {
+		return ParseSNIBatch(packets)
+	}
+	if workers > len(packets) {
+		workers = len(packets)
+	}
+	results := make([]SNIResult, len(packets))
+	// 每个 worker 处理一段连续的下标，只写入自己的那部分结果，不需要加锁
+	chunkSize := (len(packets) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(packets); start += chunkSize {
+		end := start + chunkSize
+		if end > len(packets) {
+			end = len(packets)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				results[i] = parseSNIResult(i, packets[i])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return results
+}
+
+func parseSNIResult(index int, packet []byte) SNIResult {
+	sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+	return SNIResult{Index: index, SNI: sni, Err: err}
+}
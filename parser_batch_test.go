@@ -0,0 +1,83 @@
+package quic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// composeSNIBatch composes n packets. Every third packet is too short to be parsed.
+func composeSNIBatch(n int) [][]byte {
+	packets := make([][]byte, n)
+	for i := range packets {
+		if i%3 == 2 {
+			packets[i] = []byte("foobar")
+			continue
+		}
+		packets[i] = composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+			handshake.TagSNI: []byte(fmt.Sprintf("host%d.clemente.io", i)),
+		})
+	}
+	return packets
+}
+
+func checkSNIBatchResults(results []SNIResult, n int) {
+	ExpectWithOffset(1, results).To(HaveLen(n))
+	for i, res := range results {
+		ExpectWithOffset(1, res.Index).To(Equal(i))
+		if i%3 == 2 {
+			ExpectWithOffset(1, res.Err).To(MatchError("packet too short"))
+			ExpectWithOffset(1, res.SNI).To(BeEmpty())
+		} else {
+			ExpectWithOffset(1, res.Err).ToNot(HaveOccurred())
+			ExpectWithOffset(1, res.SNI).To(Equal(fmt.Sprintf("host%d.clemente.io", i)))
+		}
+	}
+}
+
+var _ = Describe("Parser batches", func() {
+	It("parses a batch", func() {
+		checkSNIBatchResults(ParseSNIBatch(composeSNIBatch(10)), 10)
+	})
+
+	It("parses an empty batch", func() {
+		Expect(ParseSNIBatch(nil)).To(BeEmpty())
+		Expect(ParseSNIBatchParallel(nil, 4)).To(BeEmpty())
+	})
+
+	It("parses a batch in parallel", func() {
+		n := 3*minParallelBatchLen + 1
+		checkSNIBatchResults(ParseSNIBatchParallel(composeSNIBatch(n), 4), n)
+	})
+
+	It("uses GOMAXPROCS workers by default", func() {
+		n := 2 * minParallelBatchLen
+		checkSNIBatchResults(ParseSNIBatchParallel(composeSNIBatch(n), 0), n)
+	})
+
+	It("parses small batches sequentially", func() {
+		checkSNIBatchResults(ParseSNIBatchParallel(composeSNIBatch(5), 8), 5)
+	})
+})
+
+func benchmarkParseSNIBatch(b *testing.B, parse func([][]byte) []SNIResult) {
+	RegisterTestingT(b)
+	packets := composeSNIBatch(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parse(packets)
+	}
+}
+
+func BenchmarkParseSNIBatch(b *testing.B) {
+	benchmarkParseSNIBatch(b, ParseSNIBatch)
+}
+
+func BenchmarkParseSNIBatchParallel(b *testing.B) {
+	benchmarkParseSNIBatch(b, func(packets [][]byte) []SNIResult { return ParseSNIBatchParallel(packets, 0) })
+}
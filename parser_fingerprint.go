@@ -0,0 +1,91 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+)
+
+// FingerprintDiff ：两个 CHLO 之间标签的差异，各列表均按标签在握手消息中的顺序排列
+type FingerprintDiff struct {
+	// Added ：只在第二个 CHLO 中出现的标签
+	Added []string
+	// Removed ：只在第一个 CHLO 中出现的标签
+	Removed []string
+	// Changed ：两个 CHLO 中都出现，但值不同的标签
+	Changed []TagChange
+}
+
+// TagChange ：一个标签在两个 CHLO 中的值
+type TagChange struct {
+	Tag string
+	Old []byte
+	New []byte
+}
+
+// Empty ：两个 CHLO 的标签及其值是否完全相同
+func (d *FingerprintDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CompareCHLOFingerprints ：解析两个携带 CHLO 的 gquic 数据包，比较其中的标签，用于发现客户端版本的变化或者被篡改的 CHLO。
+// PAD 的长度随数据包变化，因此只比较其是否存在，不比较其值。
+func CompareCHLOFingerprints(a, b []byte) (*FingerprintDiff, error) {
+	chloA, err := parseCHLOFromGQUICPacket(a)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing first CHLO: %s", err)
+	}
+	chloB, err := parseCHLOFromGQUICPacket(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing second CHLO: %s", err)
+	}
+
+	tags := make([]handshake.Tag, 0, len(chloA)+len(chloB))
+	for tag := range chloA {
+		tags = append(tags, tag)
+	}
+	for tag := range chloB {
+		if _, ok := chloA[tag]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	diff := &FingerprintDiff{}
+	for _, tag := range tags {
+		valA, inA := chloA[tag]
+		valB, inB := chloB[tag]
+		switch {
+		case !inA:
+			diff.Added = append(diff.Added, handshakeTagName(tag))
+		case !inB:
+			diff.Removed = append(diff.Removed, handshakeTagName(tag))
+		case tag != handshake.TagPAD && !bytes.Equal(valA, valB):
+			diff.Changed = append(diff.Changed, TagChange{Tag: handshakeTagName(tag), Old: valA, New: valB})
+		}
+	}
+	return diff, nil
+}
+
+// parseCHLOFromGQUICPacket ：返回 CHLO 中所有的标签，CHLO 必须完整地位于一个 STREAM 帧中
+func parseCHLOFromGQUICPacket(packet []byte) (map[handshake.Tag][]byte, error) {
+	info, err := InspectGQUICClientHello(packet)
+	if err != nil {
+		return nil, err
+	}
+	if info.Tags == nil {
+		return nil, ErrNoCHLO
+	}
+	return info.Tags, nil
+}
+
+// handshakeTagName ：标签的可读名称，例如 "SNI"
+func handshakeTagName(tag handshake.Tag) string {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(tag))
+	return strings.TrimRight(string(b), "\x00")
+}
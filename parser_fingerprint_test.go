@@ -0,0 +1,73 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CHLO fingerprints", func() {
+	var tags map[handshake.Tag][]byte
+
+	BeforeEach(func() {
+		tags = map[handshake.Tag][]byte{
+			handshake.TagSNI:  []byte("quic.clemente.io"),
+			handshake.TagVER:  []byte("Q043"),
+			handshake.TagPAD:  make([]byte, 10),
+			handshake.TagCCS:  {1, 2, 3, 4},
+			handshake.TagPDMD: []byte("X509"),
+		}
+	})
+
+	compose := func(tags map[handshake.Tag][]byte) []byte {
+		return composeCHLOPacket(protocol.Version43, tags)
+	}
+
+	It("reports no differences for identical CHLOs", func() {
+		diff, err := CompareCHLOFingerprints(compose(tags), compose(tags))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.Empty()).To(BeTrue())
+	})
+
+	It("reports a changed tag", func() {
+		a := compose(tags)
+		tags[handshake.TagVER] = []byte("Q039")
+		diff, err := CompareCHLOFingerprints(a, compose(tags))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.Added).To(BeEmpty())
+		Expect(diff.Removed).To(BeEmpty())
+		Expect(diff.Changed).To(Equal([]TagChange{{Tag: "VER", Old: []byte("Q043"), New: []byte("Q039")}}))
+	})
+
+	It("reports added and removed tags", func() {
+		a := compose(tags)
+		delete(tags, handshake.TagCCS)
+		tags[handshake.TagUAID] = []byte("Chrome/70")
+		diff, err := CompareCHLOFingerprints(a, compose(tags))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.Added).To(Equal([]string{"UAID"}))
+		Expect(diff.Removed).To(Equal([]string{"CCS"}))
+		Expect(diff.Changed).To(BeEmpty())
+	})
+
+	It("ignores the length of the padding", func() {
+		a := compose(tags)
+		tags[handshake.TagPAD] = make([]byte, 20)
+		diff, err := CompareCHLOFingerprints(a, compose(tags))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.Empty()).To(BeTrue())
+	})
+
+	It("errors if a packet doesn't contain a CHLO", func() {
+		_, err := CompareCHLOFingerprints(compose(tags), composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+			StreamID: protocol.Version43.CryptoStreamID(),
+			Data:     composeHandshakeMessage(handshake.TagSHLO, tags),
+		}))
+		Expect(err).To(MatchError("error parsing second CHLO: no CHLO found"))
+		_, err = CompareCHLOFingerprints([]byte("foobar"), compose(tags))
+		Expect(err).To(MatchError("error parsing first CHLO: packet too short"))
+	})
+})
@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package quic
+
+import (
+	"testing"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	"github.com/onsi/gomega"
+)
+
+// FuzzParseSNIFromClientHelloGQUICPacket makes sure that parsing hostile packets never panics.
+// Run it with: go test -run=^$ -fuzz=FuzzParseSNIFromClientHelloGQUICPacket
+func FuzzParseSNIFromClientHelloGQUICPacket(f *testing.F) {
+	gomega.RegisterTestingT(f) // the packet composers make assertions
+	for _, v := range []protocol.VersionNumber{protocol.Version39, protocol.Version43, protocol.Version44} {
+		f.Add(composeCHLOPacket(v, map[handshake.Tag][]byte{
+			handshake.TagSNI: []byte("quic.clemente.io"),
+			handshake.TagVER: []byte("Q039"),
+		}))
+		f.Add(composeGQUICPacket(v, &wire.PingFrame{}))
+	}
+	f.Add(composeGQUICServerPacket(&wire.PingFrame{}))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+		if err != nil && sni != "" {
+			t.Fatalf("returned SNI %q together with error %s", sni, err)
+		}
+		InspectGQUICClientHello(packet)
+	})
+}
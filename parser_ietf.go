@@ -0,0 +1,267 @@
+package quic
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+
+	"github.com/bifurcation/mint"
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// maxIETFClientHelloLen ：单个连接最多缓存的 crypto stream 数据量，防止恶意的 Initial 包耗尽内存
+const maxIETFClientHelloLen = 64 * 1024
+
+// maxIETFClientHelloFlows ：最多同时重组的连接数，超出时丢弃最久未收到数据包的连接
+const maxIETFClientHelloFlows = 1024
+
+const (
+	tlsRecordHeaderLen      = 5
+	tlsHandshakeHeaderLen   = 4
+	tlsRecordTypeHandshake  = 22
+	tlsHandshakeClientHello = byte(mint.HandshakeTypeClientHello)
+)
+
+var errIETFClientHelloTooLarge = errors.New("ClientHello exceeds the maximum reassembly size")
+
+// IETFClientHelloReassembler ：按连接ID 重组 IETF QUIC Initial 包中 crypto stream 上的 TLS ClientHello，
+// 用于 ClientHello 扩展较多、跨越多个 Initial 包的情况。
+// 同时重组的连接数有上限，超出时按 LRU 丢弃最久未收到数据包的连接。
+// 它不是并发安全的。
+type IETFClientHelloReassembler struct {
+	flows    map[string]*list.Element // value 为 *ietfCryptoFlow
+	lru      *list.List               // 最近收到数据包的连接在前
+	maxFlows int
+}
+
+type ietfCryptoFlow struct {
+	key    string
+	sorter *frameSorter
+	data   []byte
+}
+
+// NewIETFClientHelloReassembler ：创建一个 IETFClientHelloReassembler
+func NewIETFClientHelloReassembler() *IETFClientHelloReassembler {
+	return &IETFClientHelloReassembler{
+		flows:    make(map[string]*list.Element),
+		lru:      list.New(),
+		maxFlows: maxIETFClientHelloFlows,
+	}
+}
+
+// Push ：处理一个客户端发送的 Initial 包。
+// ClientHello 完整后返回其中的 SNI（可能为空）以及 done = true，并清除该连接的状态；
+// 在此之前返回 done = false。出错时同样会清除该连接的状态。
+func (r *IETFClientHelloReassembler) Push(packet []byte) (string, bool, error) {
+	hdr, frames, err := parseIETFInitialPacket(packet)
+	if err != nil {
+		return "", false, err
+	}
+	key := string(hdr.DestConnectionID)
+	var flow *ietfCryptoFlow
+	el, ok := r.flows[key]
+	if ok {
+		flow = el.Value.(*ietfCryptoFlow)
+	} else {
+		flow = &ietfCryptoFlow{key: key, sorter: newFrameSorter()}
+	}
+	sni, done, err := flow.handleFrames(frames, hdr.Version)
+	if err != nil || done {
+		r.forget(key)
+		return sni, done, err
+	}
+	if ok {
+		r.lru.MoveToFront(el)
+		return "", false, nil
+	}
+	for r.lru.Len() >= r.maxFlows {
+		r.forget(r.lru.Back().Value.(*ietfCryptoFlow).key)
+	}
+	r.flows[key] = r.lru.PushFront(flow)
+	return "", false, nil
+}
+
+// Forget ：丢弃某个连接已缓存的数据，例如在该连接超时后
+func (r *IETFClientHelloReassembler) Forget(connID protocol.ConnectionID) {
+	r.forget(string(connID))
+}
+
+func (r *IETFClientHelloReassembler) forget(key string) {
+	if el, ok := r.flows[key]; ok {
+		r.lru.Remove(el)
+		delete(r.flows, key)
+	}
+}
+
+// Len ：返回当前正在重组的连接数
+func (r *IETFClientHelloReassembler) Len() int {
+	return len(r.flows)
+}
+
+// MeetsMinInitialSize ：判断客户端发送的 Initial 数据报是否达到最小长度（1200 字节），
+// 服务端不会回应更小的 Initial，以防止放大攻击。
+func MeetsMinInitialSize(datagram []byte) bool {
+	return len(datagram) >= protocol.MinInitialPacketSize
+}
+
+// PadToMinInitialSize ：在数据报末尾补零，使其达到 Initial 的最小长度；已达到时原样返回。
+// 补零位于长包头 Payload Length 之外，接收方会忽略它们，因此无需重新加密。
+// 只适用于 IETF QUIC：gquic 的公共头部没有长度字段，需要在 CHLO 中使用 PAD 标签填充。
+func PadToMinInitialSize(datagram []byte) []byte {
+	if MeetsMinInitialSize(datagram) {
+		return datagram
+	}
+	return append(datagram, make([]byte, protocol.MinInitialPacketSize-len(datagram))...)
+}
+
+// DatagramPackets ：返回一个迭代器，每次调用返回数据报中合并（coalesced）的下一个数据包，遍历结束后返回 nil, false。
+// 返回的切片直接引用 datagram，不做复制，调用方可以随时停止遍历。
+// 只有 IETF QUIC 的长包头带有长度字段，短包头、gquic 数据包以及无法解析的包头都延伸到数据报末尾，
+// 由调用方在解析该数据包时报告错误。第一个数据包之后以零字节开头的部分视为填充。
+func DatagramPackets(datagram []byte) func() ([]byte, bool) {
+	rest := datagram
+	first := true
+	return func() ([]byte, bool) {
+		if len(rest) == 0 || (!first && rest[0] == 0) {
+			rest = nil
+			return nil, false
+		}
+		first = false
+		n := coalescedPacketLen(rest)
+		packet := rest[:n:n]
+		rest = rest[n:]
+		return packet, true
+	}
+}
+
+// coalescedPacketLen ：data 中第一个数据包的长度
+func coalescedPacketLen(data []byte) int {
+	r := bytes.NewReader(data)
+	iHdr, err := wire.ParseInvariantHeader(r, 0)
+	if err != nil || !iHdr.IsLongHeader || !iHdr.Version.UsesLengthInHeader() {
+		return len(data)
+	}
+	hdr, err := iHdr.Parse(r, protocol.PerspectiveClient, iHdr.Version)
+	if err != nil || hdr.Type == protocol.PacketTypeRetry || protocol.ByteCount(r.Len()) < hdr.PayloadLen {
+		return len(data)
+	}
+	return len(data) - r.Len() + int(hdr.PayloadLen)
+}
+
+func (f *ietfCryptoFlow) handleFrames(frames []wire.Frame, v protocol.VersionNumber) (string, bool, error) {
+	for _, frame := range frames {
+		sf, ok := frame.(*wire.StreamFrame)
+		if !ok || sf.StreamID != v.CryptoStreamID() {
+			continue
+		}
+		if sf.Offset+sf.DataLen() > maxIETFClientHelloLen {
+			return "", false, errIETFClientHelloTooLarge
+		}
+		if err := f.sorter.Push(sf.Data, sf.Offset, sf.FinBit); err != nil {
+			return "", false, err
+		}
+	}
+	for {
+		data, _ := f.sorter.Pop()
+		if data == nil {
+			break
+		}
+		f.data = append(f.data, data...)
+	}
+	return parseSNIFromTLSRecords(f.data)
+}
+
+func parseIETFInitialPacket(packet []byte) (*wire.Header, []wire.Frame, error) {
+	r := bytes.NewReader(packet)
+	iHdr, err := wire.ParseInvariantHeader(r, 0)
+	if isTruncated(err) {
+		return nil, nil, ErrTruncatedPacket
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing invariant header: %s", err)
+	}
+	if !iHdr.IsLongHeader || iHdr.Version == 0 || !iHdr.Version.UsesTLS() {
+		return nil, nil, errors.New("not an IETF QUIC Initial packet")
+	}
+	hdr, err := iHdr.Parse(r, protocol.PerspectiveClient, iHdr.Version)
+	if isTruncated(err) {
+		return nil, nil, ErrTruncatedPacket
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing header: %s", err)
+	}
+	if hdr.Type != protocol.PacketTypeInitial {
+		return nil, nil, errors.New("not an IETF QUIC Initial packet")
+	}
+	hdr.Raw = packet[:len(packet)-r.Len()]
+	data := packet[len(packet)-r.Len():]
+	if protocol.ByteCount(len(data)) < hdr.PayloadLen {
+		return nil, nil, ErrTruncatedPacket
+	}
+	data = data[:hdr.PayloadLen]
+
+	aead, err := crypto.NewNullAEAD(protocol.PerspectiveServer, hdr.DestConnectionID, hdr.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	// don't decrypt in place, the caller still owns the packet
+	decrypted, err := aead.Open(nil, data, hdr.PacketNumber, hdr.Raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	fr := bytes.NewReader(decrypted)
+	var frames []wire.Frame
+	for {
+		frame, err := parseNextFrame(fr, hdr, hdr.Version)
+		if err != nil {
+			return nil, nil, err
+		}
+		if frame == nil {
+			return hdr, frames, nil
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// parseSNIFromTLSRecords ：从 TLS 记录层数据中取出 ClientHello 并解析 SNI。
+// 数据不完整时返回 done = false。
+func parseSNIFromTLSRecords(data []byte) (string, bool, error) {
+	var msg []byte
+	for len(data) >= tlsRecordHeaderLen {
+		if data[0] != tlsRecordTypeHandshake {
+			return "", false, fmt.Errorf("unexpected TLS record type %d", data[0])
+		}
+		recordLen := int(data[3])<<8 | int(data[4])
+		if len(data) < tlsRecordHeaderLen+recordLen {
+			break
+		}
+		msg = append(msg, data[tlsRecordHeaderLen:tlsRecordHeaderLen+recordLen]...)
+		data = data[tlsRecordHeaderLen+recordLen:]
+	}
+	if len(msg) < tlsHandshakeHeaderLen {
+		return "", false, nil
+	}
+	if msg[0] != tlsHandshakeClientHello {
+		return "", false, fmt.Errorf("unexpected TLS handshake message type %d", msg[0])
+	}
+	bodyLen := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+	if len(msg) < tlsHandshakeHeaderLen+bodyLen {
+		return "", false, nil
+	}
+	var ch mint.ClientHelloBody
+	if _, err := ch.Unmarshal(msg[tlsHandshakeHeaderLen : tlsHandshakeHeaderLen+bodyLen]); err != nil {
+		return "", false, err
+	}
+	var sni mint.ServerNameExtension
+	found, err := ch.Extensions.Find(&sni)
+	if err != nil {
+		return "", false, err
+	}
+	if !found {
+		return "", true, nil
+	}
+	return string(sni), true, nil
+}
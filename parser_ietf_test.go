@@ -0,0 +1,336 @@
+package quic
+
+import (
+	"bytes"
+
+	"github.com/bifurcation/mint"
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// composeTLSClientHello composes a ClientHello in a TLS record, as sent on the crypto stream
+func composeTLSClientHello(sni string, paddingLen int) []byte {
+	ch := &mint.ClientHelloBody{
+		LegacyVersion: 0x0303,
+		CipherSuites:  []mint.CipherSuite{mint.TLS_AES_128_GCM_SHA256},
+	}
+	if len(sni) > 0 {
+		ext := mint.ServerNameExtension(sni)
+		Expect(ch.Extensions.Add(&ext)).To(Succeed())
+	}
+	if paddingLen > 0 {
+		ch.Extensions = append(ch.Extensions, mint.Extension{
+			ExtensionType: 21, // padding
+			ExtensionData: make([]byte, paddingLen),
+		})
+	}
+	body, err := ch.Marshal()
+	Expect(err).ToNot(HaveOccurred())
+	msg := append([]byte{tlsHandshakeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+	return append([]byte{tlsRecordTypeHandshake, 0x3, 0x3, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+// composeIETFInitialPacket composes an IETF QUIC Initial packet sent by the client
+func composeIETFInitialPacket(connID protocol.ConnectionID, pn protocol.PacketNumber, frames ...wire.Frame) []byte {
+	payload := &bytes.Buffer{}
+	for _, f := range frames {
+		Expect(f.Write(payload, versionIETFFrames)).To(Succeed())
+	}
+	aead, err := crypto.NewNullAEAD(protocol.PerspectiveClient, connID, versionIETFFrames)
+	Expect(err).ToNot(HaveOccurred())
+	hdr := &wire.Header{
+		IsLongHeader:     true,
+		Type:             protocol.PacketTypeInitial,
+		Version:          versionIETFFrames,
+		DestConnectionID: connID,
+		SrcConnectionID:  connID,
+		PacketNumber:     pn,
+		PacketNumberLen:  protocol.PacketNumberLen4,
+		PayloadLen:       protocol.ByteCount(payload.Len() + aead.Overhead()),
+	}
+	buf := &bytes.Buffer{}
+	Expect(hdr.Write(buf, protocol.PerspectiveClient, versionIETFFrames)).To(Succeed())
+	return append(buf.Bytes(), aead.Seal(nil, payload.Bytes(), pn, buf.Bytes())...)
+}
+
+var _ = Describe("IETF ClientHello reassembler", func() {
+	var (
+		reassembler *IETFClientHelloReassembler
+		connID      protocol.ConnectionID
+	)
+
+	BeforeEach(func() {
+		reassembler = NewIETFClientHelloReassembler()
+		connID = protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+	})
+
+	It("extracts the SNI from a ClientHello in a single Initial", func() {
+		packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Data:     composeTLSClientHello("quic.clemente.io", 0),
+		})
+		sni, done, err := reassembler.Push(packet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeTrue())
+		Expect(sni).To(Equal("quic.clemente.io"))
+		Expect(reassembler.Len()).To(BeZero())
+	})
+
+	It("reassembles a ClientHello split across two Initial packets", func() {
+		data := composeTLSClientHello("quic.clemente.io", 1500)
+		Expect(len(data)).To(BeNumerically(">", protocol.MaxPacketSizeIPv4))
+		split := len(data) / 2
+		first := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID:       versionIETFFrames.CryptoStreamID(),
+			Data:           data[:split],
+			DataLenPresent: true,
+		})
+		second := composeIETFInitialPacket(connID, 2, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Offset:   protocol.ByteCount(split),
+			Data:     data[split:],
+		})
+		sni, done, err := reassembler.Push(first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeFalse())
+		Expect(sni).To(BeEmpty())
+		Expect(reassembler.Len()).To(Equal(1))
+		sni, done, err = reassembler.Push(second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeTrue())
+		Expect(sni).To(Equal("quic.clemente.io"))
+		Expect(reassembler.Len()).To(BeZero())
+	})
+
+	It("reassembles Initial packets received out of order", func() {
+		data := composeTLSClientHello("quic.clemente.io", 1500)
+		split := len(data) / 2
+		first := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID:       versionIETFFrames.CryptoStreamID(),
+			Data:           data[:split],
+			DataLenPresent: true,
+		})
+		second := composeIETFInitialPacket(connID, 2, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Offset:   protocol.ByteCount(split),
+			Data:     data[split:],
+		})
+		_, done, err := reassembler.Push(second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeFalse())
+		sni, done, err := reassembler.Push(first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeTrue())
+		Expect(sni).To(Equal("quic.clemente.io"))
+	})
+
+	It("keeps connections apart", func() {
+		data := composeTLSClientHello("quic.clemente.io", 1500)
+		split := len(data) / 2
+		otherConnID := protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1}
+		_, done, err := reassembler.Push(composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID:       versionIETFFrames.CryptoStreamID(),
+			Data:           data[:split],
+			DataLenPresent: true,
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeFalse())
+		_, done, err = reassembler.Push(composeIETFInitialPacket(otherConnID, 2, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Offset:   protocol.ByteCount(split),
+			Data:     data[split:],
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeFalse())
+		Expect(reassembler.Len()).To(Equal(2))
+		reassembler.Forget(otherConnID)
+		Expect(reassembler.Len()).To(Equal(1))
+	})
+
+	It("evicts the least recently used connection when there are too many", func() {
+		reassembler.maxFlows = 2
+		data := composeTLSClientHello("quic.clemente.io", 1500)
+		third := len(data) / 3
+		part := func(c protocol.ConnectionID, pn protocol.PacketNumber, start, end int) []byte {
+			return composeIETFInitialPacket(c, pn, &wire.StreamFrame{
+				StreamID:       versionIETFFrames.CryptoStreamID(),
+				Offset:         protocol.ByteCount(start),
+				Data:           data[start:end],
+				DataLenPresent: true,
+			})
+		}
+		connID2 := protocol.ConnectionID{2, 2, 2, 2, 2, 2, 2, 2}
+		connID3 := protocol.ConnectionID{3, 3, 3, 3, 3, 3, 3, 3}
+		for _, p := range [][]byte{
+			part(connID, 1, 0, third),
+			part(connID2, 1, 0, third),
+			part(connID, 2, third, 2*third), // connID is now the most recently used connection
+			part(connID3, 1, 0, third),      // evicts connID2
+		} {
+			_, done, err := reassembler.Push(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(done).To(BeFalse())
+			Expect(reassembler.Len()).To(BeNumerically("<=", 2))
+		}
+		sni, done, err := reassembler.Push(part(connID, 3, 2*third, len(data)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeTrue())
+		Expect(sni).To(Equal("quic.clemente.io"))
+		Expect(reassembler.Len()).To(Equal(1))
+		// connID2 lost its first part, so it can't complete any more
+		_, done, err = reassembler.Push(part(connID2, 2, third, len(data)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeFalse())
+		Expect(reassembler.Len()).To(Equal(2))
+	})
+
+	It("returns an empty SNI if the ClientHello doesn't contain one", func() {
+		packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Data:     composeTLSClientHello("", 0),
+		})
+		sni, done, err := reassembler.Push(packet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(done).To(BeTrue())
+		Expect(sni).To(BeEmpty())
+	})
+
+	It("errors on gQUIC packets", func() {
+		_, _, err := reassembler.Push(composeCHLOPacket(protocol.Version43, nil))
+		Expect(err).To(MatchError("not an IETF QUIC Initial packet"))
+	})
+
+	It("errors when the packet can't be decrypted", func() {
+		packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Data:     composeTLSClientHello("quic.clemente.io", 0),
+		})
+		packet[len(packet)-1] ^= 0xff
+		_, _, err := reassembler.Push(packet)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("errors when the ClientHello gets too large", func() {
+		packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Offset:   maxIETFClientHelloLen,
+			Data:     []byte("foobar"),
+		})
+		_, _, err := reassembler.Push(packet)
+		Expect(err).To(MatchError(errIETFClientHelloTooLarge))
+		Expect(reassembler.Len()).To(BeZero())
+	})
+
+	Context("minimum Initial size", func() {
+		It("flags undersized Initials", func() {
+			packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			Expect(len(packet)).To(BeNumerically("<", protocol.MinInitialPacketSize))
+			Expect(MeetsMinInitialSize(packet)).To(BeFalse())
+			Expect(MeetsMinInitialSize(make([]byte, protocol.MinInitialPacketSize))).To(BeTrue())
+		})
+
+		It("pads undersized Initials", func() {
+			packet := composeIETFInitialPacket(connID, 1, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			orig := append([]byte{}, packet...)
+			padded := PadToMinInitialSize(packet)
+			Expect(padded).To(HaveLen(protocol.MinInitialPacketSize))
+			Expect(MeetsMinInitialSize(padded)).To(BeTrue())
+			Expect(padded[:len(orig)]).To(Equal(orig))
+			Expect(padded[len(orig):]).To(Equal(make([]byte, protocol.MinInitialPacketSize-len(orig))))
+			// the padding is ignored by the receiver
+			sni, done, err := reassembler.Push(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(done).To(BeTrue())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("doesn't pad Initials that are large enough", func() {
+			packet := make([]byte, protocol.MinInitialPacketSize+1)
+			Expect(PadToMinInitialSize(packet)).To(Equal(packet))
+		})
+	})
+})
+
+var _ = Describe("Coalesced datagrams", func() {
+	connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+
+	composeInitial := func(pn protocol.PacketNumber) []byte {
+		return composeIETFInitialPacket(connID, pn, &wire.StreamFrame{
+			StreamID: versionIETFFrames.CryptoStreamID(),
+			Data:     []byte("foobar"),
+		})
+	}
+
+	collect := func(next func() ([]byte, bool)) [][]byte {
+		var packets [][]byte
+		for {
+			p, ok := next()
+			if !ok {
+				return packets
+			}
+			packets = append(packets, p)
+		}
+	}
+
+	It("yields each coalesced packet", func() {
+		p1 := composeInitial(1)
+		p2 := composeInitial(2)
+		buf := &bytes.Buffer{}
+		hdr := &wire.Header{
+			DestConnectionID: connID,
+			PacketNumber:     3,
+			PacketNumberLen:  protocol.PacketNumberLen2,
+		}
+		Expect(hdr.Write(buf, protocol.PerspectiveClient, versionIETFFrames)).To(Succeed())
+		buf.WriteString("short header payload")
+		p3 := buf.Bytes()
+		datagram := append(append(append([]byte{}, p1...), p2...), p3...)
+		packets := collect(DatagramPackets(datagram))
+		Expect(packets).To(Equal([][]byte{p1, p2, p3}))
+		// the packets are not copied
+		Expect(&packets[1][0]).To(Equal(&datagram[len(p1)]))
+	})
+
+	It("stops at the end", func() {
+		next := DatagramPackets(composeInitial(1))
+		_, ok := next()
+		Expect(ok).To(BeTrue())
+		p, ok := next()
+		Expect(ok).To(BeFalse())
+		Expect(p).To(BeNil())
+		_, ok = next()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("handles empty datagrams", func() {
+		Expect(collect(DatagramPackets(nil))).To(BeEmpty())
+	})
+
+	It("ignores padding after the last packet", func() {
+		p := composeInitial(1)
+		packets := collect(DatagramPackets(PadToMinInitialSize(append([]byte{}, p...))))
+		Expect(packets).To(Equal([][]byte{p}))
+	})
+
+	It("yields the rest of the datagram if the length exceeds the datagram", func() {
+		p := composeInitial(1)
+		datagram := p[:len(p)-1]
+		Expect(collect(DatagramPackets(datagram))).To(Equal([][]byte{datagram}))
+	})
+
+	It("yields gQUIC packets as a whole", func() {
+		p := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{handshake.TagSNI: []byte("quic.clemente.io")})
+		Expect(collect(DatagramPackets(p))).To(Equal([][]byte{p}))
+	})
+})
@@ -0,0 +1,138 @@
+package quic
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ethernetHeaderLen = 14
+	vlanTagLen        = 4
+	ipv4MinHeaderLen  = 20
+	ipv6HeaderLen     = 40
+	udpHeaderLen      = 8
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100 // IEEE 802.1Q
+	etherTypeQinQ = 0x88a8 // IEEE 802.1ad
+
+	ipProtocolUDP = 17
+	// IPv6 扩展头部
+	ipv6HopByHop    = 0
+	ipv6Routing     = 43
+	ipv6Fragment    = 44
+	ipv6DestOptions = 60
+)
+
+// ParseSNIFromUDPPayloadInEthernetFrame ：从抓包（pcap 链路类型 EN10MB）中的以太网帧解析 SNI，
+// 依次去掉以太网头部（包括 802.1Q / 802.1ad VLAN 标签）、IPv4 或 IPv6 头部以及 UDP 头部，
+// 再将 UDP 负载交给 ParseSNIFromClientHelloGQUICPacket 解析。
+// 非 IP、非 UDP 的帧以及分片的 IP 数据包返回错误。
+func ParseSNIFromUDPPayloadInEthernetFrame(frame []byte) (string, error) {
+	payload, err := UDPPayloadFromEthernetFrame(frame)
+	if err != nil {
+		return "", err
+	}
+	return ParseSNIFromClientHelloGQUICPacket(payload)
+}
+
+// ParseSNIFromUDPPayloadInFrame ：与 ParseSNIFromUDPPayloadInEthernetFrame 相同，但用于其他链路类型：
+// IP 头部从 frame[linkHeaderLen] 开始，例如原始 IP 为 0，Linux cooked capture (SLL) 为 16。
+// IP 版本由 IP 头部的第一个字节确定。
+func ParseSNIFromUDPPayloadInFrame(frame []byte, linkHeaderLen int) (string, error) {
+	if linkHeaderLen < 0 || linkHeaderLen > len(frame) {
+		return "", fmt.Errorf("frame too short for a %d byte link-layer header", linkHeaderLen)
+	}
+	payload, err := udpPayloadFromIPPacket(frame[linkHeaderLen:])
+	if err != nil {
+		return "", err
+	}
+	return ParseSNIFromClientHelloGQUICPacket(payload)
+}
+
+// UDPPayloadFromEthernetFrame ：返回以太网帧中 UDP 数据报的负载，直接引用 frame，不做复制
+func UDPPayloadFromEthernetFrame(frame []byte) ([]byte, error) {
+	if len(frame) < ethernetHeaderLen {
+		return nil, fmt.Errorf("Ethernet frame too short")
+	}
+	etherType := binary.BigEndian.Uint16(frame[12:14])
+	pos := ethernetHeaderLen
+	for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+		if len(frame) < pos+vlanTagLen {
+			return nil, fmt.Errorf("VLAN tag truncated")
+		}
+		etherType = binary.BigEndian.Uint16(frame[pos+2 : pos+4])
+		pos += vlanTagLen
+	}
+	if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+		return nil, fmt.Errorf("not an IP packet (EtherType 0x%04x)", etherType)
+	}
+	return udpPayloadFromIPPacket(frame[pos:])
+}
+
+// udpPayloadFromIPPacket ：packet 以 IPv4 或 IPv6 头部开始，以太网帧末尾的填充根据 IP 头部中的长度去掉
+func udpPayloadFromIPPacket(packet []byte) ([]byte, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("IP packet too short")
+	}
+	var proto byte
+	var payload []byte
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < ipv4MinHeaderLen {
+			return nil, fmt.Errorf("IPv4 header too short")
+		}
+		headerLen := int(packet[0]&0xf) * 4
+		totalLen := int(binary.BigEndian.Uint16(packet[2:4]))
+		if headerLen < ipv4MinHeaderLen || totalLen < headerLen {
+			return nil, fmt.Errorf("invalid IPv4 header")
+		}
+		if totalLen > len(packet) {
+			return nil, fmt.Errorf("IPv4 packet truncated")
+		}
+		// More Fragments 标志或者分片偏移量不为 0
+		if binary.BigEndian.Uint16(packet[6:8])&0x3fff != 0 {
+			return nil, fmt.Errorf("fragmented IPv4 packet")
+		}
+		proto = packet[9]
+		payload = packet[headerLen:totalLen]
+	case 6:
+		if len(packet) < ipv6HeaderLen {
+			return nil, fmt.Errorf("IPv6 header too short")
+		}
+		payloadLen := int(binary.BigEndian.Uint16(packet[4:6]))
+		if ipv6HeaderLen+payloadLen > len(packet) {
+			return nil, fmt.Errorf("IPv6 packet truncated")
+		}
+		proto = packet[6]
+		payload = packet[ipv6HeaderLen : ipv6HeaderLen+payloadLen]
+		for proto == ipv6HopByHop || proto == ipv6Routing || proto == ipv6DestOptions {
+			if len(payload) < 8 {
+				return nil, fmt.Errorf("IPv6 extension header truncated")
+			}
+			extLen := (int(payload[1]) + 1) * 8
+			if extLen > len(payload) {
+				return nil, fmt.Errorf("IPv6 extension header truncated")
+			}
+			proto = payload[0]
+			payload = payload[extLen:]
+		}
+		if proto == ipv6Fragment {
+			return nil, fmt.Errorf("fragmented IPv6 packet")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported IP version %d", packet[0]>>4)
+	}
+	if proto != ipProtocolUDP {
+		return nil, fmt.Errorf("not a UDP packet (protocol %d)", proto)
+	}
+	if len(payload) < udpHeaderLen {
+		return nil, fmt.Errorf("UDP header too short")
+	}
+	udpLen := int(binary.BigEndian.Uint16(payload[4:6]))
+	if udpLen < udpHeaderLen || udpLen > len(payload) {
+		return nil, fmt.Errorf("invalid UDP length")
+	}
+	return payload[udpHeaderLen:udpLen], nil
+}
@@ -0,0 +1,176 @@
+package quic
+
+import (
+	"encoding/binary"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func composeUDPDatagram(payload []byte) []byte {
+	udp := make([]byte, udpHeaderLen, udpHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], 51234)
+	binary.BigEndian.PutUint16(udp[2:4], 443)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderLen+len(payload)))
+	return append(udp, payload...)
+}
+
+func composeIPv4Packet(proto byte, payload []byte) []byte {
+	ip := make([]byte, ipv4MinHeaderLen, ipv4MinHeaderLen+len(payload))
+	ip[0] = 0x45 // version 4, 20 byte header
+	binary.BigEndian.PutUint16(ip[2:4], uint16(ipv4MinHeaderLen+len(payload)))
+	ip[8] = 64 // TTL
+	ip[9] = proto
+	copy(ip[12:16], []byte{10, 0, 0, 1})
+	copy(ip[16:20], []byte{10, 0, 0, 2})
+	return append(ip, payload...)
+}
+
+func composeIPv6Packet(nextHeader byte, payload []byte) []byte {
+	ip := make([]byte, ipv6HeaderLen, ipv6HeaderLen+len(payload))
+	ip[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(payload)))
+	ip[6] = nextHeader
+	ip[7] = 64 // hop limit
+	return append(ip, payload...)
+}
+
+func composeEthernetFrame(etherType uint16, payload []byte) []byte {
+	frame := make([]byte, ethernetHeaderLen, ethernetHeaderLen+len(payload))
+	copy(frame[0:6], []byte{0x02, 0, 0, 0, 0, 0x01})
+	copy(frame[6:12], []byte{0x02, 0, 0, 0, 0, 0x02})
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	return append(frame, payload...)
+}
+
+var _ = Describe("Parser for captured frames", func() {
+	var chlo []byte
+
+	BeforeEach(func() {
+		chlo = composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+			handshake.TagSNI: []byte("quic.clemente.io"),
+			handshake.TagVER: {'Q', '0', '4', '3'},
+		})
+	})
+
+	Context("Ethernet frames", func() {
+		It("parses the SNI from an IPv4 packet", func() {
+			frame := composeEthernetFrame(etherTypeIPv4, composeIPv4Packet(ipProtocolUDP, composeUDPDatagram(chlo)))
+			sni, err := ParseSNIFromUDPPayloadInEthernetFrame(frame)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("parses the SNI from an IPv6 packet", func() {
+			frame := composeEthernetFrame(etherTypeIPv6, composeIPv6Packet(ipProtocolUDP, composeUDPDatagram(chlo)))
+			sni, err := ParseSNIFromUDPPayloadInEthernetFrame(frame)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("skips IPv4 options", func() {
+			ip := composeIPv4Packet(ipProtocolUDP, nil)
+			ip[0] = 0x46                // 24 byte header
+			ip = append(ip, 1, 1, 1, 0) // NOP, NOP, NOP, End of Options
+			ip = append(ip, composeUDPDatagram(chlo)...)
+			binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)))
+			payload, err := UDPPayloadFromEthernetFrame(composeEthernetFrame(etherTypeIPv4, ip))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payload).To(Equal(chlo))
+		})
+
+		It("skips IPv6 extension headers", func() {
+			ext := make([]byte, 8)
+			ext[0] = ipProtocolUDP // next header
+			frame := composeEthernetFrame(etherTypeIPv6, composeIPv6Packet(ipv6DestOptions, append(ext, composeUDPDatagram(chlo)...)))
+			payload, err := UDPPayloadFromEthernetFrame(frame)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payload).To(Equal(chlo))
+		})
+
+		It("skips VLAN tags", func() {
+			tags := []byte{
+				0, 100, 0x81, 0x00, // 802.1ad tag, followed by an 802.1Q tag
+				0, 200, 0x08, 0x00, // 802.1Q tag, followed by IPv4
+			}
+			frame := composeEthernetFrame(etherTypeQinQ, append(tags, composeIPv4Packet(ipProtocolUDP, composeUDPDatagram(chlo))...))
+			sni, err := ParseSNIFromUDPPayloadInEthernetFrame(frame)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("removes the Ethernet padding", func() {
+			frame := composeEthernetFrame(etherTypeIPv4, composeIPv4Packet(ipProtocolUDP, composeUDPDatagram([]byte("foobar"))))
+			frame = append(frame, make([]byte, 10)...)
+			payload, err := UDPPayloadFromEthernetFrame(frame)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payload).To(Equal([]byte("foobar")))
+		})
+
+		It("errors on non-IP frames", func() {
+			_, err := ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(0x0806, make([]byte, 28))) // ARP
+			Expect(err).To(MatchError("not an IP packet (EtherType 0x0806)"))
+		})
+
+		It("errors on non-UDP packets", func() {
+			_, err := ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(etherTypeIPv4, composeIPv4Packet(6, make([]byte, 20)))) // TCP
+			Expect(err).To(MatchError("not a UDP packet (protocol 6)"))
+			_, err = ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(etherTypeIPv6, composeIPv6Packet(58, make([]byte, 8)))) // ICMPv6
+			Expect(err).To(MatchError("not a UDP packet (protocol 58)"))
+		})
+
+		It("errors on fragmented packets", func() {
+			ip := composeIPv4Packet(ipProtocolUDP, composeUDPDatagram(chlo))
+			ip[6] = 0x20 // More Fragments
+			_, err := ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(etherTypeIPv4, ip))
+			Expect(err).To(MatchError("fragmented IPv4 packet"))
+			_, err = ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(etherTypeIPv6, composeIPv6Packet(ipv6Fragment, make([]byte, 8))))
+			Expect(err).To(MatchError("fragmented IPv6 packet"))
+		})
+
+		It("errors on truncated frames", func() {
+			frame := composeEthernetFrame(etherTypeIPv4, composeIPv4Packet(ipProtocolUDP, composeUDPDatagram(chlo)))
+			for i := 0; i < len(frame)-len(chlo); i++ {
+				_, err := ParseSNIFromUDPPayloadInEthernetFrame(frame[:i])
+				Expect(err).To(HaveOccurred())
+			}
+			_, err := ParseSNIFromUDPPayloadInEthernetFrame(frame[:len(frame)-1])
+			Expect(err).To(MatchError("IPv4 packet truncated"))
+		})
+
+		It("errors on invalid UDP lengths", func() {
+			udp := composeUDPDatagram(chlo)
+			binary.BigEndian.PutUint16(udp[4:6], 7)
+			_, err := ParseSNIFromUDPPayloadInEthernetFrame(composeEthernetFrame(etherTypeIPv4, composeIPv4Packet(ipProtocolUDP, udp)))
+			Expect(err).To(MatchError("invalid UDP length"))
+		})
+	})
+
+	Context("other link types", func() {
+		It("parses raw IP packets", func() {
+			sni, err := ParseSNIFromUDPPayloadInFrame(composeIPv6Packet(ipProtocolUDP, composeUDPDatagram(chlo)), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("uses the link-layer header length", func() {
+			sll := make([]byte, 16) // Linux cooked capture
+			sni, err := ParseSNIFromUDPPayloadInFrame(append(sll, composeIPv4Packet(ipProtocolUDP, composeUDPDatagram(chlo))...), 16)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors if the frame is shorter than the link-layer header", func() {
+			_, err := ParseSNIFromUDPPayloadInFrame(make([]byte, 10), 16)
+			Expect(err).To(MatchError("frame too short for a 16 byte link-layer header"))
+		})
+
+		It("errors on unknown IP versions", func() {
+			_, err := ParseSNIFromUDPPayloadInFrame([]byte{0x50, 0, 0, 0}, 0)
+			Expect(err).To(MatchError("unsupported IP version 5"))
+		})
+	})
+})
@@ -0,0 +1,22 @@
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// SniffSNI ：循环读取 conn 中的数据报，对每个数据报调用 ParseSNIFromClientHelloGQUICPacket，并将来源地址和结果传给 handler。
+// handler 在读取循环中同步调用；数据报的缓冲区会被复用，handler 返回后不能再引用其中的数据。
+// 超过一个 UDP 数据包大小的数据报会被截断。conn 被关闭或读取出错时返回该错误。
+func SniffSNI(conn net.PacketConn, handler func(addr net.Addr, sni string, err error)) error {
+	buf := make([]byte, protocol.MaxReceivePacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		sni, err := ParseSNIFromClientHelloGQUICPacket(buf[:n])
+		handler(addr, sni, err)
+	}
+}
@@ -0,0 +1,80 @@
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SNI sniffer", func() {
+	type sniffResult struct {
+		addr net.Addr
+		sni  string
+		err  error
+	}
+
+	var (
+		serverConn, clientConn *net.UDPConn
+		results                chan sniffResult
+		sniffErr               error
+		sniffDone              chan struct{}
+	)
+
+	BeforeEach(func() {
+		addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		serverConn, err = net.ListenUDP("udp", addr)
+		Expect(err).ToNot(HaveOccurred())
+		clientConn, err = net.ListenUDP("udp", addr)
+		Expect(err).ToNot(HaveOccurred())
+		results = make(chan sniffResult, 10)
+		sniffDone = make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			sniffErr = SniffSNI(serverConn, func(addr net.Addr, sni string, err error) {
+				results <- sniffResult{addr: addr, sni: sni, err: err}
+			})
+			close(sniffDone)
+		}()
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		serverConn.Close()
+		Eventually(sniffDone).Should(BeClosed())
+	})
+
+	It("calls the handler for every datagram", func() {
+		for _, hostname := range []string{"quic.clemente.io", "foo.clemente.io"} {
+			_, err := clientConn.WriteTo(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte(hostname),
+			}), serverConn.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+			var res sniffResult
+			Eventually(results).Should(Receive(&res))
+			Expect(res.err).ToNot(HaveOccurred())
+			Expect(res.sni).To(Equal(hostname))
+			Expect(res.addr.String()).To(Equal(clientConn.LocalAddr().String()))
+		}
+	})
+
+	It("passes parsing errors to the handler", func() {
+		_, err := clientConn.WriteTo([]byte("foobar"), serverConn.LocalAddr())
+		Expect(err).ToNot(HaveOccurred())
+		var res sniffResult
+		Eventually(results).Should(Receive(&res))
+		Expect(res.err).To(MatchError("packet too short"))
+		Expect(res.sni).To(BeEmpty())
+	})
+
+	It("returns when the connection is closed", func() {
+		Consistently(sniffDone).ShouldNot(BeClosed())
+		serverConn.Close()
+		Eventually(sniffDone).Should(BeClosed())
+		Expect(sniffErr).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,1576 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var parserTestConnID = protocol.ConnectionID{0xde, 0xad, 0xbe, 0xef, 0xca, 0xfe, 0x13, 0x37}
+
+// composeGQUICPacket composes an unencrypted gQUIC packet sent by the client, carrying the given frames
+func composeGQUICPacket(version protocol.VersionNumber, frames ...wire.Frame) []byte {
+	hdr := &wire.Header{
+		VersionFlag:      true,
+		Version:          version,
+		DestConnectionID: parserTestConnID,
+		PacketNumber:     1,
+		PacketNumberLen:  protocol.PacketNumberLen1,
+	}
+	buf := &bytes.Buffer{}
+	Expect(hdr.Write(buf, protocol.PerspectiveClient, version)).To(Succeed())
+	payload := &bytes.Buffer{}
+	for _, f := range frames {
+		Expect(f.Write(payload, version)).To(Succeed())
+	}
+	aead, err := crypto.NewNullAEAD(protocol.PerspectiveClient, parserTestConnID, version)
+	Expect(err).ToNot(HaveOccurred())
+	return append(buf.Bytes(), aead.Seal(nil, payload.Bytes(), hdr.PacketNumber, buf.Bytes())...)
+}
+
+// composeGQUICServerPacket composes an unencrypted gQUIC packet sent by the server, carrying the given frames
+func composeGQUICServerPacket(frames ...wire.Frame) []byte {
+	hdr := &wire.Header{
+		DestConnectionID: parserTestConnID,
+		PacketNumber:     1,
+		PacketNumberLen:  protocol.PacketNumberLen1,
+	}
+	buf := &bytes.Buffer{}
+	Expect(hdr.Write(buf, protocol.PerspectiveServer, protocol.Version43)).To(Succeed())
+	payload := &bytes.Buffer{}
+	for _, f := range frames {
+		Expect(f.Write(payload, protocol.Version43)).To(Succeed())
+	}
+	aead, err := crypto.NewNullAEAD(protocol.PerspectiveServer, parserTestConnID, protocol.Version43)
+	Expect(err).ToNot(HaveOccurred())
+	return append(buf.Bytes(), aead.Seal(nil, payload.Bytes(), hdr.PacketNumber, buf.Bytes())...)
+}
+
+func composeHandshakeMessage(tag handshake.Tag, data map[handshake.Tag][]byte) []byte {
+	b := &bytes.Buffer{}
+	handshake.HandshakeMessage{Tag: tag, Data: data}.Write(b)
+	return b.Bytes()
+}
+
+// composeFECPacket composes a gQUIC packet with a private header, as sent by the client.
+// The FNV-1a hash is not computed.
+func composeFECPacket(version protocol.VersionNumber, pn byte, privateFlags byte, fecGroupOffset byte) []byte {
+	b := &bytes.Buffer{}
+	b.WriteByte(0x1 | 0x8) // version flag, 8 byte connection ID, 1 byte packet number
+	b.Write(parserTestConnID)
+	b.Write([]byte{byte(version >> 24), byte(version >> 16), byte(version >> 8), byte(version)})
+	b.WriteByte(pn)
+	b.Write(make([]byte, 12)) // FNV-1a hash
+	b.WriteByte(privateFlags)
+	if privateFlags&privateFlagFECGroup > 0 {
+		b.WriteByte(fecGroupOffset)
+	}
+	b.Write([]byte("redundancy data"))
+	return b.Bytes()
+}
+
+// composeCHLOPacket composes a gQUIC packet with a CHLO on the crypto stream
+func composeCHLOPacket(version protocol.VersionNumber, data map[handshake.Tag][]byte) []byte {
+	return composeGQUICPacket(version, &wire.StreamFrame{
+		StreamID: version.CryptoStreamID(),
+		Data:     composeHandshakeMessage(handshake.TagCHLO, data),
+	})
+}
+
+// paddingFrame writes n PADDING bytes
+type paddingFrame struct{ n int }
+
+func (f *paddingFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	b.Write(make([]byte, f.n))
+	return nil
+}
+func (f *paddingFrame) Length(protocol.VersionNumber) protocol.ByteCount {
+	return protocol.ByteCount(f.n)
+}
+
+// a reader that is not a *bytes.Reader, to force the copying path
+type onlyReader struct{ r io.Reader }
+
+func (r *onlyReader) Read(p []byte) (int, error) { return r.r.Read(p) }
+
+var _ = Describe("Parser", func() {
+	var chlo []byte
+
+	BeforeEach(func() {
+		chlo = composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+			handshake.TagSNI: []byte("quic.clemente.io"),
+			handshake.TagVER: {'Q', '0', '4', '3'},
+		})
+	})
+
+	Context("parsing the SNI from a packet", func() {
+		It("parses the SNI", func() {
+			sni, err := ParseSNIFromClientHelloGQUICPacket(chlo)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors on packets that are too short", func() {
+			_, err := ParseSNIFromClientHelloGQUICPacket(chlo[:minGQUICPacketLen-1])
+			Expect(err).To(MatchError("packet too short"))
+		})
+
+		It("rejects packets with a Long Header", func() {
+			chlo[0] |= 0x80
+			_, err := ParseSNIFromClientHelloGQUICPacket(chlo)
+			Expect(err).To(MatchError("is not gquic"))
+		})
+
+		It("returns an empty SNI if the CHLO doesn't contain one", func() {
+			packet := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '4', '3'},
+			})
+			sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(BeEmpty())
+		})
+	})
+
+	Context("limiting the number of frames", func() {
+		composeWithPings := func(numPings int) []byte {
+			frames := make([]wire.Frame, 0, numPings+1)
+			for i := 0; i < numPings; i++ {
+				frames = append(frames, &wire.PingFrame{})
+			}
+			frames = append(frames, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data: composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+					handshake.TagSNI: []byte("quic.clemente.io"),
+				}),
+			})
+			return composeGQUICPacket(protocol.Version43, frames...)
+		}
+
+		It("walks up to DefaultMaxFrames frames", func() {
+			sni, err := ParseSNIFromClientHelloGQUICPacket(composeWithPings(DefaultMaxFrames - 1))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors when the packet contains more than DefaultMaxFrames frames", func() {
+			_, err := ParseSNIFromClientHelloGQUICPacket(composeWithPings(DefaultMaxFrames))
+			Expect(err).To(MatchError(errTooManyFrames))
+		})
+
+		It("doesn't count PADDING", func() {
+			packet := append(composeWithPings(1), make([]byte, 2*DefaultMaxFrames)...)
+			sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("uses the limit from the options", func() {
+			opts := &ParserOptions{MaxFrames: 2}
+			sni, err := ParseSNIFromClientHelloGQUICPacketWithOptions(composeWithPings(1), opts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			_, err = ParseSNIFromClientHelloGQUICPacketWithOptions(composeWithPings(2), opts)
+			Expect(err).To(MatchError(errTooManyFrames))
+		})
+
+		It("uses the default limit if no options are given", func() {
+			_, err := ParseSNIFromClientHelloGQUICPacketWithOptions(composeWithPings(DefaultMaxFrames), nil)
+			Expect(err).To(MatchError(errTooManyFrames))
+			_, err = ParseSNIFromClientHelloGQUICPacketWithOptions(composeWithPings(DefaultMaxFrames), &ParserOptions{})
+			Expect(err).To(MatchError(errTooManyFrames))
+		})
+	})
+
+	Context("parsing the SNI from an io.Reader", func() {
+		It("parses the SNI from a bytes.Reader", func() {
+			sni, err := ParseSNIFromClientHelloGQUICReader(bytes.NewReader(chlo))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("parses the SNI from an arbitrary io.Reader", func() {
+			sni, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{bytes.NewReader(chlo)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("returns a read error when the packet is too short", func() {
+			_, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{bytes.NewReader(chlo[:minGQUICPacketLen-1])})
+			Expect(err).To(MatchError("error reading packet: unexpected EOF"))
+		})
+
+		It("parses a packet of the maximum size", func() {
+			packet := make([]byte, protocol.MaxReceivePacketSize)
+			copy(packet, chlo)
+			sni, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{bytes.NewReader(packet)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors when the reader contains more than one packet", func() {
+			data := make([]byte, protocol.MaxReceivePacketSize+1)
+			copy(data, chlo)
+			_, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{bytes.NewReader(data)})
+			Expect(err).To(MatchError(errPacketTooLarge))
+		})
+
+		It("returns a read error for an empty reader", func() {
+			_, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{&bytes.Buffer{}})
+			Expect(err).To(MatchError("error reading packet: EOF"))
+		})
+
+		It("returns a read error for a truncated bytes.Reader", func() {
+			_, err := ParseSNIFromClientHelloGQUICReader(bytes.NewReader(chlo[:3]))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("stops reading after the first byte if the packet is not a gQUIC packet", func() {
+			chlo[0] |= 0x80
+			r := bytes.NewBuffer(chlo)
+			_, err := ParseSNIFromClientHelloGQUICReader(&onlyReader{r})
+			Expect(err).To(MatchError("is not gquic"))
+			Expect(r.Len()).To(Equal(len(chlo) - 1))
+		})
+	})
+
+	Context("detecting ClientHellos", func() {
+		It("detects a CHLO", func() {
+			Expect(IsGQUICClientHello(chlo)).To(BeTrue())
+		})
+
+		It("rejects other handshake messages", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagSHLO, nil),
+			})
+			Expect(IsGQUICClientHello(packet)).To(BeFalse())
+		})
+
+		It("rejects a CHLO that is not sent on the crypto stream", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: 3,
+				Data:     composeHandshakeMessage(handshake.TagCHLO, nil),
+			})
+			Expect(IsGQUICClientHello(packet)).To(BeFalse())
+		})
+
+		It("detects a CHLO preceded by other frames", func() {
+			packet := composeGQUICPacket(protocol.Version43,
+				&wire.PingFrame{},
+				&wire.StreamFrame{
+					StreamID: protocol.Version43.CryptoStreamID(),
+					Data:     composeHandshakeMessage(handshake.TagCHLO, nil),
+				},
+			)
+			Expect(IsGQUICClientHello(packet)).To(BeTrue())
+		})
+
+		It("rejects IETF QUIC packets", func() {
+			packet := composeIETFInitialPacket(parserTestConnID, 1, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			Expect(IsGQUICClientHello(packet)).To(BeFalse())
+		})
+
+		It("returns false for malformed packets", func() {
+			Expect(IsGQUICClientHello(nil)).To(BeFalse())
+			for i := 0; i < minGQUICPacketLen; i++ {
+				Expect(IsGQUICClientHello(chlo[:i])).To(BeFalse())
+			}
+			garbage := make([]byte, len(chlo))
+			copy(garbage, chlo)
+			for i := minGQUICPacketLen; i < len(garbage); i++ {
+				garbage[i] = 0xff
+			}
+			Expect(IsGQUICClientHello(garbage)).To(BeFalse())
+		})
+	})
+
+	Context("parsing Version Negotiation packets", func() {
+		It("filters reserved versions from a gQUIC Version Negotiation packet", func() {
+			packet := wire.ComposeGQUICVersionNegotiation(parserTestConnID, []protocol.VersionNumber{protocol.Version39, 0x1a2a3a4a, protocol.Version43})
+			versions, reserved, err := ParseVersionNegotiation(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(Equal([]VersionNumber{protocol.Version39, protocol.Version43}))
+			Expect(reserved).To(Equal([]VersionNumber{0x1a2a3a4a}))
+		})
+
+		It("filters reserved versions from an IETF Version Negotiation packet", func() {
+			packet, err := wire.ComposeVersionNegotiation(parserTestConnID, parserTestConnID, []protocol.VersionNumber{protocol.VersionTLS})
+			Expect(err).ToNot(HaveOccurred())
+			versions, reserved, err := ParseVersionNegotiation(packet)
+			Expect(err).ToNot(HaveOccurred())
+			// ComposeVersionNegotiation greases the version list
+			Expect(versions).To(Equal([]VersionNumber{protocol.VersionTLS}))
+			Expect(reserved).To(HaveLen(1))
+			Expect(protocol.IsReservedVersion(reserved[0])).To(BeTrue())
+		})
+
+		It("errors on packets that are not Version Negotiation packets", func() {
+			packet := composeIETFInitialPacket(parserTestConnID, 1, &wire.PingFrame{})
+			_, _, err := ParseVersionNegotiation(packet)
+			Expect(err).To(MatchError("not a version negotiation packet"))
+		})
+
+		It("errors on empty packets", func() {
+			_, _, err := ParseVersionNegotiation(nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("finding the SNI in the packet", func() {
+		It("returns the position of the SNI", func() {
+			start, length, err := ParseSNISpanFromClientHelloGQUICPacket(chlo)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(chlo[start : start+length])).To(Equal("quic.clemente.io"))
+		})
+
+		It("allows rewriting the SNI in place", func() {
+			start, length, err := ParseSNISpanFromClientHelloGQUICPacket(chlo)
+			Expect(err).ToNot(HaveOccurred())
+			copy(chlo[start:start+length], "xxxx.xxxxxxxx.xx")
+			// skip the FNV-1a hash, the packet isn't authenticated any more
+			r := bytes.NewReader(chlo)
+			hdr, err := parseGQUICClientHeader(r)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := wire.ParseNextFrame(r, hdr, hdr.Version)
+			Expect(err).ToNot(HaveOccurred())
+			msg, err := handshake.ParseHandshakeMessage(bytes.NewReader(frame.(*wire.StreamFrame).Data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg.Data).To(HaveKeyWithValue(handshake.TagSNI, []byte("xxxx.xxxxxxxx.xx")))
+			Expect(msg.Data).To(HaveKeyWithValue(handshake.TagVER, []byte("Q043")))
+		})
+
+		It("errors if the CHLO doesn't contain an SNI", func() {
+			packet := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '4', '3'},
+			})
+			_, _, err := ParseSNISpanFromClientHelloGQUICPacket(packet)
+			Expect(err).To(MatchError("CHLO doesn't contain an SNI"))
+		})
+
+		It("errors if the SNI spans a frame boundary", func() {
+			data := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			})
+			packet := composeGQUICPacket(protocol.Version43,
+				&wire.StreamFrame{
+					StreamID:       protocol.Version43.CryptoStreamID(),
+					Data:           data[:len(data)-5],
+					DataLenPresent: true,
+				},
+				&wire.StreamFrame{
+					StreamID: protocol.Version43.CryptoStreamID(),
+					Offset:   protocol.ByteCount(len(data) - 5),
+					Data:     data[len(data)-5:],
+				},
+			)
+			_, _, err := ParseSNISpanFromClientHelloGQUICPacket(packet)
+			Expect(err).To(MatchError(errSNISpansFrames))
+		})
+
+		It("errors if the packet doesn't contain a CHLO", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagSHLO, nil),
+			})
+			_, _, err := ParseSNISpanFromClientHelloGQUICPacket(packet)
+			Expect(err).To(MatchError("no CHLO found"))
+		})
+
+		It("errors if the SNI is longer than any valid value", func() {
+			data := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			})
+			binary.LittleEndian.PutUint32(data[12:16], 0xffffffff) // the end offset of the SNI
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     data,
+			})
+			_, _, err := ParseSNISpanFromClientHelloGQUICPacket(packet)
+			Expect(err).To(MatchError("handshake message value too long"))
+		})
+
+		It("errors on packets that are too short", func() {
+			_, _, err := ParseSNISpanFromClientHelloGQUICPacket(chlo[:minGQUICPacketLen-1])
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+
+	Context("parsing FEC groups", func() {
+		const version31 = protocol.VersionNumber(0x51303331) // Q031
+
+		It("parses a protected data packet", func() {
+			info, err := ParseFECGroup(composeFECPacket(version31, 10, privateFlagEntropy|privateFlagFECGroup, 3))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&FECGroupInfo{InFECGroup: true, FECGroup: 7}))
+		})
+
+		It("parses an FEC packet", func() {
+			info, err := ParseFECGroup(composeFECPacket(version31, 10, privateFlagFECGroup|privateFlagFEC, 4))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&FECGroupInfo{InFECGroup: true, FECGroup: 6, IsFECPacket: true}))
+		})
+
+		It("parses a packet that is not in an FEC group", func() {
+			info, err := ParseFECGroup(composeFECPacket(version31, 10, 0, 0))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&FECGroupInfo{}))
+		})
+
+		It("errors on FEC packets that are not in an FEC group", func() {
+			_, err := ParseFECGroup(composeFECPacket(version31, 10, privateFlagFEC, 0))
+			Expect(err).To(MatchError("FEC packet without FEC group"))
+		})
+
+		It("errors on invalid FEC group offsets", func() {
+			_, err := ParseFECGroup(composeFECPacket(version31, 10, privateFlagFECGroup, 11))
+			Expect(err).To(MatchError("invalid FEC group offset 11 for packet number 10"))
+		})
+
+		It("errors for versions without FEC", func() {
+			_, err := ParseFECGroup(chlo)
+			Expect(err).To(MatchError("FEC is not supported by gQUIC 43"))
+		})
+	})
+
+	Context("trailing padding", func() {
+		It("ignores trailing zero bytes after a CHLO that extends to the end of the packet", func() {
+			padded := append(chlo, make([]byte, 100)...)
+			sni, err := ParseSNIFromClientHelloGQUICPacket(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			Expect(IsGQUICClientHello(padded)).To(BeTrue())
+			start, length, err := ParseSNISpanFromClientHelloGQUICPacket(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(padded[start : start+length])).To(Equal("quic.clemente.io"))
+		})
+
+		It("ignores trailing zero bytes after a CHLO with an explicit length", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID:       protocol.Version43.CryptoStreamID(),
+				Data:           composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("quic.clemente.io")}),
+				DataLenPresent: true,
+			})
+			padded := append(packet, make([]byte, 100)...)
+			sni, err := ParseSNIFromClientHelloGQUICPacket(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			sni, err = ParseSNIWithVersion(padded, protocol.Version43)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("ignores trailing bytes after an IETF QUIC Initial", func() {
+			packet := composeIETFInitialPacket(parserTestConnID, 1, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			padded := append(packet, make([]byte, 100)...)
+			padded[len(padded)-1] = 0xff
+			sni, done, err := NewIETFClientHelloReassembler().Push(padded)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(done).To(BeTrue())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+	})
+
+	Context("parsing the SNI with a version hint", func() {
+		It("parses the SNI", func() {
+			sni, err := ParseSNIWithVersion(chlo, protocol.Version43)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("parses the SNI from a packet without a version", func() {
+			// remove the version flag and the version
+			packet := append([]byte{chlo[0] &^ 0x1}, chlo[1:9]...)
+			packet = append(packet, chlo[13:]...)
+			_, err := ParseSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).To(HaveOccurred())
+			sni, err := ParseSNIWithVersion(packet, protocol.Version43)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors if the version doesn't match the hint", func() {
+			_, err := ParseSNIWithVersion(chlo, protocol.Version39)
+			Expect(err).To(MatchError("version mismatch: packet uses gQUIC 43, expected gQUIC 39"))
+		})
+
+		It("errors on IETF QUIC versions", func() {
+			_, err := ParseSNIWithVersion(chlo, protocol.VersionTLS)
+			Expect(err).To(MatchError(ContainSubstring("is not a supported gQUIC version")))
+		})
+
+		It("errors on Long Header packets for versions using the Public Header", func() {
+			chlo[0] |= 0x80
+			_, err := ParseSNIWithVersion(chlo, protocol.Version43)
+			Expect(err).To(MatchError("packet header doesn't match gQUIC 43"))
+		})
+
+		It("errors on packets that are too short", func() {
+			_, err := ParseSNIWithVersion(chlo[:minGQUICPacketLen-1], protocol.Version43)
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+
+	Context("detecting version negotiation packets", func() {
+		It("detects gQUIC version negotiation packets", func() {
+			packet := wire.ComposeGQUICVersionNegotiation(parserTestConnID, []protocol.VersionNumber{protocol.Version39, protocol.Version43})
+			isVN, versions, err := IsVersionNegotiationPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isVN).To(BeTrue())
+			Expect(versions).To(Equal([]VersionNumber{protocol.Version39, protocol.Version43}))
+		})
+
+		It("detects IETF version negotiation packets, including reserved versions", func() {
+			packet, err := wire.ComposeVersionNegotiation(parserTestConnID, parserTestConnID, []protocol.VersionNumber{protocol.VersionTLS})
+			Expect(err).ToNot(HaveOccurred())
+			isVN, versions, err := IsVersionNegotiationPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isVN).To(BeTrue())
+			Expect(versions).To(ContainElement(protocol.VersionTLS))
+			Expect(versions).To(HaveLen(2)) // ComposeVersionNegotiation adds a reserved version
+		})
+
+		It("handles truncated version lists", func() {
+			packet := wire.ComposeGQUICVersionNegotiation(parserTestConnID, []protocol.VersionNumber{protocol.Version39, protocol.Version43})
+			isVN, versions, err := IsVersionNegotiationPacket(packet[:len(packet)-2])
+			Expect(err).To(MatchError("truncated version list"))
+			Expect(isVN).To(BeTrue())
+			Expect(versions).To(Equal([]VersionNumber{protocol.Version39}))
+		})
+
+		It("errors on empty version lists", func() {
+			packet := wire.ComposeGQUICVersionNegotiation(parserTestConnID, []protocol.VersionNumber{protocol.Version39})
+			isVN, versions, err := IsVersionNegotiationPacket(packet[:len(packet)-4])
+			Expect(err).To(MatchError("empty version list"))
+			Expect(isVN).To(BeTrue())
+			Expect(versions).To(BeEmpty())
+		})
+
+		It("doesn't detect other packets", func() {
+			for _, packet := range [][]byte{
+				composeGQUICServerPacket(&wire.PingFrame{}),
+				composeIETFInitialPacket(parserTestConnID, 1, &wire.PingFrame{}),
+				wire.WritePublicReset(parserTestConnID, 1, 0),
+			} {
+				isVN, versions, err := IsVersionNegotiationPacket(packet)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isVN).To(BeFalse())
+				Expect(versions).To(BeNil())
+			}
+		})
+
+		It("errors on malformed packets", func() {
+			_, _, err := IsVersionNegotiationPacket(nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("parsing server hellos", func() {
+		composeServerHelloPacket := func(msg []byte) []byte {
+			return composeGQUICServerPacket(&wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     msg,
+			})
+		}
+
+		It("parses a REJ", func() {
+			scfg := composeHandshakeMessage(handshake.TagSCFG, map[handshake.Tag][]byte{
+				handshake.TagSCID: []byte("server config id"),
+				handshake.TagKEXS: []byte("C255"),
+			})
+			info, err := ParseServerHelloInfo(composeServerHelloPacket(composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+				handshake.TagSCFG: scfg,
+				handshake.TagSTK:  []byte("source address token"),
+			})))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Rejected).To(BeTrue())
+			Expect(info.ConnectionID).To(Equal([]byte(parserTestConnID)))
+			Expect(info.ServerConfigID).To(Equal([]byte("server config id")))
+			Expect(info.Versions).To(BeEmpty())
+			Expect(info.SNI).To(BeEmpty())
+		})
+
+		It("parses a REJ whose certificate chain continues in the next packet", func() {
+			scfg := composeHandshakeMessage(handshake.TagSCFG, map[handshake.Tag][]byte{
+				handshake.TagSCID: []byte("server config id"),
+			})
+			msg := composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+				handshake.TagSCFG: scfg,
+				handshake.TagCERT: make([]byte, 3000),
+			})
+			info, err := ParseServerHelloInfo(composeServerHelloPacket(msg[:1000]))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Rejected).To(BeTrue())
+			Expect(info.ServerConfigID).To(Equal([]byte("server config id")))
+		})
+
+		It("parses an SHLO", func() {
+			info, err := ParseServerHelloInfo(composeServerHelloPacket(composeHandshakeMessage(handshake.TagSHLO, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '4', '3', 'Q', '0', '3', '9'},
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			})))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Rejected).To(BeFalse())
+			Expect(info.Versions).To(Equal([]VersionNumber{protocol.Version43, protocol.Version39}))
+			Expect(info.SNI).To(Equal("quic.clemente.io"))
+			Expect(info.ServerConfigID).To(BeNil())
+		})
+
+		It("errors on invalid version lists", func() {
+			_, err := ParseServerHelloInfo(composeServerHelloPacket(composeHandshakeMessage(handshake.TagSHLO, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '4'},
+			})))
+			Expect(err).To(MatchError("invalid version list"))
+		})
+
+		It("errors if the packet doesn't contain a REJ or SHLO", func() {
+			_, err := ParseServerHelloInfo(composeGQUICServerPacket(&wire.PingFrame{}, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagCHLO, nil),
+			}))
+			Expect(err).To(MatchError("no REJ or SHLO found"))
+		})
+
+		It("errors on version negotiation packets", func() {
+			_, err := ParseServerHelloInfo(wire.ComposeGQUICVersionNegotiation(parserTestConnID, []protocol.VersionNumber{protocol.Version39, protocol.Version43, protocol.Version44}))
+			Expect(err).To(MatchError("no frame"))
+		})
+
+		It("errors on packets that are too short", func() {
+			_, err := ParseServerHelloInfo(make([]byte, minGQUICPacketLen-1))
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+
+	Context("parsing the server config from a REJ", func() {
+		composeREJPacket := func(msg []byte) []byte {
+			return composeGQUICServerPacket(&wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     msg,
+			})
+		}
+
+		It("parses the SCFG, STK and SCID", func() {
+			scfg := composeHandshakeMessage(handshake.TagSCFG, map[handshake.Tag][]byte{
+				handshake.TagSCID: []byte("server config id"),
+				handshake.TagKEXS: []byte("C255"),
+			})
+			info, err := ParseServerConfigFromREJGQUICPacket(composeREJPacket(composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+				handshake.TagSCFG: scfg,
+				handshake.TagSTK:  []byte("source address token"),
+			})))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SCFG).To(Equal(scfg))
+			Expect(info.STK).To(Equal([]byte("source address token")))
+			Expect(info.SCID).To(Equal([]byte("server config id")))
+		})
+
+		It("returns nil for missing tags", func() {
+			info, err := ParseServerConfigFromREJGQUICPacket(composeREJPacket(composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+				handshake.TagSTK: []byte("source address token"),
+			})))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SCFG).To(BeNil())
+			Expect(info.SCID).To(BeNil())
+			Expect(info.STK).To(Equal([]byte("source address token")))
+		})
+
+		It("only returns values contained in the packet", func() {
+			scfg := composeHandshakeMessage(handshake.TagSCFG, map[handshake.Tag][]byte{
+				handshake.TagSCID: []byte("server config id"),
+			})
+			msg := composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+				handshake.TagSCFG: scfg,
+				handshake.TagSTK:  []byte("source address token"),
+				handshake.TagCERT: make([]byte, 3000),
+			})
+			info, err := ParseServerConfigFromREJGQUICPacket(composeREJPacket(msg[:1000]))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SCFG).To(Equal(scfg))
+			Expect(info.STK).To(Equal([]byte("source address token")))
+			Expect(info.SCID).To(Equal([]byte("server config id")))
+		})
+
+		It("parses packets without a connection ID", func() {
+			hdr := &wire.Header{ // the server omits the connection ID
+				PacketNumber:    1,
+				PacketNumberLen: protocol.PacketNumberLen1,
+			}
+			buf := &bytes.Buffer{}
+			Expect(hdr.Write(buf, protocol.PerspectiveServer, protocol.Version43)).To(Succeed())
+			payload := &bytes.Buffer{}
+			Expect((&wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data: composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{
+					handshake.TagSTK: []byte("source address token"),
+				}),
+			}).Write(payload, protocol.Version43)).To(Succeed())
+			aead, err := crypto.NewNullAEAD(protocol.PerspectiveServer, parserTestConnID, protocol.Version43)
+			Expect(err).ToNot(HaveOccurred())
+			packet := append(buf.Bytes(), aead.Seal(nil, payload.Bytes(), hdr.PacketNumber, buf.Bytes())...)
+			info, err := ParseServerConfigFromREJGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.STK).To(Equal([]byte("source address token")))
+		})
+
+		It("errors on SHLOs", func() {
+			_, err := ParseServerConfigFromREJGQUICPacket(composeREJPacket(composeHandshakeMessage(handshake.TagSHLO, map[handshake.Tag][]byte{
+				handshake.TagSTK: []byte("source address token"),
+			})))
+			Expect(err).To(MatchError("no REJ found"))
+		})
+
+		It("errors on client packets", func() {
+			_, err := ParseServerConfigFromREJGQUICPacket(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			}))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors on packets that are too short", func() {
+			_, err := ParseServerConfigFromREJGQUICPacket(make([]byte, minGQUICPacketLen-1))
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+
+	Context("parsing certificate info", func() {
+		It("returns the certificate related tags", func() {
+			info, err := ParseCHLOCertInfoGQUICPacket(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI:  []byte("quic.clemente.io"),
+				handshake.TagCCS:  {1, 2, 3, 4, 5, 6, 7, 8},
+				handshake.TagPDMD: []byte("X509"),
+				handshake.TagPROF: []byte("proof"),
+				handshake.TagCERT: []byte("cert"),
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&CHLOCertInfo{
+				CCS:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				PDMD: []byte("X509"),
+				PROF: []byte("proof"),
+				CERT: []byte("cert"),
+			}))
+		})
+
+		It("returns nil for missing tags", func() {
+			info, err := ParseCHLOCertInfoGQUICPacket(chlo)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&CHLOCertInfo{}))
+		})
+
+		It("errors if the packet doesn't contain a CHLO", func() {
+			_, err := ParseCHLOCertInfoGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagSHLO, nil),
+			}))
+			Expect(err).To(MatchError("no CHLO found"))
+		})
+	})
+
+	Context("parsing the client's versions", func() {
+		It("returns the versions from the version tag", func() {
+			versions, err := ParseClientVersionsFromCHLOGQUICPacket(chlo)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(Equal([]VersionNumber{protocol.Version43}))
+		})
+
+		It("returns multiple versions", func() {
+			versions, err := ParseClientVersionsFromCHLOGQUICPacket(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagVER: []byte("Q044Q043"),
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(Equal([]VersionNumber{protocol.Version44, protocol.Version43}))
+		})
+
+		It("detects a version different from the header version", func() {
+			versions, err := ParseClientVersionsFromCHLOGQUICPacket(composeCHLOPacket(protocol.Version39, map[handshake.Tag][]byte{
+				handshake.TagVER: []byte("Q043"),
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).ToNot(ContainElement(protocol.Version39))
+		})
+
+		It("errors if the CHLO doesn't contain a version tag", func() {
+			_, err := ParseClientVersionsFromCHLOGQUICPacket(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			}))
+			Expect(err).To(MatchError("CHLO doesn't contain a version tag"))
+		})
+
+		It("errors on malformed version tags", func() {
+			for _, ver := range [][]byte{{}, []byte("Q04"), []byte("Q043Q0")} {
+				_, err := ParseClientVersionsFromCHLOGQUICPacket(composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+					handshake.TagVER: ver,
+				}))
+				Expect(err).To(MatchError("invalid version tag"))
+			}
+		})
+
+		It("errors if the packet doesn't contain a CHLO", func() {
+			_, err := ParseClientVersionsFromCHLOGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagSHLO, nil),
+			}))
+			Expect(err).To(MatchError("no CHLO found"))
+		})
+	})
+
+	Context("decoding tag values", func() {
+		var msg []byte
+
+		BeforeEach(func() {
+			msg = composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI:  []byte("quic.clemente.io"),
+				handshake.TagVER:  []byte("Q043"),
+				handshake.TagUAID: []byte("Chrome/70"),
+				handshake.TagPAD:  nil,
+			})
+		})
+
+		It("decodes all tags", func() {
+			values, err := DecodeGQUICTagValues(msg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(values).To(Equal(map[HandshakeTag][]byte{
+				handshake.TagSNI:  []byte("quic.clemente.io"),
+				handshake.TagVER:  []byte("Q043"),
+				handshake.TagUAID: []byte("Chrome/70"),
+				handshake.TagPAD:  {},
+			}))
+		})
+
+		It("ignores trailing data", func() {
+			values, err := DecodeGQUICTagValues(append(msg, []byte("foobar")...))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(values).To(HaveLen(4))
+			Expect(values[handshake.TagUAID]).To(Equal([]byte("Chrome/70")))
+		})
+
+		It("errors on truncated messages", func() {
+			_, err := DecodeGQUICTagValues(msg[:len(msg)-1])
+			Expect(err).To(MatchError(errHandshakeMessageTruncated))
+			_, err = DecodeGQUICTagValues(msg[:8+3*8])
+			Expect(err).To(MatchError(errHandshakeMessageTruncated))
+			_, err = DecodeGQUICTagValues(msg[:7])
+			Expect(err).To(MatchError(errHandshakeMessageTruncated))
+		})
+
+		It("errors if the tag count is too large", func() {
+			binary.LittleEndian.PutUint32(msg[4:8], protocol.CryptoMaxParams+1)
+			_, err := DecodeGQUICTagValues(msg)
+			Expect(err).To(MatchError(fmt.Sprintf("too many entries in handshake message: %d", protocol.CryptoMaxParams+1)))
+		})
+
+		It("errors if the tags are not sorted", func() {
+			// swap the tags of the first two index entries
+			first := append([]byte{}, msg[8:12]...)
+			copy(msg[8:12], msg[16:20])
+			copy(msg[16:20], first)
+			_, err := DecodeGQUICTagValues(msg)
+			Expect(err).To(MatchError("handshake message tags not in ascending order"))
+		})
+
+		It("errors if the offsets decrease", func() {
+			binary.LittleEndian.PutUint32(msg[8+8+4:], 0)
+			binary.LittleEndian.PutUint32(msg[8+4:], 4)
+			_, err := DecodeGQUICTagValues(msg)
+			Expect(err).To(MatchError("invalid handshake message index"))
+		})
+
+		It("errors if a value is too long", func() {
+			msg = composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagPAD: make([]byte, protocol.CryptoParameterMaxLength+1),
+			})
+			_, err := DecodeGQUICTagValues(msg)
+			Expect(err).To(MatchError("handshake message value too long"))
+		})
+	})
+
+	Context("classifying packets", func() {
+		longHeader := func(typeByte byte, version protocol.VersionNumber) []byte {
+			return []byte{0x80 | typeByte, byte(version >> 24), byte(version >> 16), byte(version >> 8), byte(version), 0x55}
+		}
+
+		classify := func(packet []byte) QUICKind {
+			kind, err := ClassifyQUICPacket(packet)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return kind
+		}
+
+		It("classifies gQUIC Public Headers", func() {
+			Expect(classify(chlo)).To(Equal(KindGQUICLongHeader))
+			Expect(classify([]byte{0x09, 1, 2, 3, 4})).To(Equal(KindGQUICLongHeader))
+			Expect(classify([]byte{0x08, 1, 2, 3, 4})).To(Equal(KindGQUICShortHeader))
+			Expect(classify([]byte{0x00, 1})).To(Equal(KindGQUICShortHeader))
+			Expect(classify([]byte{0x28, 1, 2, 3, 4})).To(Equal(KindGQUICShortHeader))
+			Expect(classify([]byte{0x0a, 1, 2, 3, 4})).To(Equal(KindGQUICPublicReset))
+		})
+
+		It("classifies gQUIC 44 long headers", func() {
+			Expect(classify(longHeader(0x7f, protocol.Version44))).To(Equal(KindGQUICLongHeader))
+		})
+
+		It("classifies IETF long headers", func() {
+			Expect(classify(longHeader(byte(protocol.PacketTypeInitial), protocol.VersionTLS))).To(Equal(KindIETFInitial))
+			Expect(classify(longHeader(byte(protocol.PacketTypeHandshake), protocol.VersionTLS))).To(Equal(KindIETFLongHeader))
+			Expect(classify(longHeader(byte(protocol.PacketType0RTT), protocol.VersionTLS))).To(Equal(KindIETFLongHeader))
+			Expect(classify(longHeader(byte(protocol.PacketTypeRetry), protocol.VersionTLS))).To(Equal(KindIETFLongHeader))
+			Expect(classify(longHeader(0x42, protocol.VersionTLS))).To(Equal(KindUnknown))
+		})
+
+		It("classifies IETF short headers", func() {
+			Expect(classify([]byte{0x30, 1, 2, 3, 4})).To(Equal(KindIETFShortHeader))
+			Expect(classify([]byte{0x70, 1, 2, 3, 4})).To(Equal(KindIETFShortHeader))
+		})
+
+		It("classifies Version Negotiation packets", func() {
+			vn, err := wire.ComposeVersionNegotiation(parserTestConnID, parserTestConnID, []protocol.VersionNumber{protocol.VersionTLS})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(classify(vn)).To(Equal(KindVersionNegotiation))
+			Expect(classify(longHeader(0x42, 0))).To(Equal(KindVersionNegotiation))
+		})
+
+		It("errors on packets that are too short", func() {
+			_, err := ClassifyQUICPacket(nil)
+			Expect(err).To(MatchError("empty packet"))
+			_, err = ClassifyQUICPacket([]byte{0x80, 0, 0, 0})
+			Expect(err).To(MatchError("packet too short"))
+		})
+
+		It("has a string representation", func() {
+			Expect(KindIETFInitial.String()).To(Equal("IETF Initial"))
+			Expect(QUICKind(1337).String()).To(Equal("unknown"))
+		})
+	})
+
+	Context("inspecting ClientHellos", func() {
+		It("returns all the information at once", func() {
+			packet := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI:  []byte("quic.clemente.io"),
+				handshake.TagVER:  {'Q', '0', '4', '3'},
+				handshake.TagALPN: []byte("h2"),
+			})
+			info, err := InspectGQUICClientHello(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(Equal("quic.clemente.io"))
+			Expect(info.ALPNs).To(Equal([]string{"h2"}))
+			Expect(info.Version).To(Equal(protocol.Version43))
+			Expect(info.ConnectionID).To(Equal([]byte(parserTestConnID)))
+			Expect(info.Tags).To(HaveLen(3))
+			Expect(info.Tags).To(HaveKeyWithValue(handshake.TagVER, []byte("Q043")))
+		})
+
+		It("uses zero values for absent fields", func() {
+			info, err := InspectGQUICClientHello(composeCHLOPacket(protocol.Version39, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '3', '9'},
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(BeEmpty())
+			Expect(info.ALPNs).To(BeNil())
+			Expect(info.Version).To(Equal(protocol.Version39))
+			Expect(info.Tags).To(HaveLen(1))
+		})
+
+		It("doesn't error if the packet doesn't contain a CHLO", func() {
+			info, err := InspectGQUICClientHello(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagSHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("foo")}),
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(BeEmpty())
+			Expect(info.Tags).To(BeNil())
+			Expect(info.Version).To(Equal(protocol.Version43))
+		})
+
+		It("errors on unparseable packets", func() {
+			_, err := InspectGQUICClientHello([]byte("foobar"))
+			Expect(err).To(MatchError("packet too short"))
+			_, err = InspectGQUICClientHello(append([]byte{0x30}, make([]byte, 30)...))
+			Expect(err).To(MatchError("is not gquic"))
+		})
+
+		It("respects the frame limit", func() {
+			frames := make([]wire.Frame, 5)
+			for i := range frames {
+				frames[i] = &wire.PingFrame{}
+			}
+			_, err := InspectGQUICClientHelloWithOptions(composeGQUICPacket(protocol.Version43, frames...), &ParserOptions{MaxFrames: 2})
+			Expect(err).To(MatchError(errTooManyFrames))
+		})
+	})
+
+	Context("inspecting ClientHellos after version negotiation", func() {
+		var chlo39, chlo43 []byte
+
+		BeforeEach(func() {
+			chlo39 = composeCHLOPacket(protocol.Version39, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("rejected.clemente.io"),
+				handshake.TagVER: {'Q', '0', '3', '9'},
+			})
+			chlo43 = composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+				handshake.TagVER: {'Q', '0', '3', '9'},
+			})
+		})
+
+		It("uses the CHLO with the negotiated version", func() {
+			info, err := InspectNegotiatedGQUICClientHello([][]byte{chlo39, chlo43})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(Equal("quic.clemente.io"))
+			Expect(info.Version).To(Equal(protocol.Version43))
+		})
+
+		It("uses the version of the last CHLO", func() {
+			info, err := InspectNegotiatedGQUICClientHello([][]byte{chlo43, chlo39})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(Equal("rejected.clemente.io"))
+			Expect(info.Version).To(Equal(protocol.Version39))
+		})
+
+		It("uses the first CHLO with an SNI for the negotiated version", func() {
+			noSNI := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagVER: {'Q', '0', '3', '9'},
+			})
+			retransmission := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("other.clemente.io"),
+			})
+			info, err := InspectNegotiatedGQUICClientHello([][]byte{chlo39, noSNI, chlo43, retransmission})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(Equal("quic.clemente.io"))
+		})
+
+		It("ignores packets that don't contain a CHLO", func() {
+			ping := composeGQUICPacket(protocol.Version43, &wire.PingFrame{})
+			info, err := InspectNegotiatedGQUICClientHello([][]byte{chlo43, ping, []byte("foobar")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.SNI).To(Equal("quic.clemente.io"))
+		})
+
+		It("errors if no packet contains a CHLO", func() {
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			_, err := InspectNegotiatedGQUICClientHello([][]byte{composeGQUICPacket(protocol.Version43, ack)})
+			Expect(err).To(MatchError("no CHLO found"))
+			_, err = InspectNegotiatedGQUICClientHello([][]byte{[]byte("foobar")})
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+
+	Context("padding before the CHLO", func() {
+		var streamFrame *wire.StreamFrame
+
+		BeforeEach(func() {
+			streamFrame = &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("quic.clemente.io")}),
+			}
+		})
+
+		It("skips leading PADDING", func() {
+			packet := composeGQUICPacket(protocol.Version43, &paddingFrame{n: 100}, streamFrame)
+			sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			Expect(IsGQUICClientHello(packet)).To(BeTrue())
+			start, length, err := ParseSNISpanFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(packet[start : start+length])).To(Equal("quic.clemente.io"))
+		})
+
+		It("skips PADDING between frames", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.PingFrame{}, &paddingFrame{n: 10}, &wire.PingFrame{}, &paddingFrame{n: 1}, streamFrame)
+			sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			sni, err = ParseSNIWithVersion(packet, protocol.Version43)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+
+		It("skips leading PADDING in IETF QUIC Initial packets", func() {
+			packet := composeIETFInitialPacket(parserTestConnID, 1, &paddingFrame{n: 100}, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			sni, done, err := NewIETFClientHelloReassembler().Push(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(done).To(BeTrue())
+			Expect(sni).To(Equal("quic.clemente.io"))
+		})
+	})
+
+	Context("truncated packets", func() {
+		It("returns ErrTruncatedPacket when a STREAM frame is longer than the packet", func() {
+			data := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("quic.clemente.io")})
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID:       protocol.Version43.CryptoStreamID(),
+				Data:           data,
+				DataLenPresent: true,
+			})
+			for l := len(packet) - len(data); l < len(packet); l++ {
+				_, err := ParseSNIFromClientHelloGQUICPacket(packet[:l])
+				Expect(err).To(MatchError(ErrTruncatedPacket), fmt.Sprintf("length %d", l))
+				_, err = InspectGQUICClientHello(packet[:l])
+				Expect(err).To(MatchError(ErrTruncatedPacket), fmt.Sprintf("length %d", l))
+				_, _, err = ParseSNISpanFromClientHelloGQUICPacket(packet[:l])
+				Expect(err).To(MatchError(ErrTruncatedPacket), fmt.Sprintf("length %d", l))
+				_, err = ParseSNIWithVersion(packet[:l], protocol.Version43)
+				Expect(err).To(MatchError(ErrTruncatedPacket), fmt.Sprintf("length %d", l))
+			}
+		})
+
+		It("returns ErrTruncatedPacket when an IETF QUIC Initial is shorter than its payload length", func() {
+			packet := composeIETFInitialPacket(parserTestConnID, 1, &wire.StreamFrame{
+				StreamID: versionIETFFrames.CryptoStreamID(),
+				Data:     composeTLSClientHello("quic.clemente.io", 0),
+			})
+			// the first byte, the version and the connection ID lengths are needed to identify the packet
+			for l := 6; l < len(packet); l++ {
+				_, _, err := NewIETFClientHelloReassembler().Push(packet[:l])
+				Expect(err).To(MatchError(ErrTruncatedPacket), fmt.Sprintf("length %d", l))
+			}
+		})
+
+		It("fails cleanly on packets truncated at any length", func() {
+			packets := [][]byte{
+				chlo,
+				composeGQUICServerPacket(&wire.StreamFrame{
+					StreamID: serverHelloFrameVersion.CryptoStreamID(),
+					Data:     composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{handshake.TagSNI: []byte("foo")}),
+				}),
+				composeIETFInitialPacket(parserTestConnID, 1, &wire.StreamFrame{
+					StreamID: versionIETFFrames.CryptoStreamID(),
+					Data:     composeTLSClientHello("quic.clemente.io", 0),
+				}),
+			}
+			for _, packet := range packets {
+				for l := 0; l < len(packet); l++ {
+					p := packet[:l]
+					Expect(func() {
+						ParseSNIFromClientHelloGQUICPacket(p)
+						ParseSNIWithVersion(p, protocol.Version43)
+						ParseSNISpanFromClientHelloGQUICPacket(p)
+						InspectGQUICClientHello(p)
+						IsGQUICClientHello(p)
+						ClassifyQUICPacket(p)
+						ParseFECGroup(p)
+						ParseServerHelloInfo(p)
+						ParseServerConfigFromREJGQUICPacket(p)
+						ParseCHLOCertInfoGQUICPacket(p)
+						ParseCHLOPaddingGQUICPacket(p)
+						WalkGQUICFrames(p, func(Frame) (bool, error) { return false, nil })
+						ParseVersionNegotiation(p)
+						IsVersionNegotiationPacket(p)
+						CompareCHLOFingerprints(p, chlo)
+						NewIETFClientHelloReassembler().Push(p)
+					}).ToNot(Panic(), fmt.Sprintf("length %d", l))
+				}
+			}
+		})
+	})
+
+	Context("validating the SNI", func() {
+		composeSNIPacket := func(sni string) []byte {
+			return composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{handshake.TagSNI: []byte(sni)})
+		}
+
+		It("accepts valid hostnames", func() {
+			for _, name := range []string{
+				"quic.clemente.io",
+				"localhost",
+				"xn--bcher-kva.example",
+				"a-b.C0M",
+				strings.Repeat("a", 63) + ".com",
+			} {
+				sni, err := ParseAndValidateSNIFromClientHelloGQUICPacket(composeSNIPacket(name))
+				Expect(err).ToNot(HaveOccurred(), name)
+				Expect(sni).To(Equal(name))
+			}
+		})
+
+		It("accepts CHLOs without an SNI", func() {
+			packet := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{handshake.TagVER: {'Q', '0', '4', '3'}})
+			sni, err := ParseAndValidateSNIFromClientHelloGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(BeEmpty())
+		})
+
+		It("rejects invalid hostnames", func() {
+			for _, name := range []string{
+				"quic.clemente.io\x00.evil",
+				"quic\nclemente.io",
+				"quic clemente.io",
+				"quic..clemente.io",
+				".quic.clemente.io",
+				"quic.clemente.io.",
+				"-quic.clemente.io",
+				"quic-.clemente.io",
+				"quic.clemente.io-",
+				"quic_clemente.io",
+				"quic.clemente.io/foo",
+				"bücher.example",
+				strings.Repeat("a", 64) + ".com",
+				strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com",
+			} {
+				_, err := ParseAndValidateSNIFromClientHelloGQUICPacket(composeSNIPacket(name))
+				Expect(err).To(Equal(ErrInvalidSNI), name)
+			}
+		})
+
+		It("returns parsing errors", func() {
+			_, err := ParseAndValidateSNIFromClientHelloGQUICPacket([]byte{0x1})
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(Equal(ErrInvalidSNI))
+		})
+	})
+
+	Context("parsing the CHLO padding", func() {
+		It("returns the length of the padding and of the CHLO", func() {
+			msg := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI:  []byte("quic.clemente.io"),
+				handshake.TagXLCT: {1, 2, 3, 4, 5, 6, 7, 8},
+				handshake.TagPAD:  bytes.Repeat([]byte{'-'}, 1000),
+			})
+			info, err := ParseCHLOPaddingGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     msg,
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.PaddingLen).To(Equal(1000))
+			Expect(info.CHLOLen).To(Equal(len(msg)))
+			Expect(info.XLCT).To(Equal([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		})
+
+		It("returns zero for an unpadded CHLO", func() {
+			msg := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			})
+			info, err := ParseCHLOPaddingGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     msg,
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.PaddingLen).To(BeZero())
+			Expect(info.CHLOLen).To(Equal(len(msg)))
+			Expect(info.XLCT).To(BeNil())
+		})
+
+		It("errors if there's no CHLO", func() {
+			_, err := ParseCHLOPaddingGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}))
+			Expect(err).To(MatchError(ErrNoCHLO))
+		})
+	})
+
+	Context("extracting the CHLO", func() {
+		var msg []byte
+
+		BeforeEach(func() {
+			msg = composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+				handshake.TagVER: {'Q', '0', '4', '3'},
+			})
+		})
+
+		It("extracts the CHLO", func() {
+			chlo, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID:       protocol.Version43.CryptoStreamID(),
+				Data:           msg,
+				DataLenPresent: true,
+			}, &wire.PingFrame{}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo).To(Equal(msg))
+		})
+
+		It("strips data following the CHLO on the crypto stream", func() {
+			chlo, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     append(append([]byte{}, msg...), []byte("foobar")...),
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo).To(Equal(msg))
+		})
+
+		It("assembles a CHLO sent in multiple STREAM frames", func() {
+			split := len(msg) / 2
+			chlo, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43,
+				&wire.StreamFrame{
+					StreamID:       protocol.Version43.CryptoStreamID(),
+					Offset:         protocol.ByteCount(split),
+					Data:           msg[split:],
+					DataLenPresent: true,
+				},
+				&wire.StreamFrame{
+					StreamID: protocol.Version43.CryptoStreamID(),
+					Data:     msg[:split],
+				},
+			))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo).To(Equal(msg))
+		})
+
+		It("ignores data sent on other streams", func() {
+			chlo, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43,
+				&wire.StreamFrame{
+					StreamID:       5,
+					Data:           composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("foo")}),
+					DataLenPresent: true,
+				},
+				&wire.StreamFrame{
+					StreamID: protocol.Version43.CryptoStreamID(),
+					Data:     msg,
+				},
+			))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo).To(Equal(msg))
+		})
+
+		It("returns ErrNoCHLO if the packet doesn't contain a CHLO", func() {
+			_, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}))
+			Expect(err).To(Equal(ErrNoCHLO))
+			_, err = ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     composeHandshakeMessage(handshake.TagREJ, map[handshake.Tag][]byte{handshake.TagSNI: []byte("foo")}),
+			}))
+			Expect(err).To(Equal(ErrNoCHLO))
+		})
+
+		It("returns ErrNoCHLO if the crypto stream data doesn't start at offset 0", func() {
+			_, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Offset:   100,
+				Data:     msg,
+			}))
+			Expect(err).To(Equal(ErrNoCHLO))
+		})
+
+		It("errors if the CHLO is incomplete", func() {
+			_, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     msg[:len(msg)-1],
+			}))
+			Expect(err).To(MatchError("handshake message truncated"))
+		})
+
+		It("errors if the CHLO exceeds the maximum reassembly size", func() {
+			large := composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+				handshake.TagPAD: bytes.Repeat([]byte{'-'}, 2*DefaultMaxReassemblyBytes),
+			})
+			split := len(large) / 2
+			_, err := ExtractCHLOFromGQUICPacket(composeGQUICPacket(protocol.Version43,
+				&wire.StreamFrame{
+					StreamID:       protocol.Version43.CryptoStreamID(),
+					Data:           large[:split],
+					DataLenPresent: true,
+				},
+				&wire.StreamFrame{
+					StreamID: protocol.Version43.CryptoStreamID(),
+					Offset:   protocol.ByteCount(split),
+					Data:     large[split:],
+				},
+			))
+			Expect(err).To(Equal(ErrCHLOTooLarge))
+		})
+
+		It("uses the maximum reassembly size from the options", func() {
+			packet := composeGQUICPacket(protocol.Version43, &wire.StreamFrame{
+				StreamID: protocol.Version43.CryptoStreamID(),
+				Data:     append(append([]byte{}, msg...), []byte("foobar")...),
+			})
+			chlo, err := ExtractCHLOFromGQUICPacketWithOptions(packet, &ParserOptions{MaxReassemblyBytes: len(msg)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo).To(Equal(msg))
+			_, err = ExtractCHLOFromGQUICPacketWithOptions(packet, &ParserOptions{MaxReassemblyBytes: len(msg) - 1})
+			Expect(err).To(Equal(ErrCHLOTooLarge))
+		})
+	})
+
+	Context("parsing the connection ID of short header packets", func() {
+		composeShortHeaderPacket := func(hdr *wire.Header, version protocol.VersionNumber) []byte {
+			buf := &bytes.Buffer{}
+			Expect(hdr.Write(buf, protocol.PerspectiveClient, version)).To(Succeed())
+			buf.Write([]byte("encrypted payload"))
+			return buf.Bytes()
+		}
+
+		It("parses an 8 byte connection ID", func() {
+			packet := composeShortHeaderPacket(&wire.Header{
+				DestConnectionID: parserTestConnID,
+				PacketNumber:     0x1337,
+				PacketNumberLen:  protocol.PacketNumberLen2,
+			}, protocol.Version39)
+			connID, err := ParseConnectionIDFromShortHeaderGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connID).To(Equal([]byte(parserTestConnID)))
+		})
+
+		It("returns nil if the connection ID was omitted", func() {
+			for _, pnLen := range []protocol.PacketNumberLen{protocol.PacketNumberLen1, protocol.PacketNumberLen2, protocol.PacketNumberLen4} {
+				packet := composeShortHeaderPacket(&wire.Header{
+					PacketNumber:    0x1337,
+					PacketNumberLen: pnLen,
+				}, protocol.Version39)
+				connID, err := ParseConnectionIDFromShortHeaderGQUICPacket(packet)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(connID).To(BeNil())
+			}
+		})
+
+		It("parses the connection ID of gQUIC 44 short headers", func() {
+			packet := composeShortHeaderPacket(&wire.Header{
+				DestConnectionID: parserTestConnID,
+				PacketNumber:     0x1337,
+				PacketNumberLen:  protocol.PacketNumberLen4,
+			}, protocol.Version44)
+			Expect(packet[0] & 0x38).To(Equal(byte(0x30)))
+			connID, err := ParseConnectionIDFromShortHeaderGQUICPacket(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connID).To(Equal([]byte(parserTestConnID)))
+		})
+
+		It("errors on long headers", func() {
+			_, err := ParseConnectionIDFromShortHeaderGQUICPacket([]byte{0xff, 0, 0, 0, 0})
+			Expect(err).To(MatchError("not a short header packet"))
+		})
+
+		It("errors on truncated packets", func() {
+			_, err := ParseConnectionIDFromShortHeaderGQUICPacket(nil)
+			Expect(err).To(MatchError("empty packet"))
+			packet := composeShortHeaderPacket(&wire.Header{
+				DestConnectionID: parserTestConnID,
+				PacketNumber:     0x42,
+				PacketNumberLen:  protocol.PacketNumberLen1,
+			}, protocol.Version39)
+			_, err = ParseConnectionIDFromShortHeaderGQUICPacket(packet[:8])
+			Expect(err).To(Equal(ErrTruncatedPacket))
+		})
+	})
+
+	Context("concurrent use", func() {
+		It("parses the same packet from many goroutines", func() {
+			packet := composeCHLOPacket(protocol.Version43, map[handshake.Tag][]byte{
+				handshake.TagSNI: []byte("quic.clemente.io"),
+			})
+			original := append([]byte{}, packet...)
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(sni).To(Equal("quic.clemente.io"))
+					}
+				}()
+			}
+			wg.Wait()
+			Expect(packet).To(Equal(original))
+		})
+
+		It("parses different packets from many goroutines", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					hostname := fmt.Sprintf("host%d.clemente.io", i)
+					version := protocol.Version39
+					if i%2 == 0 {
+						version = protocol.Version43
+					}
+					packet := composeCHLOPacket(version, map[handshake.Tag][]byte{
+						handshake.TagSNI: []byte(hostname),
+					})
+					for j := 0; j < 20; j++ {
+						sni, err := ParseSNIFromClientHelloGQUICPacket(packet)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(sni).To(Equal(hostname))
+					}
+				}(i)
+			}
+			wg.Wait()
+		})
+	})
+
+	Context("walking frames", func() {
+		var packet []byte
+
+		BeforeEach(func() {
+			packet = composeGQUICPacket(protocol.Version43,
+				&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 3}}},
+				&wire.PingFrame{},
+				&wire.StreamFrame{
+					StreamID:       protocol.Version43.CryptoStreamID(),
+					Data:           composeHandshakeMessage(handshake.TagCHLO, map[handshake.Tag][]byte{handshake.TagSNI: []byte("quic.clemente.io")}),
+					DataLenPresent: true,
+				},
+			)
+		})
+
+		It("calls the callback for every frame", func() {
+			var frames []Frame
+			err := WalkGQUICFrames(packet, func(f Frame) (bool, error) {
+				frames = append(frames, f)
+				return false, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frames).To(HaveLen(3))
+			Expect(frames[0]).To(BeAssignableToTypeOf(&AckFrame{}))
+			Expect(frames[0].(*AckFrame).LargestAcked()).To(Equal(protocol.PacketNumber(3)))
+			Expect(frames[1]).To(BeAssignableToTypeOf(&wire.PingFrame{}))
+			Expect(frames[2]).To(BeAssignableToTypeOf(&StreamFrame{}))
+			Expect(frames[2].(*StreamFrame).StreamID).To(Equal(protocol.Version43.CryptoStreamID()))
+		})
+
+		It("stops when the callback says so", func() {
+			var count int
+			err := WalkGQUICFrames(packet, func(f Frame) (bool, error) {
+				count++
+				return count == 2, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+
+		It("returns errors returned by the callback", func() {
+			testErr := errors.New("test error")
+			var count int
+			err := WalkGQUICFrames(packet, func(f Frame) (bool, error) {
+				count++
+				return false, testErr
+			})
+			Expect(err).To(MatchError(testErr))
+			Expect(count).To(Equal(1))
+		})
+
+		It("errors on truncated packets", func() {
+			err := WalkGQUICFrames(packet[:len(packet)-1], func(Frame) (bool, error) { return false, nil })
+			Expect(err).To(MatchError(ErrTruncatedPacket))
+		})
+
+		It("errors on packets that are too short", func() {
+			err := WalkGQUICFrames(make([]byte, minGQUICPacketLen-1), func(Frame) (bool, error) {
+				Fail("callback should not be called")
+				return false, nil
+			})
+			Expect(err).To(MatchError("packet too short"))
+		})
+	})
+})
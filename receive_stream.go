@@ -16,9 +16,11 @@ type receiveStreamI interface {
 	ReceiveStream
 
 	handleStreamFrame(*wire.StreamFrame) error
+	setEncryptionLevel(protocol.EncryptionLevel)
 	handleRstStreamFrame(*wire.RstStreamFrame) error
 	closeForShutdown(error)
 	getWindowUpdate() protocol.ByteCount
+	closeError() error
 }
 
 type receiveStream struct {
@@ -47,6 +49,9 @@ type receiveStream struct {
 	readChan chan struct{}
 	deadline time.Time
 
+	// the lowest encryption level of the STREAM frames received on this stream
+	encryptionLevel protocol.EncryptionLevel
+
 	flowController flowcontrol.StreamFlowController
 	version        protocol.VersionNumber
 }
@@ -96,31 +101,33 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 	if s.resetRemotely {
 		return false, 0, s.resetRemotelyErr
 	}
-	if s.closedForShutdown {
-		return false, 0, s.closeForShutdownErr
-	}
 
 	bytesRead := 0
 	for bytesRead < len(p) {
 		if s.currentFrame == nil || s.readPosInFrame >= len(s.currentFrame) {
 			s.dequeueNextFrame()
 		}
+		// Return the data read so far. Errors are returned by the next call to Read.
 		if s.currentFrame == nil && bytesRead > 0 {
-			return false, bytesRead, s.closeForShutdownErr
+			return false, bytesRead, nil
 		}
 
 		var deadlineTimer *utils.Timer
 		for {
 			// Stop waiting on errors
-			if s.closedForShutdown {
-				return false, bytesRead, s.closeForShutdownErr
-			}
 			if s.canceledRead {
 				return false, bytesRead, s.cancelReadErr
 			}
 			if s.resetRemotely {
 				return false, bytesRead, s.resetRemotelyErr
 			}
+			// Data that was already received is returned, even if the deadline expired or the session was closed.
+			if s.currentFrame != nil || s.currentFrameIsLast {
+				break
+			}
+			if s.closedForShutdown {
+				return false, bytesRead, s.closeForShutdownErr
+			}
 
 			deadline := s.deadline
 			if !deadline.IsZero() {
@@ -133,10 +140,6 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 				deadlineTimer.Reset(deadline)
 			}
 
-			if s.currentFrame != nil || s.currentFrameIsLast {
-				break
-			}
-
 			s.mutex.Unlock()
 			if deadline.IsZero() {
 				<-s.readChan
@@ -227,6 +230,20 @@ func (s *receiveStream) handleStreamFrame(frame *wire.StreamFrame) error {
 	return nil
 }
 
+func (s *receiveStream) setEncryptionLevel(encLevel protocol.EncryptionLevel) {
+	s.mutex.Lock()
+	if s.encryptionLevel == protocol.EncryptionUnspecified || encLevel < s.encryptionLevel {
+		s.encryptionLevel = encLevel
+	}
+	s.mutex.Unlock()
+}
+
+func (s *receiveStream) EncryptionLevel() protocol.EncryptionLevel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encryptionLevel
+}
+
 func (s *receiveStream) handleRstStreamFrame(frame *wire.RstStreamFrame) error {
 	completed, err := s.handleRstStreamFrameImpl(frame)
 	if completed {
@@ -265,6 +282,16 @@ func (s *receiveStream) handleRstStreamFrameImpl(frame *wire.RstStreamFrame) (bo
 	return true, nil
 }
 
+// closeError returns the error that reading was canceled with, or nil if the stream was closed cleanly.
+func (s *receiveStream) closeError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.resetRemotely {
+		return s.resetRemotelyErr
+	}
+	return s.cancelReadErr
+}
+
 func (s *receiveStream) CloseRemote(offset protocol.ByteCount) {
 	s.handleStreamFrame(&wire.StreamFrame{FinBit: true, Offset: offset})
 }
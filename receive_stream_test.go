@@ -7,9 +7,13 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -39,6 +43,21 @@ var _ = Describe("Receive Stream", func() {
 		Expect(str.StreamID()).To(Equal(protocol.StreamID(1337)))
 	})
 
+	Context("encryption level", func() {
+		It("is unspecified before any data was received", func() {
+			Expect(str.EncryptionLevel()).To(Equal(protocol.EncryptionUnspecified))
+		})
+
+		It("reports the lowest encryption level", func() {
+			str.setEncryptionLevel(protocol.EncryptionForwardSecure)
+			Expect(str.EncryptionLevel()).To(Equal(protocol.EncryptionForwardSecure))
+			str.setEncryptionLevel(protocol.EncryptionSecure)
+			Expect(str.EncryptionLevel()).To(Equal(protocol.EncryptionSecure))
+			str.setEncryptionLevel(protocol.EncryptionForwardSecure)
+			Expect(str.EncryptionLevel()).To(Equal(protocol.EncryptionSecure))
+		})
+	})
+
 	Context("reading", func() {
 		It("reads a single STREAM frame", func() {
 			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
@@ -232,17 +251,49 @@ var _ = Describe("Receive Stream", func() {
 			})
 
 			It("returns an error when Read is called after the deadline", func() {
-				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false).AnyTimes()
-				f := &wire.StreamFrame{Data: []byte("foobar")}
-				err := str.handleStreamFrame(f)
+				str.SetReadDeadline(time.Now().Add(-time.Second))
+				b := make([]byte, 6)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(BeZero())
+			})
+
+			It("returns data that was received before the deadline expired, before returning the error", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+				mockFC.EXPECT().MaybeQueueWindowUpdate()
+				err := str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})
 				Expect(err).ToNot(HaveOccurred())
 				str.SetReadDeadline(time.Now().Add(-time.Second))
 				b := make([]byte, 6)
 				n, err := strWithTimeout.Read(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				Expect(b).To(Equal([]byte("foobar")))
+				n, err = strWithTimeout.Read(b)
 				Expect(err).To(MatchError(errDeadline))
 				Expect(n).To(BeZero())
 			})
 
+			It("returns partial data without an error, and the deadline error on the next call", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(3))
+				mockFC.EXPECT().MaybeQueueWindowUpdate()
+				err := str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foo")})
+				Expect(err).ToNot(HaveOccurred())
+				deadline := time.Now().Add(scaleDuration(50 * time.Millisecond))
+				str.SetReadDeadline(deadline)
+				b := make([]byte, 6)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(3))
+				Expect(b[:n]).To(Equal([]byte("foo")))
+				n, err = strWithTimeout.Read(b)
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(BeZero())
+				Expect(time.Now()).To(BeTemporally("~", deadline, scaleDuration(20*time.Millisecond)))
+			})
+
 			It("unblocks when the deadline is changed to the past", func() {
 				str.SetReadDeadline(time.Now().Add(time.Hour))
 				done := make(chan struct{})
@@ -456,6 +507,22 @@ var _ = Describe("Receive Stream", func() {
 				Expect(n).To(BeZero())
 				Expect(err).To(MatchError(testErr))
 			})
+
+			It("returns data that was received before closing, before returning the error", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+				mockFC.EXPECT().MaybeQueueWindowUpdate()
+				err := str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})
+				Expect(err).ToNot(HaveOccurred())
+				str.closeForShutdown(testErr)
+				b := make([]byte, 10)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b[:n]).To(Equal([]byte("foobar")))
+				n, err = strWithTimeout.Read(b)
+				Expect(n).To(BeZero())
+				Expect(err).To(MatchError(testErr))
+			})
 		})
 	})
 
@@ -667,5 +734,27 @@ var _ = Describe("Receive Stream", func() {
 			mockFC.EXPECT().GetWindowUpdate().Return(protocol.ByteCount(0x100))
 			Expect(str.getWindowUpdate()).To(Equal(protocol.ByteCount(0x100)))
 		})
+
+		It("doesn't buffer more than the receive window if the application doesn't read", func() {
+			const window = 1000
+			var windowUpdateQueued bool
+			connFC := flowcontrol.NewConnectionFlowController(window, window, func() {}, &congestion.RTTStats{}, utils.DefaultLogger)
+			fc := flowcontrol.NewStreamFlowController(streamID, true, connFC, window, window, 0, func(protocol.StreamID) { windowUpdateQueued = true }, &congestion.RTTStats{}, utils.DefaultLogger)
+			str = newReceiveStream(streamID, mockSender, fc, versionIETFFrames)
+			for offset := protocol.ByteCount(0); offset < window; offset += 100 {
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: offset, Data: make([]byte, 100)})).To(Succeed())
+			}
+			// the window isn't extended as long as the application doesn't read
+			Expect(windowUpdateQueued).To(BeFalse())
+			Expect(str.getWindowUpdate()).To(BeZero())
+			err := str.handleStreamFrame(&wire.StreamFrame{Offset: window, Data: []byte{0}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.FlowControlReceivedTooMuchData))
+			// reading the data extends the window
+			_, err = io.ReadFull(str, make([]byte, window))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(windowUpdateQueued).To(BeTrue())
+			Expect(str.getWindowUpdate()).To(Equal(protocol.ByteCount(2 * window)))
+		})
 	})
 })
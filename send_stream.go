@@ -19,6 +19,8 @@ type sendStreamI interface {
 	popStreamFrame(maxBytes protocol.ByteCount) (*wire.StreamFrame, bool)
 	closeForShutdown(error)
 	handleMaxStreamDataFrame(*wire.MaxStreamDataFrame)
+	writeCanceled() bool
+	closeError() error
 }
 
 type sendStream struct {
@@ -44,6 +46,8 @@ type sendStream struct {
 
 	writeChan chan struct{}
 	deadline  time.Time
+	// flushChan is closed (and reset) when the pending data was popped, or can't be sent any more
+	flushChan chan struct{}
 
 	flowController flowcontrol.StreamFlowController
 
@@ -94,19 +98,41 @@ func (s *sendStream) Write(p []byte) (int, error) {
 		return 0, nil
 	}
 
-	s.dataForWriting = p
+	// While the sender holds back stream data to coalesce small writes, these writes are buffered,
+	// so that subsequent writes on this stream can be sent in the same packet.
+	if s.streamID != s.version.CryptoStreamID() &&
+		protocol.ByteCount(len(s.dataForWriting)+len(p)) <= protocol.MaxCoalescedWriteSize &&
+		s.sender.coalescesWrites() {
+		s.dataForWriting = append(s.dataForWriting, p...)
+		s.mutex.Unlock()
+		s.sender.onHasStreamData(s.streamID) // must be called without holding the mutex
+		s.mutex.Lock()
+		return len(p), nil
+	}
 
 	var (
 		deadlineTimer  *utils.Timer
 		bytesWritten   int
 		notifiedSender bool
+		// data buffered by previous writes has to be popped before p is written
+		pending = p
 	)
 	for {
-		bytesWritten = len(p) - len(s.dataForWriting)
+		if pending != nil && s.dataForWriting == nil {
+			s.dataForWriting = pending
+			pending = nil
+			notifiedSender = false
+		}
+		if pending == nil {
+			bytesWritten = len(p) - len(s.dataForWriting)
+		}
 		deadline := s.deadline
 		if !deadline.IsZero() {
 			if !time.Now().Before(deadline) {
-				s.dataForWriting = nil
+				if pending == nil {
+					s.dataForWriting = nil
+					s.signalFlushed()
+				}
 				return bytesWritten, errDeadline
 			}
 			if deadlineTimer == nil {
@@ -114,7 +140,7 @@ func (s *sendStream) Write(p []byte) (int, error) {
 			}
 			deadlineTimer.Reset(deadline)
 		}
-		if s.dataForWriting == nil || s.canceledWrite || s.closedForShutdown {
+		if (pending == nil && s.dataForWriting == nil) || s.canceledWrite || s.closedForShutdown {
 			break
 		}
 
@@ -190,6 +216,7 @@ func (s *sendStream) popStreamFrameImpl(maxBytes protocol.ByteCount) (bool /* co
 	}
 	if frame.FinBit {
 		s.finSent = true
+		s.signalFlushed()
 	}
 	return frame.FinBit, frame, s.dataForWriting != nil
 }
@@ -223,6 +250,7 @@ func (s *sendStream) getDataForWriting(maxBytes protocol.ByteCount) ([]byte, boo
 		copy(ret, s.dataForWriting)
 		s.dataForWriting = nil
 		s.signalWrite()
+		s.signalFlushed()
 	}
 	s.writeOffset += protocol.ByteCount(len(ret))
 	s.flowController.AddBytesSent(protocol.ByteCount(len(ret)))
@@ -243,6 +271,38 @@ func (s *sendStream) Close() error {
 	return nil
 }
 
+func (s *sendStream) Flush() error {
+	s.mutex.Lock()
+	for s.hasPendingData() && !s.canceledWrite && !s.closedForShutdown {
+		if s.flushChan == nil {
+			s.flushChan = make(chan struct{})
+		}
+		flushChan := s.flushChan
+		s.mutex.Unlock()
+		s.sender.flush() // must be called without holding the mutex
+		<-flushChan
+		s.mutex.Lock()
+	}
+	if s.closeForShutdownErr != nil {
+		s.mutex.Unlock()
+		return s.closeForShutdownErr
+	}
+	if s.cancelWriteErr != nil {
+		s.mutex.Unlock()
+		return s.cancelWriteErr
+	}
+	s.mutex.Unlock()
+	// The packet containing the last STREAM frame might not have been written yet.
+	<-s.sender.flush()
+	return nil
+}
+
+// hasPendingData says if there's data or a FIN that has not been popped yet.
+// It must be called with the mutex held.
+func (s *sendStream) hasPendingData() bool {
+	return s.dataForWriting != nil || (s.finishedWriting && !s.finSent)
+}
+
 func (s *sendStream) CancelWrite(errorCode protocol.ApplicationErrorCode) error {
 	s.mutex.Lock()
 	completed, err := s.cancelWriteImpl(errorCode, fmt.Errorf("Write on stream %d canceled with error code %d", s.streamID, errorCode))
@@ -265,16 +325,31 @@ func (s *sendStream) cancelWriteImpl(errorCode protocol.ApplicationErrorCode, wr
 	s.canceledWrite = true
 	s.cancelWriteErr = writeErr
 	s.signalWrite()
+	s.signalFlushed()
 	s.sender.queueControlFrame(&wire.RstStreamFrame{
 		StreamID:   s.streamID,
 		ByteOffset: s.writeOffset,
 		ErrorCode:  errorCode,
 	})
-	// TODO(#991): cancel retransmissions for this stream
 	s.ctxCancel()
 	return true, nil
 }
 
+// writeCanceled says if writing was canceled, either locally or by a STOP_SENDING frame.
+// Lost STREAM frames for a canceled stream are not retransmitted.
+func (s *sendStream) writeCanceled() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.canceledWrite
+}
+
+// closeError returns the error that writing was canceled with, or nil if the stream was closed cleanly.
+func (s *sendStream) closeError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cancelWriteErr
+}
+
 func (s *sendStream) handleStopSendingFrame(frame *wire.StopSendingFrame) {
 	if completed := s.handleStopSendingFrameImpl(frame); completed {
 		s.sender.onStreamCompleted(s.streamID)
@@ -327,6 +402,7 @@ func (s *sendStream) closeForShutdown(err error) {
 	s.mutex.Lock()
 	s.closedForShutdown = true
 	s.closeForShutdownErr = err
+	s.signalFlushed()
 	s.mutex.Unlock()
 	s.signalWrite()
 	s.ctxCancel()
@@ -343,3 +419,12 @@ func (s *sendStream) signalWrite() {
 	default:
 	}
 }
+
+// signalFlushed unblocks calls to Flush that wait for the pending data to be popped.
+// It must be called with the mutex held.
+func (s *sendStream) signalFlushed() {
+	if s.flushChan != nil {
+		close(s.flushChan)
+		s.flushChan = nil
+	}
+}
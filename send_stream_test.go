@@ -8,8 +8,11 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 
 	. "github.com/onsi/ginkgo"
@@ -29,6 +32,7 @@ var _ = Describe("Send Stream", func() {
 
 	BeforeEach(func() {
 		mockSender = NewMockStreamSender(mockCtrl)
+		mockSender.EXPECT().coalescesWrites().AnyTimes()
 		mockFC = mocks.NewMockStreamFlowController(mockCtrl)
 		str = newSendStream(streamID, mockSender, mockFC, protocol.VersionWhatever)
 
@@ -101,6 +105,29 @@ var _ = Describe("Send Stream", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("blocks Write until all data has been sent", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			frameHeaderLen := protocol.ByteCount(4)
+			mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(9999)).Times(2)
+			mockFC.EXPECT().AddBytesSent(gomock.Any()).Times(2)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				n, err := str.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				close(done)
+			}()
+			waitForWrite()
+			Consistently(done).ShouldNot(BeClosed())
+			f, _ := str.popStreamFrame(3 + frameHeaderLen)
+			Expect(f.Data).To(Equal([]byte("foo")))
+			Consistently(done).ShouldNot(BeClosed())
+			f, _ = str.popStreamFrame(100)
+			Expect(f.Data).To(Equal([]byte("bar")))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("popStreamFrame returns nil if no data is available", func() {
 			frame, hasMoreData := str.popStreamFrame(1000)
 			Expect(frame).To(BeNil())
@@ -236,6 +263,35 @@ var _ = Describe("Send Stream", func() {
 				str.closeForShutdown(nil)
 				Eventually(done).Should(BeClosed())
 			})
+
+			It("queues only one BLOCKED frame when the flow control window is filled", func() {
+				connFC := flowcontrol.NewConnectionFlowController(1000, 1000, func() {}, &congestion.RTTStats{}, utils.DefaultLogger)
+				connFC.UpdateSendWindow(1000)
+				str = newSendStream(streamID, mockSender, flowcontrol.NewStreamFlowController(streamID, true, connFC, 1000, 1000, 3, func(protocol.StreamID) {}, &congestion.RTTStats{}, utils.DefaultLogger), protocol.VersionWhatever)
+				mockSender.EXPECT().onHasStreamData(streamID)
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := str.Write([]byte("foobar"))
+					Expect(err).ToNot(HaveOccurred())
+					close(done)
+				}()
+				waitForWrite()
+				f, _ := str.popStreamFrame(1000)
+				Expect(f.Data).To(Equal([]byte("foo")))
+				mockSender.EXPECT().queueControlFrame(&wire.StreamBlockedFrame{
+					StreamID: streamID,
+					Offset:   3,
+				})
+				f, _ = str.popStreamFrame(1000)
+				Expect(f).To(BeNil())
+				// still blocked at the same offset, no new BLOCKED frame
+				f, _ = str.popStreamFrame(1000)
+				Expect(f).To(BeNil())
+				// make the Write go routine return
+				str.closeForShutdown(nil)
+				Eventually(done).Should(BeClosed())
+			})
 		})
 
 		Context("deadlines", func() {
@@ -487,6 +543,181 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	Context("flushing", func() {
+		It("flushes the data of a blocked Write, and waits until it was written", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				str.Write([]byte("foobar"))
+				close(done)
+			}()
+			waitForWrite()
+			written := make(chan struct{})
+			mockSender.EXPECT().flush().Return(written).Times(2)
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(str.Flush()).To(Succeed())
+				close(flushed)
+			}()
+			Consistently(flushed).ShouldNot(BeClosed())
+			mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(9999))
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			f, _ := str.popStreamFrame(1000)
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Eventually(done).Should(BeClosed())
+			// the packet containing the STREAM frame was not yet written
+			Consistently(flushed).ShouldNot(BeClosed())
+			close(written)
+			Eventually(flushed).Should(BeClosed())
+		})
+
+		It("flushes the FIN", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			Expect(str.Close()).To(Succeed())
+			written := make(chan struct{})
+			close(written)
+			mockSender.EXPECT().flush().Return(written).Times(2)
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(str.Flush()).To(Succeed())
+				close(flushed)
+			}()
+			Consistently(flushed).ShouldNot(BeClosed())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			f, _ := str.popStreamFrame(1000)
+			Expect(f.FinBit).To(BeTrue())
+			Eventually(flushed).Should(BeClosed())
+		})
+
+		It("waits for the last packet to be written if there's no pending data", func() {
+			written := make(chan struct{})
+			mockSender.EXPECT().flush().Return(written)
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(str.Flush()).To(Succeed())
+				close(flushed)
+			}()
+			Consistently(flushed).ShouldNot(BeClosed())
+			close(written)
+			Eventually(flushed).Should(BeClosed())
+		})
+
+		It("returns when the stream is closed for shutdown", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			Expect(str.Close()).To(Succeed())
+			mockSender.EXPECT().flush()
+			testErr := errors.New("test error")
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(str.Flush()).To(MatchError(testErr))
+				close(flushed)
+			}()
+			Consistently(flushed).ShouldNot(BeClosed())
+			str.closeForShutdown(testErr)
+			Eventually(flushed).Should(BeClosed())
+		})
+
+		It("returns when writing is canceled", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				str.Write([]byte("foobar"))
+				close(done)
+			}()
+			waitForWrite()
+			mockSender.EXPECT().flush()
+			flushed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(str.Flush()).To(MatchError("Write on stream 1337 canceled with error code 1234"))
+				close(flushed)
+			}()
+			Consistently(flushed).ShouldNot(BeClosed())
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(str.CancelWrite(1234)).To(Succeed())
+			Eventually(flushed).Should(BeClosed())
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("coalescing writes", func() {
+		BeforeEach(func() {
+			mockSender = NewMockStreamSender(mockCtrl)
+			mockSender.EXPECT().coalescesWrites().Return(true).AnyTimes()
+			str = newSendStream(streamID, mockSender, mockFC, protocol.VersionWhatever)
+		})
+
+		It("buffers small writes", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).Times(3)
+			data := []byte("foo")
+			n, err := str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(3))
+			copy(data, "bar") // the stream must have copied the data
+			n, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(3))
+			_, err = str.Write([]byte("baz"))
+			Expect(err).ToNot(HaveOccurred())
+			mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(9999))
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(9))
+			f, hasMoreData := str.popStreamFrame(1000)
+			Expect(f.Data).To(Equal([]byte("foobarbaz")))
+			Expect(hasMoreData).To(BeFalse())
+		})
+
+		It("blocks large writes until the buffered data was popped", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).Times(3)
+			_, err := str.Write([]byte("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			data := bytes.Repeat([]byte{'f'}, int(protocol.MaxCoalescedWriteSize))
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				n, err := str.Write(data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(len(data)))
+				close(done)
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(9999)).Times(2)
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(3))
+			f, _ := str.popStreamFrame(protocol.MaxCoalescedWriteSize + 100)
+			Expect(f.Data).To(Equal([]byte("foo")))
+			waitForWrite()
+			Expect(done).ToNot(BeClosed())
+			mockFC.EXPECT().AddBytesSent(protocol.MaxCoalescedWriteSize)
+			f, _ = str.popStreamFrame(protocol.MaxCoalescedWriteSize + 100)
+			Expect(f.Data).To(Equal(data))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't buffer writes on the crypto stream", func() {
+			str = newSendStream(protocol.VersionWhatever.CryptoStreamID(), mockSender, mockFC, protocol.VersionWhatever)
+			mockSender.EXPECT().onHasStreamData(protocol.VersionWhatever.CryptoStreamID())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := str.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				close(done)
+			}()
+			waitForWrite()
+			Consistently(done).ShouldNot(BeClosed())
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			f, _ := str.popStreamFrame(1000)
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
 	Context("handling MAX_STREAM_DATA frames", func() {
 		It("informs the flow controller", func() {
 			mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(0x1337))
@@ -531,6 +762,14 @@ var _ = Describe("Send Stream", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("reports that writing was canceled", func() {
+				mockSender.EXPECT().queueControlFrame(gomock.Any())
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				Expect(str.writeCanceled()).To(BeFalse())
+				Expect(str.CancelWrite(9876)).To(Succeed())
+				Expect(str.writeCanceled()).To(BeTrue())
+			})
+
 			It("unblocks Write", func() {
 				mockSender.EXPECT().onHasStreamData(streamID)
 				mockSender.EXPECT().onStreamCompleted(streamID)
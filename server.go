@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -42,6 +43,7 @@ type quicSession interface {
 	handlePacket(*receivedPacket)
 	GetVersion() protocol.VersionNumber
 	run() error
+	runContext(context.Context) error
 	destroy(error)
 	closeRemote(error)
 }
@@ -65,6 +67,9 @@ var _ sessionRunner = &runner{}
 type server struct {
 	mutex sync.Mutex
 
+	// sessions are closed when ctx is canceled
+	ctx context.Context
+
 	tlsConf *tls.Config
 	config  *Config
 
@@ -77,7 +82,8 @@ type server struct {
 	serverTLS   *serverTLS
 
 	certChain crypto.CertChain
-	scfg      *handshake.ServerConfig
+	// the current server config comes first, followed by the previous ones
+	scfgs []*handshake.ServerConfig
 
 	sessionHandler packetHandlerManager
 
@@ -89,17 +95,25 @@ type server struct {
 
 	sessionRunner sessionRunner
 	// set as a member, so they can be set in the tests
-	newSession func(connection, sessionRunner, protocol.VersionNumber, protocol.ConnectionID, protocol.ConnectionID, *handshake.ServerConfig, *tls.Config, *Config, utils.Logger) (quicSession, error)
+	newSession func(connection, sessionRunner, protocol.VersionNumber, protocol.ConnectionID, protocol.ConnectionID, []*handshake.ServerConfig, *tls.Config, *Config, utils.Clock, utils.Logger) (quicSession, error)
 
 	logger utils.Logger
 }
 
 var _ Listener = &server{}
+var _ ServerConfigRotator = &server{}
 var _ unknownPacketHandler = &server{}
 
 // ListenAddr creates a QUIC server listening on a given address.
 // The tls.Config must not be nil, the quic.Config may be nil.
 func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, error) {
+	return ListenAddrContext(context.Background(), addr, tlsConf, config)
+}
+
+// ListenAddrContext creates a QUIC server listening on a given address.
+// All sessions accepted by the server are closed when the context is canceled.
+// The tls.Config must not be nil, the quic.Config may be nil.
+func ListenAddrContext(ctx context.Context, addr string, tlsConf *tls.Config, config *Config) (Listener, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
@@ -108,7 +122,7 @@ func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, err
 	if err != nil {
 		return nil, err
 	}
-	serv, err := listen(conn, tlsConf, config)
+	serv, err := listen(ctx, conn, tlsConf, config)
 	if err != nil {
 		return nil, err
 	}
@@ -119,10 +133,18 @@ func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, err
 // Listen listens for QUIC connections on a given net.PacketConn.
 // The tls.Config must not be nil, the quic.Config may be nil.
 func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
-	return listen(conn, tlsConf, config)
+	return ListenContext(context.Background(), conn, tlsConf, config)
+}
+
+// ListenContext listens for QUIC connections on a given net.PacketConn.
+// All sessions accepted by the server are closed when the context is canceled.
+// A session closed that way sends a PEER_GOING_AWAY error carrying the error of the context.
+// The tls.Config must not be nil, the quic.Config may be nil.
+func ListenContext(ctx context.Context, conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
+	return listen(ctx, conn, tlsConf, config)
 }
 
-func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (*server, error) {
+func listen(ctx context.Context, conn net.PacketConn, tlsConf *tls.Config, config *Config) (*server, error) {
 	if tlsConf == nil || (len(tlsConf.Certificates) == 0 && tlsConf.GetCertificate == nil) {
 		return nil, errors.New("quic: neither Certificates nor GetCertificate set in tls.Config")
 	}
@@ -136,6 +158,12 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (*server,
 		return nil, err
 	}
 	config = populateServerConfig(config)
+	if err := validateMaxPacketSize(config.MaxPacketSize); err != nil {
+		return nil, err
+	}
+	if err := validatePacketNumberLength(config.PacketNumberLength); err != nil {
+		return nil, err
+	}
 
 	var supportsTLS bool
 	for _, v := range config.Versions {
@@ -154,11 +182,12 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (*server,
 		return nil, err
 	}
 	s := &server{
+		ctx:            ctx,
 		conn:           conn,
 		tlsConf:        tlsConf,
 		config:         config,
 		certChain:      certChain,
-		scfg:           scfg,
+		scfgs:          []*handshake.ServerConfig{scfg},
 		newSession:     newSession,
 		sessionHandler: sessionHandler,
 		sessionQueue:   make(chan Session, 5),
@@ -185,7 +214,7 @@ func (s *server) setup() {
 }
 
 func (s *server) setupTLS() error {
-	serverTLS, sessionChan, err := newServerTLS(s.conn, s.config, s.sessionRunner, s.tlsConf, s.logger)
+	serverTLS, sessionChan, err := newServerTLS(s.ctx, s.conn, s.config, s.sessionRunner, s.tlsConf, s.logger)
 	if err != nil {
 		return err
 	}
@@ -284,6 +313,20 @@ func populateServerConfig(config *Config) *Config {
 		IdleTimeout:                           idleTimeout,
 		AcceptCookie:                          vsa,
 		KeepAlive:                             config.KeepAlive,
+		IdleTimeoutRetransmittableOnly:        config.IdleTimeoutRetransmittableOnly,
+		PublicResetOnCryptoError:              config.PublicResetOnCryptoError,
+		MaxPacketSize:                         config.MaxPacketSize,
+		PacketNumberLength:                    config.PacketNumberLength,
+		MaxBytesInFlight:                      config.MaxBytesInFlight,
+		MaxAckDelay:                           config.MaxAckDelay,
+		WriteCoalescingDelay:                  config.WriteCoalescingDelay,
+		CongestionControl:                     config.CongestionControl,
+		OnConnectionWindowUpdate:              config.OnConnectionWindowUpdate,
+		OnStreamClosed:                        config.OnStreamClosed,
+		EnableDatagrams:                       config.EnableDatagrams,
+		OnDatagram:                            config.OnDatagram,
+		PacketSink:                            config.PacketSink,
+		Tracer:                                config.Tracer,
 		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
 		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
 		MaxIncomingStreams:                    maxIncomingStreams,
@@ -339,6 +382,32 @@ func (s *server) closeWithError(e error) error {
 	return s.closeWithMutex()
 }
 
+// RotateServerConfig generates a new server config
+func (s *server) RotateServerConfig() error {
+	kex, err := crypto.NewCurve25519KEX()
+	if err != nil {
+		return err
+	}
+	scfg, err := handshake.NewServerConfig(kex, s.certChain)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scfgs := append([]*handshake.ServerConfig{scfg}, s.scfgs...)
+	if len(scfgs) > protocol.MaxServerConfigs {
+		scfgs = scfgs[:protocol.MaxServerConfigs]
+	}
+	s.scfgs = scfgs
+	return nil
+}
+
+func (s *server) serverConfigs() []*handshake.ServerConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.scfgs
+}
+
 // Addr returns the server's network address
 func (s *server) Addr() net.Addr {
 	return s.conn.LocalAddr()
@@ -390,16 +459,17 @@ func (s *server) handlePacketImpl(p *receivedPacket) error {
 		hdr.Version,
 		destConnID,
 		srcConnID,
-		s.scfg,
+		s.serverConfigs(),
 		s.tlsConf,
 		s.config,
+		utils.DefaultClock{},
 		s.logger,
 	)
 	if err != nil {
 		return err
 	}
 	s.sessionHandler.Add(hdr.DestConnectionID, newServerSession(sess, s.config, s.logger))
-	go sess.run()
+	go sess.runContext(s.ctx)
 	sess.handlePacket(p)
 	return nil
 }
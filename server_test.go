@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"net"
@@ -9,11 +10,13 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -63,6 +66,93 @@ var _ = Describe("Server", func() {
 			Expect(c.Versions).To(Equal([]protocol.VersionNumber{VersionGQUIC43}))
 		})
 
+		It("copies the congestion control", func() {
+			cc := NewUnlimitedCongestionControl()
+			config := &Config{CongestionControl: func() CongestionControl { return cc }}
+			c := populateServerConfig(config)
+			Expect(c.CongestionControl).ToNot(BeNil())
+			Expect(c.CongestionControl()).To(Equal(cc))
+		})
+
+		It("copies the connection window update callback", func() {
+			var called bool
+			config := &Config{OnConnectionWindowUpdate: func(ByteCount) { called = true }}
+			c := populateServerConfig(config)
+			c.OnConnectionWindowUpdate(42)
+			Expect(called).To(BeTrue())
+		})
+
+		It("copies the max packet size", func() {
+			c := populateServerConfig(&Config{MaxPacketSize: 1300})
+			Expect(c.MaxPacketSize).To(Equal(protocol.ByteCount(1300)))
+		})
+
+		It("copies the max ACK delay", func() {
+			c := populateServerConfig(&Config{MaxAckDelay: 42 * time.Millisecond})
+			Expect(c.MaxAckDelay).To(Equal(42 * time.Millisecond))
+		})
+
+		It("copies the packet sink", func() {
+			var called bool
+			config := &Config{PacketSink: func(Direction, []byte) { called = true }}
+			c := populateServerConfig(config)
+			c.PacketSink(DirectionSent, []byte("foobar"))
+			Expect(called).To(BeTrue())
+		})
+
+		It("copies the tracer constructor", func() {
+			tracer := &recordingTracer{}
+			config := &Config{Tracer: func() Tracer { return tracer }}
+			c := populateServerConfig(config)
+			Expect(c.Tracer()).To(Equal(tracer))
+		})
+
+		It("copies the idle timeout reset policy", func() {
+			c := populateServerConfig(&Config{IdleTimeoutRetransmittableOnly: true})
+			Expect(c.IdleTimeoutRetransmittableOnly).To(BeTrue())
+		})
+
+		It("copies the public reset policy for crypto errors", func() {
+			c := populateServerConfig(&Config{PublicResetOnCryptoError: true})
+			Expect(c.PublicResetOnCryptoError).To(BeTrue())
+		})
+
+		It("copies the packet number length", func() {
+			c := populateServerConfig(&Config{PacketNumberLength: 4})
+			Expect(c.PacketNumberLength).To(Equal(4))
+		})
+
+		It("copies the maximum bytes in flight", func() {
+			c := populateServerConfig(&Config{MaxBytesInFlight: 1 << 20})
+			Expect(c.MaxBytesInFlight).To(Equal(ByteCount(1 << 20)))
+		})
+
+		It("copies the write coalescing delay", func() {
+			config := &Config{WriteCoalescingDelay: 5 * time.Millisecond}
+			c := populateServerConfig(config)
+			Expect(c.WriteCoalescingDelay).To(Equal(5 * time.Millisecond))
+		})
+
+		It("copies the OnStreamClosed callback", func() {
+			var called bool
+			config := &Config{OnStreamClosed: func(StreamID, error) { called = true }}
+			c := populateServerConfig(config)
+			c.OnStreamClosed(3, nil)
+			Expect(called).To(BeTrue())
+		})
+
+		It("copies the datagram support", func() {
+			c := populateServerConfig(&Config{EnableDatagrams: true})
+			Expect(c.EnableDatagrams).To(BeTrue())
+		})
+
+		It("copies the OnDatagram callback", func() {
+			var data []byte
+			c := populateServerConfig(&Config{OnDatagram: func(b []byte) { data = b }})
+			c.OnDatagram([]byte("foobar"))
+			Expect(data).To(Equal([]byte("foobar")))
+		})
+
 		It("uses 8 byte connection IDs if gQUIC 44 is supported", func() {
 			config := &Config{
 				Versions:           []protocol.VersionNumber{protocol.Version43, protocol.Version44},
@@ -108,20 +198,23 @@ var _ = Describe("Server", func() {
 			firstPacket    *receivedPacket
 			connID         = protocol.ConnectionID{0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6}
 			sessions       = make([]*MockQuicSession, 0)
+			sessionScfgs   [][]*handshake.ServerConfig
 			sessionHandler *MockPacketHandlerManager
 		)
 
 		BeforeEach(func() {
 			sessionHandler = NewMockPacketHandlerManager(mockCtrl)
+			sessionScfgs = nil
 			newMockSession := func(
 				_ connection,
 				runner sessionRunner,
 				_ protocol.VersionNumber,
 				connID protocol.ConnectionID,
 				_ protocol.ConnectionID,
-				_ *handshake.ServerConfig,
+				scfgs []*handshake.ServerConfig,
 				_ *tls.Config,
 				_ *Config,
+				_ utils.Clock,
 				_ utils.Logger,
 			) (quicSession, error) {
 				ExpectWithOffset(0, sessions).ToNot(BeEmpty())
@@ -129,6 +222,7 @@ var _ = Describe("Server", func() {
 				s.connID = connID
 				s.runner = runner
 				sessions = sessions[1:]
+				sessionScfgs = append(sessionScfgs, scfgs)
 				return s, nil
 			}
 			serv = &server{
@@ -171,7 +265,7 @@ var _ = Describe("Server", func() {
 			s := NewMockQuicSession(mockCtrl)
 			s.EXPECT().handlePacket(gomock.Any())
 			run := make(chan struct{})
-			s.EXPECT().run().Do(func() { close(run) })
+			s.EXPECT().runContext(gomock.Any()).Do(func(context.Context) { close(run) })
 			sessions = append(sessions, s)
 
 			sessionHandler.EXPECT().Add(connID, gomock.Any()).Do(func(cid protocol.ConnectionID, _ packetHandler) {
@@ -181,6 +275,22 @@ var _ = Describe("Server", func() {
 			Eventually(run).Should(BeClosed())
 		})
 
+		It("passes the current and the previous server configs to new sessions", func() {
+			serv.certChain = crypto.NewCertChain(testdata.GetTLSConfig())
+			Expect(serv.RotateServerConfig()).To(Succeed())
+			Expect(serv.RotateServerConfig()).To(Succeed())
+			Expect(serv.scfgs).To(HaveLen(2))
+			s := NewMockQuicSession(mockCtrl)
+			s.EXPECT().handlePacket(gomock.Any())
+			run := make(chan struct{})
+			s.EXPECT().runContext(gomock.Any()).Do(func(context.Context) { close(run) })
+			sessions = append(sessions, s)
+			sessionHandler.EXPECT().Add(connID, gomock.Any())
+			Expect(serv.handlePacketImpl(firstPacket)).To(Succeed())
+			Eventually(run).Should(BeClosed())
+			Expect(sessionScfgs).To(Equal([][]*handshake.ServerConfig{serv.scfgs}))
+		})
+
 		It("accepts new TLS sessions", func() {
 			connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
 			sess := NewMockQuicSession(mockCtrl)
@@ -202,7 +312,7 @@ var _ = Describe("Server", func() {
 			s := NewMockQuicSession(mockCtrl)
 			s.EXPECT().handlePacket(gomock.Any())
 			run := make(chan struct{})
-			s.EXPECT().run().Do(func() { close(run) })
+			s.EXPECT().runContext(gomock.Any()).Do(func(context.Context) { close(run) })
 			sessions = append(sessions, s)
 			done := make(chan struct{})
 			go func() {
@@ -225,7 +335,7 @@ var _ = Describe("Server", func() {
 			run := make(chan error, 1)
 			sess := NewMockQuicSession(mockCtrl)
 			sess.EXPECT().handlePacket(gomock.Any())
-			sess.EXPECT().run().DoAndReturn(func() error { return <-run })
+			sess.EXPECT().runContext(gomock.Any()).DoAndReturn(func(context.Context) error { return <-run })
 			sessions = append(sessions, sess)
 			done := make(chan struct{})
 			go func() {
@@ -421,7 +531,7 @@ var _ = Describe("Server", func() {
 		Expect(err).ToNot(HaveOccurred())
 		server := ln.(*server)
 		Expect(server.sessionHandler).ToNot(BeNil())
-		Expect(server.scfg).ToNot(BeNil())
+		Expect(server.scfgs).To(HaveLen(1))
 		Expect(server.config.Versions).To(Equal(supportedVersions))
 		Expect(server.config.HandshakeTimeout).To(Equal(1337 * time.Hour))
 		Expect(server.config.IdleTimeout).To(Equal(42 * time.Minute))
@@ -431,12 +541,46 @@ var _ = Describe("Server", func() {
 		Expect(ln.Close()).To(Succeed())
 	})
 
+	It("rotates the server config", func() {
+		ln, err := Listen(conn, tlsConf, &Config{})
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		serv := ln.(*server)
+		Expect(serv.scfgs).To(HaveLen(1))
+		first := serv.scfgs[0]
+		rotator, ok := ln.(ServerConfigRotator)
+		Expect(ok).To(BeTrue())
+		Expect(rotator.RotateServerConfig()).To(Succeed())
+		Expect(serv.scfgs).To(HaveLen(2))
+		Expect(serv.scfgs[0].ID).ToNot(Equal(first.ID))
+		Expect(serv.scfgs[1]).To(Equal(first))
+		// the oldest server config is dropped
+		for i := 2; i < protocol.MaxServerConfigs; i++ {
+			Expect(rotator.RotateServerConfig()).To(Succeed())
+		}
+		Expect(serv.scfgs).To(HaveLen(protocol.MaxServerConfigs))
+		Expect(serv.scfgs).To(ContainElement(first))
+		Expect(rotator.RotateServerConfig()).To(Succeed())
+		Expect(serv.scfgs).To(HaveLen(protocol.MaxServerConfigs))
+		Expect(serv.scfgs).ToNot(ContainElement(first))
+	})
+
 	It("errors when the Config contains an invalid version", func() {
 		version := protocol.VersionNumber(0x1234)
 		_, err := Listen(conn, tlsConf, &Config{Versions: []protocol.VersionNumber{version}})
 		Expect(err).To(MatchError("0x1234 is not a valid QUIC version"))
 	})
 
+	It("errors when the Config contains an invalid max packet size", func() {
+		_, err := Listen(conn, tlsConf, &Config{MaxPacketSize: 1500})
+		Expect(err).To(MatchError("invalid MaxPacketSize: 1500 (must be between 1200 and 1452)"))
+	})
+
+	It("errors when the Config contains an invalid packet number length", func() {
+		_, err := Listen(conn, tlsConf, &Config{PacketNumberLength: 5})
+		Expect(err).To(MatchError("invalid PacketNumberLength: 5 (must be 1, 2 or 4)"))
+	})
+
 	It("fills in default values if options are not set in the Config", func() {
 		ln, err := Listen(conn, tlsConf, &Config{})
 		Expect(err).ToNot(HaveOccurred())
@@ -460,6 +604,33 @@ var _ = Describe("Server", func() {
 		Expect(ln.Close()).To(Succeed())
 	})
 
+	It("closes the sessions when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		versions := []protocol.VersionNumber{protocol.Version39}
+		ln, err := ListenAddrContext(ctx, "127.0.0.1:0", testdata.GetTLSConfig(), &Config{Versions: versions})
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		sessChan := make(chan Session, 1)
+		go func() {
+			defer GinkgoRecover()
+			sess, err := ln.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			sessChan <- sess
+		}()
+		cl, err := DialAddr(ln.Addr().String(), &tls.Config{InsecureSkipVerify: true}, &Config{Versions: versions})
+		Expect(err).ToNot(HaveOccurred())
+		var sess Session
+		Eventually(sessChan).Should(Receive(&sess))
+		Consistently(sess.Context().Done()).ShouldNot(BeClosed())
+		cancel()
+		Eventually(sess.Context().Done()).Should(BeClosed())
+		// the client receives the CONNECTION_CLOSE
+		Eventually(cl.Context().Done()).Should(BeClosed())
+		code, reason := cl.CloseReason()
+		Expect(code).To(Equal(qerr.PeerGoingAway))
+		Expect(reason).To(Equal(context.Canceled.Error()))
+	})
+
 	It("errors when given no tls.Config", func() {
 		_, err := ListenAddr("127.0.0.1:0", nil, nil)
 		Expect(err).To(MatchError("quic: neither Certificates nor GetCertificate set in tls.Config"))
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"net"
@@ -19,13 +20,14 @@ type tlsSession struct {
 }
 
 type serverTLS struct {
+	ctx             context.Context
 	conn            net.PacketConn
 	config          *Config
 	mintConf        *mint.Config
 	params          *handshake.TransportParameters
 	cookieGenerator *handshake.CookieGenerator
 
-	newSession func(connection, sessionRunner, protocol.ConnectionID, protocol.ConnectionID, protocol.ConnectionID, protocol.PacketNumber, *Config, *mint.Config, <-chan handshake.TransportParameters, utils.Logger, protocol.VersionNumber) (quicSession, error)
+	newSession func(connection, sessionRunner, protocol.ConnectionID, protocol.ConnectionID, protocol.ConnectionID, protocol.PacketNumber, *Config, *mint.Config, <-chan handshake.TransportParameters, utils.Clock, utils.Logger, protocol.VersionNumber) (quicSession, error)
 
 	sessionRunner sessionRunner
 	sessionChan   chan<- tlsSession
@@ -34,6 +36,7 @@ type serverTLS struct {
 }
 
 func newServerTLS(
+	ctx context.Context,
 	conn net.PacketConn,
 	config *Config,
 	runner sessionRunner,
@@ -51,6 +54,7 @@ func newServerTLS(
 		MaxBidiStreams:              uint16(config.MaxIncomingStreams),
 		MaxUniStreams:               uint16(config.MaxIncomingUniStreams),
 		DisableMigration:            true,
+		SupportsDatagrams:           config.EnableDatagrams,
 		// TODO(#855): generate a real token
 		StatelessResetToken: bytes.Repeat([]byte{42}, 16),
 	}
@@ -61,6 +65,7 @@ func newServerTLS(
 
 	sessionChan := make(chan tlsSession)
 	s := &serverTLS{
+		ctx:             ctx,
 		conn:            conn,
 		config:          config,
 		mintConf:        mconf,
@@ -133,13 +138,14 @@ func (s *serverTLS) handleInitialImpl(p *receivedPacket) (quicSession, protocol.
 		s.config,
 		mconf,
 		extHandler.GetPeerParams(),
+		utils.DefaultClock{},
 		s.logger,
 		hdr.Version,
 	)
 	if err != nil {
 		return nil, nil, err
 	}
-	go sess.run()
+	go sess.runContext(s.ctx)
 	sess.handlePacket(p)
 	return sess, connID, nil
 }
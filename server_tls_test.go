@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"context"
 	"net"
 
 	"github.com/bifurcation/mint"
@@ -28,7 +29,7 @@ var _ = Describe("Stateless TLS handling", func() {
 			Versions: []protocol.VersionNumber{protocol.VersionTLS},
 		}
 		var err error
-		server, sessionChan, err = newServerTLS(conn, config, nil, testdata.GetTLSConfig(), utils.DefaultLogger)
+		server, sessionChan, err = newServerTLS(context.Background(), conn, config, nil, testdata.GetTLSConfig(), utils.DefaultLogger)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
@@ -98,10 +99,10 @@ var _ = Describe("Stateless TLS handling", func() {
 			data:   bytes.Repeat([]byte{0}, protocol.MinInitialPacketSize),
 		}
 		run := make(chan struct{})
-		server.newSession = func(connection, sessionRunner, protocol.ConnectionID, protocol.ConnectionID, protocol.ConnectionID, protocol.PacketNumber, *Config, *mint.Config, <-chan handshake.TransportParameters, utils.Logger, protocol.VersionNumber) (quicSession, error) {
+		server.newSession = func(connection, sessionRunner, protocol.ConnectionID, protocol.ConnectionID, protocol.ConnectionID, protocol.PacketNumber, *Config, *mint.Config, <-chan handshake.TransportParameters, utils.Clock, utils.Logger, protocol.VersionNumber) (quicSession, error) {
 			sess := NewMockQuicSession(mockCtrl)
 			sess.EXPECT().handlePacket(p)
-			sess.EXPECT().run().Do(func() { close(run) })
+			sess.EXPECT().runContext(context.Background()).Do(func(context.Context) { close(run) })
 			return sess, nil
 		}
 
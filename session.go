@@ -33,6 +33,7 @@ type streamGetter interface {
 type streamManager interface {
 	GetOrOpenSendStream(protocol.StreamID) (sendStreamI, error)
 	GetOrOpenReceiveStream(protocol.StreamID) (receiveStreamI, error)
+	GetSendStream(protocol.StreamID) sendStreamI
 	OpenStream() (Stream, error)
 	OpenUniStream() (SendStream, error)
 	OpenStreamSync() (Stream, error)
@@ -43,6 +44,7 @@ type streamManager interface {
 	UpdateLimits(*handshake.TransportParameters)
 	HandleMaxStreamIDFrame(*wire.MaxStreamIDFrame) error
 	CloseWithError(error)
+	CloseStreamsAbove(protocol.StreamID, error) []protocol.StreamID
 }
 
 type cryptoStreamHandler interface {
@@ -54,6 +56,10 @@ type divNonceSetter interface {
 	SetDiversificationNonce([]byte) error
 }
 
+type keyUpdater interface {
+	UpdateKeys() error
+}
+
 type receivedPacket struct {
 	remoteAddr net.Addr
 	header     *wire.Header
@@ -66,10 +72,23 @@ var (
 	newCryptoSetupClient = handshake.NewCryptoSetupClient
 )
 
+// errPeerGoneAway is returned when opening a new stream after the peer sent a GOAWAY frame
+var errPeerGoneAway = qerr.Error(qerr.PeerGoingAway, "the peer sent a GOAWAY frame")
+
+// errDatagramQueueFull is returned by SendDatagram when the run loop didn't send the queued datagrams yet
+var errDatagramQueueFull = errors.New("too many datagrams queued for sending")
+
+// errDatagramsDisabled is returned by SendDatagram when Config.EnableDatagrams is not set
+var errDatagramsDisabled = errors.New("DATAGRAM frames are not enabled")
+
+// errPeerDoesntSupportDatagrams is returned by SendDatagram when the peer didn't announce support for DATAGRAM frames
+var errPeerDoesntSupportDatagrams = errors.New("the peer doesn't support DATAGRAM frames")
+
 type closeError struct {
-	err       error
-	remote    bool
-	sendClose bool
+	err         error
+	remote      bool
+	sendClose   bool
+	publicReset bool
 }
 
 // A Session is a QUIC session
@@ -103,6 +122,27 @@ type session struct {
 
 	receivedPackets  chan *receivedPacket
 	sendingScheduled chan struct{}
+	// openStreams are the streams that were opened, but not closed yet.
+	// They are only tracked if Config.OnStreamClosed is set.
+	openStreamsMutex sync.Mutex
+	openStreams      map[protocol.StreamID]struct{}
+	// coalescingScheduled signals that stream data was written while the WriteCoalescingDelay is used.
+	coalescingScheduled chan struct{}
+	coalescingDeadline  time.Time
+	// datagramQueue holds the data passed to SendDatagram, until the run loop sends it.
+	datagramQueue chan []byte
+	// maxDatagramSize is updated by the run loop when the maximum packet size changes.
+	// Reads from other go routines need to hold the maxDatagramSizeMutex.
+	maxDatagramSizeMutex sync.Mutex
+	maxDatagramSize      protocol.ByteCount
+	// connectionDeadlineChan is used to pass the deadline set by SetConnectionDeadline to the run loop.
+	connectionDeadlineChan chan time.Time
+	connectionDeadline     time.Time
+	// flushWaiters are closed by the run loop after it wrote the packets it packed, see flush.
+	// runLoopDone is set when the run loop exits. Both are protected by the flushMutex.
+	flushMutex   sync.Mutex
+	flushWaiters []chan struct{}
+	runLoopDone  bool
 	// closeChan is used to notify the run loop that it should terminate.
 	closeChan chan closeError
 	closeOnce sync.Once
@@ -125,6 +165,7 @@ type session struct {
 	receivedFirstPacket              bool // since packet numbers start at 0, we can't use largestRcvdPacketNumber != 0 for this
 	receivedFirstForwardSecurePacket bool
 	lastRcvdPacketNumber             protocol.PacketNumber
+	lastRcvdPacketTime               time.Time
 	// Used to calculate the next packet number from the truncated wire
 	// representation, and sent back in public reset packets
 	largestRcvdPacketNumber protocol.PacketNumber
@@ -134,8 +175,43 @@ type session struct {
 	// pacingDeadline is the time when the next packet should be sent
 	pacingDeadline time.Time
 
-	peerParams *handshake.TransportParameters
-
+	// peerParams is only written by the run loop, reads from other go routines need to hold the peerParamsMutex
+	peerParamsMutex sync.Mutex
+	peerParams      *handshake.TransportParameters
+
+	// rttStatsSnapshot is a copy of the rttStats, updated by the run loop after processing an ACK.
+	// Reads from other go routines need to hold the rttStatsMutex.
+	rttStatsMutex    sync.Mutex
+	rttStatsSnapshot RTTStats
+
+	// bytesInFlight is a copy of the bytes in flight of the sentPacketHandler, updated by the run loop.
+	// Reads from other go routines need to hold the bytesInFlightMutex.
+	bytesInFlightMutex sync.Mutex
+	bytesInFlight      protocol.ByteCount
+
+	// peerGoneAway is set by the run loop when the peer sends a GOAWAY frame.
+	// Reads from other go routines need to hold the goawayMutex.
+	goawayMutex  sync.Mutex
+	peerGoneAway bool
+
+	// peerCloseFrame is set by the run loop when the peer closes the connection.
+	// Reads from other go routines need to hold the peerCloseMutex.
+	peerCloseMutex sync.Mutex
+	peerCloseFrame *wire.ConnectionCloseFrame
+
+	// pathChallenge is the outstanding PATH_CHALLENGE sent to pathChallengeAddr at pathChallengeTime.
+	// We only migrate to this address after receiving a matching PATH_RESPONSE.
+	// PATH_CHALLENGE frames are not retransmitted. If no PATH_RESPONSE arrives in time,
+	// the next packet from pathChallengeAddr triggers a new PATH_CHALLENGE with new data.
+	pathChallenge     *wire.PathChallengeFrame
+	pathChallengeAddr net.Addr
+	pathChallengeTime time.Time
+
+	// tracer is nil if Config.Tracer is not set
+	tracer Tracer
+
+	// clock is used for all timers of the session, it can be replaced in tests
+	clock utils.Clock
 	timer *utils.Timer
 	// keepAlivePingSent stores whether a Ping frame was sent to the peer or not
 	// it is reset as soon as we receive a packet from the peer
@@ -154,9 +230,10 @@ func newSession(
 	v protocol.VersionNumber,
 	destConnID protocol.ConnectionID,
 	srcConnID protocol.ConnectionID,
-	scfg *handshake.ServerConfig,
+	scfgs []*handshake.ServerConfig,
 	tlsConf *tls.Config,
 	config *Config,
+	clock utils.Clock,
 	logger utils.Logger,
 ) (quicSession, error) {
 	logger.Debugf("Creating new session. Destination Connection ID: %s, Source Connection ID: %s", destConnID, srcConnID)
@@ -172,6 +249,7 @@ func newSession(
 		config:         config,
 		handshakeEvent: handshakeEvent,
 		paramsChan:     paramsChan,
+		clock:          clock,
 		logger:         logger,
 	}
 	s.preSetup()
@@ -180,6 +258,7 @@ func newSession(
 		ConnectionFlowControlWindow: protocol.ReceiveConnectionFlowControlWindow,
 		MaxStreams:                  uint32(s.config.MaxIncomingStreams),
 		IdleTimeout:                 s.config.IdleTimeout,
+		SupportsDatagrams:           s.config.EnableDatagrams,
 	}
 	divNonce := make([]byte, 32)
 	if _, err := rand.Read(divNonce); err != nil {
@@ -191,7 +270,7 @@ func newSession(
 		s.conn.RemoteAddr(),
 		s.version,
 		divNonce,
-		scfg,
+		scfgs,
 		transportParams,
 		s.config.Versions,
 		s.config.AcceptCookie,
@@ -209,8 +288,8 @@ func newSession(
 	s.packer = newPacketPackerLegacy(
 		destConnID,
 		srcConnID,
-		s.sentPacketHandler.GetPacketNumberLen,
-		s.RemoteAddr(),
+		s.getPacketNumberLen,
+		s.maxPacketSize(),
 		divNonce,
 		s.cryptoStream,
 		cs,
@@ -233,6 +312,7 @@ var newClientSession = func(
 	config *Config,
 	initialVersion protocol.VersionNumber,
 	negotiatedVersions []protocol.VersionNumber, // needed for validation of the GQUIC version negotiation
+	clock utils.Clock,
 	logger utils.Logger,
 ) (quicSession, error) {
 	logger.Debugf("Creating new session. Destination Connection ID: %s, Source Connection ID: %s", destConnID, srcConnID)
@@ -248,6 +328,7 @@ var newClientSession = func(
 		config:         config,
 		handshakeEvent: handshakeEvent,
 		paramsChan:     paramsChan,
+		clock:          clock,
 		logger:         logger,
 	}
 	s.preSetup()
@@ -257,6 +338,7 @@ var newClientSession = func(
 		MaxStreams:                  uint32(s.config.MaxIncomingStreams),
 		IdleTimeout:                 s.config.IdleTimeout,
 		OmitConnectionID:            s.config.RequestConnectionIDOmission,
+		SupportsDatagrams:           s.config.EnableDatagrams,
 	}
 	cs, err := newCryptoSetupClient(
 		s.cryptoStream,
@@ -280,8 +362,8 @@ var newClientSession = func(
 	s.packer = newPacketPackerLegacy(
 		destConnID,
 		srcConnID,
-		s.sentPacketHandler.GetPacketNumberLen,
-		s.RemoteAddr(),
+		s.getPacketNumberLen,
+		s.maxPacketSize(),
 		nil, // no diversification nonce
 		s.cryptoStream,
 		cs,
@@ -303,6 +385,7 @@ func newTLSServerSession(
 	config *Config,
 	mintConf *mint.Config,
 	paramsChan <-chan handshake.TransportParameters,
+	clock utils.Clock,
 	logger utils.Logger,
 	v protocol.VersionNumber,
 ) (quicSession, error) {
@@ -317,6 +400,7 @@ func newTLSServerSession(
 		version:        v,
 		handshakeEvent: handshakeEvent,
 		paramsChan:     paramsChan,
+		clock:          clock,
 		logger:         logger,
 	}
 	s.preSetup()
@@ -337,8 +421,8 @@ func newTLSServerSession(
 		s.destConnID,
 		s.srcConnID,
 		initialPacketNumber,
-		s.sentPacketHandler.GetPacketNumberLen,
-		s.RemoteAddr(),
+		s.getPacketNumberLen,
+		s.maxPacketSize(),
 		nil, // no token
 		s.cryptoStream,
 		cs,
@@ -365,6 +449,7 @@ var newTLSClientSession = func(
 	mintConf *mint.Config,
 	paramsChan <-chan handshake.TransportParameters,
 	initialPacketNumber protocol.PacketNumber,
+	clock utils.Clock,
 	logger utils.Logger,
 	v protocol.VersionNumber,
 ) (quicSession, error) {
@@ -379,6 +464,7 @@ var newTLSClientSession = func(
 		version:        v,
 		handshakeEvent: handshakeEvent,
 		paramsChan:     paramsChan,
+		clock:          clock,
 		logger:         logger,
 	}
 	s.preSetup()
@@ -400,8 +486,8 @@ var newTLSClientSession = func(
 		s.destConnID,
 		s.srcConnID,
 		initialPacketNumber,
-		s.sentPacketHandler.GetPacketNumberLen,
-		s.RemoteAddr(),
+		s.getPacketNumberLen,
+		s.maxPacketSize(),
 		token,
 		s.cryptoStream,
 		cs,
@@ -415,8 +501,17 @@ var newTLSClientSession = func(
 
 func (s *session) preSetup() {
 	s.rttStats = &congestion.RTTStats{}
-	s.sentPacketHandler = ackhandler.NewSentPacketHandler(s.rttStats, s.logger, s.version)
-	s.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(s.rttStats, s.logger, s.version)
+	var congestionControl congestion.CongestionControl
+	if s.config.CongestionControl != nil {
+		congestionControl = s.config.CongestionControl()
+	}
+	var onPacketLost func(protocol.PacketNumber, protocol.ByteCount)
+	if s.config.Tracer != nil {
+		s.tracer = s.config.Tracer()
+		onPacketLost = s.tracer.LostPacket
+	}
+	s.sentPacketHandler = ackhandler.NewSentPacketHandler(s.rttStats, congestionControl, onPacketLost, s.clock, s.logger, s.version)
+	s.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(s.rttStats, s.config.MaxAckDelay, s.clock, s.logger, s.version)
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
 		protocol.ReceiveConnectionFlowControlWindow,
 		protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow),
@@ -431,11 +526,16 @@ func (s *session) postSetup() error {
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
 	s.sendingScheduled = make(chan struct{}, 1)
+	s.openStreams = make(map[protocol.StreamID]struct{})
+	s.coalescingScheduled = make(chan struct{}, 1)
+	s.connectionDeadlineChan = make(chan time.Time, 1)
+	s.datagramQueue = make(chan []byte, protocol.MaxDatagramQueueLen)
+	s.maxDatagramSize = s.packer.MaxDatagramSize()
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 
-	s.timer = utils.NewTimer()
-	now := time.Now()
+	s.timer = utils.NewTimerWithClock(s.clock)
+	now := s.clock.Now()
 	s.lastNetworkActivityTime = now
 	s.sessionCreationTime = now
 
@@ -445,15 +545,26 @@ func (s *session) postSetup() error {
 
 // run the session main loop
 func (s *session) run() error {
+	return s.runContext(context.Background())
+}
+
+// runContext runs the session main loop until the session is closed.
+// Canceling ctx closes the session gracefully, with an error derived from ctx.Err().
+func (s *session) runContext(ctx context.Context) error {
 	defer s.ctxCancel()
 
 	go func() {
 		if err := s.cryptoStreamHandler.HandleCryptoStream(); err != nil {
-			s.closeLocal(err)
+			if s.config.PublicResetOnCryptoError && s.perspective == protocol.PerspectiveServer && !s.version.UsesTLS() {
+				s.closeWithPublicReset(err)
+			} else {
+				s.closeLocal(err)
+			}
 		}
 	}()
 
 	var closeErr closeError
+	ctxDone := ctx.Done()
 
 runLoop:
 	for {
@@ -468,6 +579,7 @@ runLoop:
 		default:
 		}
 
+		s.updateBytesInFlight()
 		s.maybeResetTimer()
 
 		select {
@@ -480,7 +592,16 @@ runLoop:
 		case <-s.sendingScheduled:
 			// We do all the interesting stuff after the switch statement, so
 			// nothing to see here.
+		case <-s.coalescingScheduled:
+			// Wait for more stream data, it will be sent when the timer fires.
+			if s.coalescingDeadline.IsZero() {
+				s.coalescingDeadline = s.clock.Now().Add(s.config.WriteCoalescingDelay)
+			}
+			continue
 		case p := <-s.receivedPackets:
+			if s.config.PacketSink != nil {
+				s.config.PacketSink(DirectionReceived, append(append([]byte{}, p.header.Raw...), p.data...))
+			}
 			err := s.handlePacketImpl(p)
 			if err != nil {
 				if qErr, ok := err.(*qerr.QuicError); ok && qErr.ErrorCode == qerr.DecryptionFailure {
@@ -496,12 +617,19 @@ runLoop:
 		case p := <-s.paramsChan:
 			s.processTransportParameters(&p)
 			continue
+		case <-ctxDone:
+			ctxDone = nil
+			s.closeLocal(qerr.Error(qerr.PeerGoingAway, ctx.Err().Error()))
+			continue
+		case s.connectionDeadline = <-s.connectionDeadlineChan:
 		case _, ok := <-s.handshakeEvent:
 			// when the handshake is completed, the channel will be closed
 			s.handleHandshakeEvent(!ok)
 		}
 
-		now := time.Now()
+		now := s.clock.Now()
+		// Stream data held back for coalescing is sent together with the next packet.
+		s.coalescingDeadline = time.Time{}
 		if timeout := s.sentPacketHandler.GetAlarmTimeout(); !timeout.IsZero() && timeout.Before(now) {
 			// This could cause packets to be retransmitted.
 			// Check it before trying to send packets.
@@ -514,7 +642,7 @@ runLoop:
 		if s.pacingDeadline.IsZero() { // the timer didn't have a pacing deadline set
 			pacingDeadline = s.sentPacketHandler.TimeUntilSend()
 		}
-		if s.config.KeepAlive && !s.keepAlivePingSent && s.handshakeComplete && time.Since(s.lastNetworkActivityTime) >= s.peerParams.IdleTimeout/2 {
+		if s.config.KeepAlive && !s.keepAlivePingSent && s.handshakeComplete && now.Sub(s.lastNetworkActivityTime) >= s.peerParams.IdleTimeout/2 {
 			// send a PING frame since there is no activity in the session
 			s.logger.Debugf("Sending a keep-alive ping to keep the connection alive.")
 			s.framer.QueueControlFrame(&wire.PingFrame{})
@@ -531,6 +659,10 @@ runLoop:
 			s.closeLocal(qerr.Error(qerr.DecryptionFailure, "too many undecryptable packets received"))
 			continue
 		}
+		if !s.connectionDeadline.IsZero() && !now.Before(s.connectionDeadline) {
+			s.closeLocal(qerr.Error(qerr.PeerGoingAway, "Connection deadline exceeded."))
+			continue
+		}
 		if !s.handshakeComplete && now.Sub(s.sessionCreationTime) >= s.config.HandshakeTimeout {
 			s.closeLocal(qerr.Error(qerr.HandshakeTimeout, "Crypto handshake did not complete in time."))
 			continue
@@ -543,12 +675,17 @@ runLoop:
 		if err := s.sendPackets(); err != nil {
 			s.closeLocal(err)
 		}
+		s.notifyFlushWaiters(false)
 	}
+	s.notifyFlushWaiters(true)
 
 	if err := s.handleCloseError(closeErr); err != nil {
 		s.logger.Infof("Handling close error failed: %s", err)
 	}
 	s.logger.Infof("Connection %s closed.", s.srcConnID)
+	if s.tracer != nil {
+		s.tracer.ClosedConnection(closeErr.err)
+	}
 	s.sessionRunner.removeConnectionID(s.srcConnID)
 	return closeErr.err
 }
@@ -561,6 +698,89 @@ func (s *session) ConnectionState() ConnectionState {
 	return s.cryptoStreamHandler.ConnectionState()
 }
 
+func (s *session) RTTStats() RTTStats {
+	s.rttStatsMutex.Lock()
+	defer s.rttStatsMutex.Unlock()
+	return s.rttStatsSnapshot
+}
+
+func (s *session) BytesInFlight() ByteCount {
+	s.bytesInFlightMutex.Lock()
+	defer s.bytesInFlightMutex.Unlock()
+	return s.bytesInFlight
+}
+
+func (s *session) updateBytesInFlight() {
+	s.bytesInFlightMutex.Lock()
+	s.bytesInFlight = s.sentPacketHandler.GetBytesInFlight()
+	s.bytesInFlightMutex.Unlock()
+}
+
+func (s *session) ConnectionParameters() ConnectionParameters {
+	s.peerParamsMutex.Lock()
+	defer s.peerParamsMutex.Unlock()
+	if s.peerParams == nil {
+		return ConnectionParameters{}
+	}
+	maxStreams := int(s.peerParams.MaxStreams)
+	if s.version.UsesIETFFrameFormat() {
+		maxStreams = int(s.peerParams.MaxBidiStreams)
+	}
+	return ConnectionParameters{
+		StreamFlowControlWindow:     s.peerParams.StreamFlowControlWindow,
+		ConnectionFlowControlWindow: s.peerParams.ConnectionFlowControlWindow,
+		MaxPacketSize:               s.peerParams.MaxPacketSize,
+		MaxStreams:                  maxStreams,
+		MaxUniStreams:               int(s.peerParams.MaxUniStreams),
+		IdleTimeout:                 s.peerParams.IdleTimeout,
+		OmitConnectionID:            s.peerParams.OmitConnectionID,
+	}
+}
+
+func (s *session) UpdateKeys() error {
+	ku, ok := s.cryptoStreamHandler.(keyUpdater)
+	if !ok {
+		return errors.New("key updates are only supported for IETF QUIC")
+	}
+	return ku.UpdateKeys()
+}
+
+func (s *session) SetConnectionDeadline(t time.Time) {
+	select {
+	case <-s.connectionDeadlineChan: // drop a deadline that wasn't processed by the run loop yet
+	default:
+	}
+	select {
+	case s.connectionDeadlineChan <- t:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *session) SendDatagram(data []byte) error {
+	if !s.config.EnableDatagrams {
+		return errDatagramsDisabled
+	}
+	s.peerParamsMutex.Lock()
+	peerParams := s.peerParams
+	s.peerParamsMutex.Unlock()
+	if peerParams != nil && !peerParams.SupportsDatagrams {
+		return errPeerDoesntSupportDatagrams
+	}
+	s.maxDatagramSizeMutex.Lock()
+	maxSize := s.maxDatagramSize
+	s.maxDatagramSizeMutex.Unlock()
+	if protocol.ByteCount(len(data)) > maxSize {
+		return fmt.Errorf("datagram too large: %d bytes (maximum %d bytes)", len(data), maxSize)
+	}
+	select {
+	case s.datagramQueue <- append([]byte{}, data...):
+	default:
+		return errDatagramQueueFull
+	}
+	s.scheduleSending()
+	return nil
+}
+
 func (s *session) maybeResetTimer() {
 	var deadline time.Time
 	if s.config.KeepAlive && s.handshakeComplete && !s.keepAlivePingSent {
@@ -585,6 +805,12 @@ func (s *session) maybeResetTimer() {
 	if !s.pacingDeadline.IsZero() {
 		deadline = utils.MinTime(deadline, s.pacingDeadline)
 	}
+	if !s.coalescingDeadline.IsZero() {
+		deadline = utils.MinTime(deadline, s.coalescingDeadline)
+	}
+	if !s.connectionDeadline.IsZero() {
+		deadline = utils.MinTime(deadline, s.connectionDeadline)
+	}
 
 	s.timer.Reset(deadline)
 }
@@ -597,6 +823,9 @@ func (s *session) handleHandshakeEvent(completed bool) {
 	s.handshakeComplete = true
 	s.handshakeEvent = nil // prevent this case from ever being selected again
 	s.sessionRunner.onHandshakeComplete(s)
+	if s.tracer != nil {
+		s.tracer.CompletedHandshake()
+	}
 
 	// In gQUIC, the server completes the handshake first (after sending the SHLO).
 	// In TLS 1.3, the client completes the handshake first (after sending the CFIN).
@@ -630,7 +859,7 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 
 	if p.rcvTime.IsZero() {
 		// To simplify testing
-		p.rcvTime = time.Now()
+		p.rcvTime = s.clock.Now()
 	}
 
 	// Calculate packet number
@@ -662,9 +891,16 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 		s.packer.ChangeDestConnectionID(s.destConnID)
 	}
 
+	if s.tracer != nil {
+		s.tracer.ReceivedPacket(hdr.PacketNumber, protocol.ByteCount(len(hdr.Raw)+len(p.data)), packet.encryptionLevel)
+	}
+
 	s.receivedFirstPacket = true
-	s.lastNetworkActivityTime = p.rcvTime
-	s.keepAlivePingSent = false
+	isRetransmittable := ackhandler.HasRetransmittableFrames(packet.frames)
+	if !s.config.IdleTimeoutRetransmittableOnly || isRetransmittable {
+		s.lastNetworkActivityTime = p.rcvTime
+		s.keepAlivePingSent = false
+	}
 
 	// In gQUIC, the server completes the handshake first (after sending the SHLO).
 	// In TLS 1.3, the client completes the handshake first (after sending the CFIN).
@@ -678,21 +914,58 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 	}
 
 	s.lastRcvdPacketNumber = hdr.PacketNumber
+	s.lastRcvdPacketTime = p.rcvTime
 	// Only do this after decrypting, so we are sure the packet is not attacker-controlled
 	s.largestRcvdPacketNumber = utils.MaxPacketNumber(s.largestRcvdPacketNumber, hdr.PacketNumber)
 
 	// If this is a Retry packet, there's no need to send an ACK.
 	// The session will be closed and recreated as soon as the crypto setup processed the HRR.
 	if hdr.Type != protocol.PacketTypeRetry {
-		isRetransmittable := ackhandler.HasRetransmittableFrames(packet.frames)
 		if err := s.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, p.rcvTime, isRetransmittable); err != nil {
 			return err
 		}
+		// Handshake packets are acknowledged right away, so that the handshake isn't delayed by the ACK timer.
+		if isRetransmittable && packet.encryptionLevel < protocol.EncryptionForwardSecure {
+			s.receivedPacketHandler.QueueAck()
+		}
+	}
+
+	if s.version.UsesIETFFrameFormat() && p.remoteAddr != nil {
+		if err := s.maybeValidatePath(p.remoteAddr); err != nil {
+			return err
+		}
 	}
 
 	return s.handleFrames(packet.frames, packet.encryptionLevel)
 }
 
+// maybeValidatePath sends a PATH_CHALLENGE when a packet is received from a new remote address
+func (s *session) maybeValidatePath(addr net.Addr) error {
+	if isSameAddr(addr, s.conn.RemoteAddr()) {
+		return nil
+	}
+	if s.pathChallenge != nil && isSameAddr(addr, s.pathChallengeAddr) &&
+		s.clock.Now().Before(s.pathChallengeTime.Add(3*s.rttStats.SmoothedOrInitialRTT())) {
+		return nil
+	}
+	frame := &wire.PathChallengeFrame{}
+	if _, err := rand.Read(frame.Data[:]); err != nil {
+		return err
+	}
+	packet, err := s.packer.PackPathChallenge(frame)
+	if err != nil {
+		return err
+	}
+	s.logger.Debugf("Received a packet from %s. Validating the path.", addr)
+	s.pathChallenge = frame
+	s.pathChallengeAddr = addr
+	s.pathChallengeTime = s.clock.Now()
+	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+	defer putPacketBuffer(&packet.raw)
+	s.logPacket(packet)
+	return s.writePacketTo(packet.raw, addr)
+}
+
 func (s *session) handleFrames(fs []wire.Frame, encLevel protocol.EncryptionLevel) error {
 	for _, ff := range fs {
 		var err error
@@ -703,9 +976,9 @@ func (s *session) handleFrames(fs []wire.Frame, encLevel protocol.EncryptionLeve
 		case *wire.AckFrame:
 			err = s.handleAckFrame(frame, encLevel)
 		case *wire.ConnectionCloseFrame:
-			s.closeRemote(qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
+			s.handleConnectionCloseFrame(frame)
 		case *wire.GoawayFrame:
-			err = errors.New("unimplemented: handling GOAWAY frames")
+			s.handleGoawayFrame(frame)
 		case *wire.StopWaitingFrame: // ignore STOP_WAITINGs
 		case *wire.RstStreamFrame:
 			err = s.handleRstStreamFrame(frame)
@@ -724,8 +997,9 @@ func (s *session) handleFrames(fs []wire.Frame, encLevel protocol.EncryptionLeve
 		case *wire.PathChallengeFrame:
 			s.handlePathChallengeFrame(frame)
 		case *wire.PathResponseFrame:
-			// since we don't send PATH_CHALLENGEs, we don't expect PATH_RESPONSEs
-			err = errors.New("unexpected PATH_RESPONSE frame")
+			err = s.handlePathResponseFrame(frame)
+		case *wire.DatagramFrame:
+			err = s.handleDatagramFrame(frame)
 		default:
 			return errors.New("Session BUG: unexpected frame type")
 		}
@@ -765,11 +1039,15 @@ func (s *session) handleStreamFrame(frame *wire.StreamFrame, encLevel protocol.E
 		// ignore this StreamFrame
 		return nil
 	}
+	str.setEncryptionLevel(encLevel)
 	return str.handleStreamFrame(frame)
 }
 
 func (s *session) handleMaxDataFrame(frame *wire.MaxDataFrame) {
 	s.connFlowController.UpdateSendWindow(frame.ByteOffset)
+	if s.config.OnConnectionWindowUpdate != nil {
+		s.config.OnConnectionWindowUpdate(frame.ByteOffset)
+	}
 }
 
 func (s *session) handleMaxStreamDataFrame(frame *wire.MaxStreamDataFrame) error {
@@ -789,6 +1067,37 @@ func (s *session) handleMaxStreamDataFrame(frame *wire.MaxStreamDataFrame) error
 	return nil
 }
 
+func (s *session) handleConnectionCloseFrame(frame *wire.ConnectionCloseFrame) {
+	s.peerCloseMutex.Lock()
+	if s.peerCloseFrame == nil {
+		s.peerCloseFrame = frame
+	}
+	s.peerCloseMutex.Unlock()
+	s.closeRemote(qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
+}
+
+// handleGoawayFrame handles a GOAWAY frame.
+// No new streams can be opened afterwards.
+// Streams up to the last good stream can still be used. The peer won't process the streams we opened after that, so they are closed.
+func (s *session) handleGoawayFrame(frame *wire.GoawayFrame) {
+	s.goawayMutex.Lock()
+	s.peerGoneAway = true
+	s.goawayMutex.Unlock()
+
+	closed := s.streamsMap.CloseStreamsAbove(frame.LastGoodStream, errPeerGoneAway)
+	if s.config.OnStreamClosed == nil {
+		return
+	}
+	s.openStreamsMutex.Lock()
+	for _, id := range closed {
+		delete(s.openStreams, id)
+	}
+	s.openStreamsMutex.Unlock()
+	for _, id := range closed {
+		s.config.OnStreamClosed(id, errPeerGoneAway)
+	}
+}
+
 func (s *session) handleMaxStreamIDFrame(frame *wire.MaxStreamIDFrame) error {
 	return s.streamsMap.HandleMaxStreamIDFrame(frame)
 }
@@ -828,10 +1137,43 @@ func (s *session) handlePathChallengeFrame(frame *wire.PathChallengeFrame) {
 	s.queueControlFrame(&wire.PathResponseFrame{Data: frame.Data})
 }
 
+func (s *session) handleDatagramFrame(frame *wire.DatagramFrame) error {
+	if !s.config.EnableDatagrams {
+		return qerr.Error(qerr.InvalidFrameData, "received a DATAGRAM frame, but DATAGRAM frames are not enabled")
+	}
+	if s.config.OnDatagram == nil {
+		s.logger.Debugf("Dropping a DATAGRAM frame, since no OnDatagram callback is set.")
+		return nil
+	}
+	s.config.OnDatagram(frame.Data)
+	return nil
+}
+
+func (s *session) handlePathResponseFrame(frame *wire.PathResponseFrame) error {
+	// This might be the response to a previous PATH_CHALLENGE, or a duplicate of a response we already processed.
+	if s.pathChallenge == nil || frame.Data != s.pathChallenge.Data {
+		s.logger.Debugf("Ignoring a PATH_RESPONSE that doesn't match the outstanding PATH_CHALLENGE.")
+		return nil
+	}
+	s.logger.Debugf("Path to %s validated. Migrating the connection.", s.pathChallengeAddr)
+	s.conn.SetCurrentRemoteAddr(s.pathChallengeAddr)
+	s.pathChallenge = nil
+	s.pathChallengeAddr = nil
+	s.pathChallengeTime = time.Time{}
+	return nil
+}
+
 func (s *session) handleAckFrame(frame *wire.AckFrame, encLevel protocol.EncryptionLevel) error {
-	if err := s.sentPacketHandler.ReceivedAck(frame, s.lastRcvdPacketNumber, encLevel, s.lastNetworkActivityTime); err != nil {
+	if err := s.sentPacketHandler.ReceivedAck(frame, s.lastRcvdPacketNumber, encLevel, s.lastRcvdPacketTime); err != nil {
 		return err
 	}
+	s.rttStatsMutex.Lock()
+	s.rttStatsSnapshot = RTTStats{
+		SmoothedRTT:   s.rttStats.SmoothedRTT(),
+		LatestRTT:     s.rttStats.LatestRTT(),
+		MeanDeviation: s.rttStats.MeanDeviation(),
+	}
+	s.rttStatsMutex.Unlock()
 	s.receivedPacketHandler.IgnoreBelow(s.sentPacketHandler.GetLowestPacketNotConfirmedAcked())
 	return nil
 }
@@ -843,6 +1185,13 @@ func (s *session) closeLocal(e error) {
 	})
 }
 
+// closeWithPublicReset closes the session and sends a PUBLIC_RESET
+func (s *session) closeWithPublicReset(e error) {
+	s.closeOnce.Do(func() {
+		s.closeChan <- closeError{err: e, sendClose: true, publicReset: true}
+	})
+}
+
 // destroy closes the session without sending the error on the wire
 func (s *session) destroy(e error) {
 	s.closeOnce.Do(func() {
@@ -889,6 +1238,18 @@ func (s *session) handleCloseError(closeErr closeError) error {
 
 	s.cryptoStream.closeForShutdown(quicErr)
 	s.streamsMap.CloseWithError(quicErr)
+	if s.config.OnStreamClosed != nil {
+		s.openStreamsMutex.Lock()
+		ids := make([]protocol.StreamID, 0, len(s.openStreams))
+		for id := range s.openStreams {
+			ids = append(ids, id)
+		}
+		s.openStreams = make(map[protocol.StreamID]struct{})
+		s.openStreamsMutex.Unlock()
+		for _, id := range ids {
+			s.config.OnStreamClosed(id, quicErr)
+		}
+	}
 
 	if !closeErr.sendClose {
 		return nil
@@ -899,7 +1260,8 @@ func (s *session) handleCloseError(closeErr closeError) error {
 		return nil
 	}
 
-	if quicErr.ErrorCode == qerr.DecryptionFailure ||
+	if closeErr.publicReset ||
+		quicErr.ErrorCode == qerr.DecryptionFailure ||
 		quicErr == handshake.ErrNSTPExperiment {
 		return s.sendPublicReset(s.lastRcvdPacketNumber)
 	}
@@ -907,9 +1269,14 @@ func (s *session) handleCloseError(closeErr closeError) error {
 }
 
 func (s *session) processTransportParameters(params *handshake.TransportParameters) {
+	s.peerParamsMutex.Lock()
 	s.peerParams = params
+	s.peerParamsMutex.Unlock()
 	s.streamsMap.UpdateLimits(params)
 	s.packer.HandleTransportParameters(params)
+	s.maxDatagramSizeMutex.Lock()
+	s.maxDatagramSize = s.packer.MaxDatagramSize()
+	s.maxDatagramSizeMutex.Unlock()
 	s.connFlowController.UpdateSendWindow(params.ConnectionFlowControlWindow)
 	// the crypto stream is the only open stream at this moment
 	// so we don't need to update stream flow control windows
@@ -918,7 +1285,7 @@ func (s *session) processTransportParameters(params *handshake.TransportParamete
 func (s *session) sendPackets() error {
 	s.pacingDeadline = time.Time{}
 
-	sendMode := s.sentPacketHandler.SendMode()
+	sendMode := s.sendMode()
 	if sendMode == ackhandler.SendNone { // shortcut: return immediately if there's nothing to send
 		return nil
 	}
@@ -957,10 +1324,16 @@ sendLoop:
 				// e.g. when an Initial is queued, but we already received a packet from the server.
 			}
 		case ackhandler.SendAny:
-			sentPacket, err := s.sendPacket()
+			sentPacket, err := s.maybeSendDatagram()
 			if err != nil {
 				return err
 			}
+			if !sentPacket {
+				sentPacket, err = s.sendPacket()
+				if err != nil {
+					return err
+				}
+			}
 			if !sentPacket {
 				break sendLoop
 			}
@@ -971,7 +1344,7 @@ sendLoop:
 		if numPacketsSent >= numPackets {
 			break
 		}
-		sendMode = s.sentPacketHandler.SendMode()
+		sendMode = s.sendMode()
 	}
 	// Only start the pacing timer if we sent as many packets as we were allowed.
 	// There will probably be more to send when calling sendPacket again.
@@ -981,6 +1354,44 @@ sendLoop:
 	return nil
 }
 
+// sendMode is the SendMode of the sentPacketHandler, additionally limited by the MaxBytesInFlight.
+// Once the limit is reached, only ACKs are sent until bytes in flight are acknowledged or declared lost.
+// Retransmissions and probe packets are not limited.
+func (s *session) sendMode() ackhandler.SendMode {
+	sendMode := s.sentPacketHandler.SendMode()
+	if sendMode == ackhandler.SendAny && s.config.MaxBytesInFlight > 0 && s.sentPacketHandler.GetBytesInFlight() >= s.config.MaxBytesInFlight {
+		s.logger.Debugf("Limited by the maximum bytes in flight: %d", s.config.MaxBytesInFlight)
+		return ackhandler.SendAck
+	}
+	return sendMode
+}
+
+// maybeSendDatagram sends the next queued datagram in a packet of its own, once the handshake has completed.
+// Packets containing only a DATAGRAM frame are not retransmitted.
+// Datagrams queued before the peer's transport parameters were known are dropped if the peer doesn't support them.
+func (s *session) maybeSendDatagram() (bool, error) {
+	if !s.handshakeComplete {
+		return false, nil
+	}
+	for {
+		select {
+		case data := <-s.datagramQueue:
+			if s.peerParams == nil || !s.peerParams.SupportsDatagrams {
+				s.logger.Debugf("Dropping a datagram, since the peer doesn't support DATAGRAM frames.")
+				continue
+			}
+			packet, err := s.packer.PackDatagram(&wire.DatagramFrame{Data: data})
+			if err != nil {
+				return false, err
+			}
+			s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket())
+			return true, s.sendPackedPacket(packet)
+		default:
+			return false, nil
+		}
+	}
+}
+
 func (s *session) maybeSendAckOnlyPacket() error {
 	packet, err := s.packer.MaybePackAckPacket()
 	if err != nil {
@@ -1010,6 +1421,13 @@ func (s *session) maybeSendRetransmission() (bool, error) {
 			s.logger.Debugf("Skipping retransmission of packet %d. Already received a response to an Initial.", retransmitPacket.PacketNumber)
 			continue
 		}
+		if retransmitPacket.EncryptionLevel == protocol.EncryptionForwardSecure {
+			retransmitPacket.Frames = s.dropFramesForCanceledStreams(retransmitPacket.Frames)
+			if len(retransmitPacket.Frames) == 0 {
+				s.logger.Debugf("Skipping retransmission of packet %d. It only contained STREAM frames for canceled streams.", retransmitPacket.PacketNumber)
+				continue
+			}
+		}
 		break
 	}
 
@@ -1036,6 +1454,22 @@ func (s *session) maybeSendRetransmission() (bool, error) {
 	return true, nil
 }
 
+// dropFramesForCanceledStreams removes all STREAM frames for streams that were canceled.
+// The peer already received (or will receive) a RST_STREAM frame for these streams.
+func (s *session) dropFramesForCanceledStreams(frames []wire.Frame) []wire.Frame {
+	j := 0
+	for _, f := range frames {
+		if sf, ok := f.(*wire.StreamFrame); ok && sf.StreamID != s.version.CryptoStreamID() {
+			if str := s.streamsMap.GetSendStream(sf.StreamID); str != nil && str.writeCanceled() {
+				continue
+			}
+		}
+		frames[j] = f
+		j++
+	}
+	return frames[:j]
+}
+
 func (s *session) sendProbePacket() error {
 	p, err := s.sentPacketHandler.DequeueProbePacket()
 	if err != nil {
@@ -1080,7 +1514,7 @@ func (s *session) sendPacket() (bool, error) {
 func (s *session) sendPackedPacket(packet *packedPacket) error {
 	defer putPacketBuffer(&packet.raw)
 	s.logPacket(packet)
-	return s.conn.Write(packet.raw)
+	return s.writePacket(packet.raw)
 }
 
 func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
@@ -1092,10 +1526,13 @@ func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
 		return err
 	}
 	s.logPacket(packet)
-	return s.conn.Write(packet.raw)
+	return s.writePacket(packet.raw)
 }
 
 func (s *session) logPacket(packet *packedPacket) {
+	if s.tracer != nil {
+		s.tracer.SentPacket(packet.header.PacketNumber, protocol.ByteCount(len(packet.raw)), packet.encryptionLevel)
+	}
 	if !s.logger.Debug() {
 		// We don't need to allocate the slices for calling the format functions
 		return
@@ -1133,27 +1570,65 @@ func (s *session) AcceptUniStream() (ReceiveStream, error) {
 
 // OpenStream opens a stream
 func (s *session) OpenStream() (Stream, error) {
+	if s.PeerGoneAway() {
+		return nil, errPeerGoneAway
+	}
 	return s.streamsMap.OpenStream()
 }
 
 func (s *session) OpenStreamSync() (Stream, error) {
+	if s.PeerGoneAway() {
+		return nil, errPeerGoneAway
+	}
 	return s.streamsMap.OpenStreamSync()
 }
 
 func (s *session) OpenUniStream() (SendStream, error) {
+	if s.PeerGoneAway() {
+		return nil, errPeerGoneAway
+	}
 	return s.streamsMap.OpenUniStream()
 }
 
 func (s *session) OpenUniStreamSync() (SendStream, error) {
+	if s.PeerGoneAway() {
+		return nil, errPeerGoneAway
+	}
 	return s.streamsMap.OpenUniStreamSync()
 }
 
+// CloseReason returns the error code and reason phrase of the CONNECTION_CLOSE frame sent by the peer.
+func (s *session) CloseReason() (qerr.ErrorCode, string) {
+	s.peerCloseMutex.Lock()
+	defer s.peerCloseMutex.Unlock()
+	if s.peerCloseFrame == nil {
+		return 0, ""
+	}
+	return s.peerCloseFrame.ErrorCode, s.peerCloseFrame.ReasonPhrase
+}
+
+// PeerGoneAway returns whether the peer sent a GOAWAY frame.
+func (s *session) PeerGoneAway() bool {
+	s.goawayMutex.Lock()
+	defer s.goawayMutex.Unlock()
+	return s.peerGoneAway
+}
+
 func (s *session) newStream(id protocol.StreamID) streamI {
 	flowController := s.newFlowController(id)
 	return newStream(id, s, flowController, s.version)
 }
 
+// newFlowController is called once for every new stream
 func (s *session) newFlowController(id protocol.StreamID) flowcontrol.StreamFlowController {
+	if s.tracer != nil {
+		s.tracer.OpenedStream(id)
+	}
+	if s.config.OnStreamClosed != nil {
+		s.openStreamsMutex.Lock()
+		s.openStreams[id] = struct{}{}
+		s.openStreamsMutex.Unlock()
+	}
 	var initialSendWindow protocol.ByteCount
 	if s.peerParams != nil {
 		initialSendWindow = s.peerParams.StreamFlowControlWindow
@@ -1189,7 +1664,36 @@ func (s *session) newCryptoStream() cryptoStream {
 
 func (s *session) sendPublicReset(rejectedPacketNumber protocol.PacketNumber) error {
 	s.logger.Infof("Sending PUBLIC_RESET for connection %s, packet number %d", s.destConnID, rejectedPacketNumber)
-	return s.conn.Write(wire.WritePublicReset(s.destConnID, rejectedPacketNumber, 0))
+	return s.writePacket(wire.WritePublicReset(s.destConnID, rejectedPacketNumber, 0))
+}
+
+func (s *session) maxPacketSize() protocol.ByteCount {
+	if s.config.MaxPacketSize != 0 {
+		return s.config.MaxPacketSize
+	}
+	return getMaxPacketSize(s.RemoteAddr())
+}
+
+func (s *session) getPacketNumberLen(pn protocol.PacketNumber) protocol.PacketNumberLen {
+	if s.config.PacketNumberLength != 0 {
+		return protocol.PacketNumberLen(s.config.PacketNumberLength)
+	}
+	return s.sentPacketHandler.GetPacketNumberLen(pn)
+}
+
+func (s *session) writePacket(data []byte) error {
+	if s.config.PacketSink != nil {
+		s.config.PacketSink(DirectionSent, data)
+	}
+	return s.conn.Write(data)
+}
+
+// writePacketTo sends a packet to addr, without changing the current remote address
+func (s *session) writePacketTo(data []byte, addr net.Addr) error {
+	if s.config.PacketSink != nil {
+		s.config.PacketSink(DirectionSent, data)
+	}
+	return s.conn.WriteTo(data, addr)
 }
 
 // scheduleSending signals that we have data for sending
@@ -1208,7 +1712,7 @@ func (s *session) tryQueueingUndecryptablePacket(p *receivedPacket) {
 	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
 		// if this is the first time the undecryptablePackets runs full, start the timer to send a Public Reset
 		if s.receivedTooManyUndecrytablePacketsTime.IsZero() {
-			s.receivedTooManyUndecrytablePacketsTime = time.Now()
+			s.receivedTooManyUndecrytablePacketsTime = s.clock.Now()
 			s.maybeResetTimer()
 		}
 		s.logger.Infof("Dropping undecrytable packet 0x%x (undecryptable packet queue full)", p.header.PacketNumber)
@@ -1241,16 +1745,84 @@ func (s *session) onHasConnectionWindowUpdate() {
 }
 
 func (s *session) onHasStreamData(id protocol.StreamID) {
-	if id != s.version.CryptoStreamID() {
-		s.framer.AddActiveStream(id)
+	if id == s.version.CryptoStreamID() {
+		s.scheduleSending()
+		return
 	}
+	s.framer.AddActiveStream(id)
+	if s.config.WriteCoalescingDelay > 0 {
+		select {
+		case s.coalescingScheduled <- struct{}{}:
+		default:
+		}
+		return
+	}
+	s.scheduleSending()
+}
+
+func (s *session) coalescesWrites() bool {
+	return s.config.WriteCoalescingDelay > 0
+}
+
+// flush sends stream data immediately, even if sending is delayed to coalesce small writes.
+// The returned channel is closed once the run loop finished its current (or next) attempt to send packets,
+// i.e. all stream data that was packed before has been written to the connection.
+func (s *session) flush() <-chan struct{} {
+	c := make(chan struct{})
+	s.flushMutex.Lock()
+	if s.runLoopDone {
+		close(c)
+	} else {
+		s.flushWaiters = append(s.flushWaiters, c)
+	}
+	s.flushMutex.Unlock()
 	s.scheduleSending()
+	return c
+}
+
+// notifyFlushWaiters is called by the run loop after sending packets, and with done set when it exits
+func (s *session) notifyFlushWaiters(done bool) {
+	s.flushMutex.Lock()
+	waiters := s.flushWaiters
+	s.flushWaiters = nil
+	if done {
+		s.runLoopDone = true
+	}
+	s.flushMutex.Unlock()
+	for _, c := range waiters {
+		close(c)
+	}
 }
 
 func (s *session) onStreamCompleted(id protocol.StreamID) {
+	if s.config.OnStreamClosed != nil {
+		s.openStreamsMutex.Lock()
+		_, ok := s.openStreams[id]
+		delete(s.openStreams, id)
+		s.openStreamsMutex.Unlock()
+		if ok {
+			s.config.OnStreamClosed(id, s.streamCloseError(id))
+		}
+	}
 	if err := s.streamsMap.DeleteStream(id); err != nil {
 		s.closeLocal(err)
 	}
+	s.framer.RemoveStream(id)
+}
+
+// streamCloseError returns the error that a completed stream was closed with
+func (s *session) streamCloseError(id protocol.StreamID) error {
+	if str, err := s.streamsMap.GetOrOpenSendStream(id); err == nil && str != nil {
+		return str.closeError()
+	}
+	if str, err := s.streamsMap.GetOrOpenReceiveStream(id); err == nil && str != nil {
+		return str.closeError()
+	}
+	return nil
+}
+
+func (s *session) SetStreamPriority(id protocol.StreamID, priority uint8) {
+	s.framer.SetStreamPriority(id, priority)
 }
 
 func (s *session) LocalAddr() net.Addr {
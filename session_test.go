@@ -5,7 +5,9 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"runtime/pprof"
 	"strings"
@@ -28,9 +30,10 @@ import (
 )
 
 type mockConnection struct {
-	remoteAddr net.Addr
-	localAddr  net.Addr
-	written    chan []byte
+	remoteAddr    net.Addr
+	localAddr     net.Addr
+	written       chan []byte
+	writtenToAddr net.Addr // the address of the last WriteTo call
 }
 
 func newMockConnection() *mockConnection {
@@ -50,6 +53,10 @@ func (m *mockConnection) Write(p []byte) error {
 	}
 	return nil
 }
+func (m *mockConnection) WriteTo(p []byte, addr net.Addr) error {
+	m.writtenToAddr = addr
+	return m.Write(p)
+}
 func (m *mockConnection) Read([]byte) (int, net.Addr, error) { panic("not implemented") }
 
 func (m *mockConnection) SetCurrentRemoteAddr(addr net.Addr) {
@@ -85,6 +92,34 @@ func (m *mockCryptoSetup) SetDiversificationNonce(divNonce []byte) error {
 }
 func (m *mockCryptoSetup) ConnectionState() ConnectionState { panic("not implemented") }
 
+// mockKeyUpdatingCryptoSetup is a crypto setup that supports key updates, like the TLS crypto setup
+type mockKeyUpdatingCryptoSetup struct {
+	mockCryptoSetup
+	updateErr  error
+	keyUpdates int
+}
+
+func (m *mockKeyUpdatingCryptoSetup) UpdateKeys() error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	m.keyUpdates++
+	return nil
+}
+
+// nullSealingCryptoSetup is a crypto setup that seals all packets with the null AEAD
+type nullSealingCryptoSetup struct {
+	mockCryptoSetup
+	sealer handshake.Sealer
+}
+
+func (m *nullSealingCryptoSetup) GetSealer() (protocol.EncryptionLevel, handshake.Sealer) {
+	return protocol.EncryptionForwardSecure, m.sealer
+}
+func (m *nullSealingCryptoSetup) GetSealerForCryptoStream() (protocol.EncryptionLevel, handshake.Sealer) {
+	return protocol.EncryptionUnencrypted, m.sealer
+}
+
 func areSessionsRunning() bool {
 	var b bytes.Buffer
 	pprof.Lookup("goroutine").WriteTo(&b, 1)
@@ -113,7 +148,7 @@ var _ = Describe("Session", func() {
 			_ net.Addr,
 			_ protocol.VersionNumber,
 			_ []byte,
-			_ *handshake.ServerConfig,
+			_ []*handshake.ServerConfig,
 			_ *handshake.TransportParameters,
 			_ []protocol.VersionNumber,
 			_ func(net.Addr, *Cookie) bool,
@@ -139,9 +174,10 @@ var _ = Describe("Session", func() {
 			protocol.Version39,
 			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
 			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
-			scfg,
+			[]*handshake.ServerConfig{scfg},
 			nil,
 			populateServerConfig(&Config{}),
+			utils.DefaultClock{},
 			utils.DefaultLogger,
 		)
 		Expect(err).NotTo(HaveOccurred())
@@ -157,6 +193,28 @@ var _ = Describe("Session", func() {
 		Eventually(areSessionsRunning).Should(BeFalse())
 	})
 
+	// useMockClock replaces the session by a session using a mock clock
+	useMockClock := func() *mockClock {
+		clock := newMockClock()
+		pSess, err := newSession(
+			mconn,
+			sessionRunner,
+			protocol.Version39,
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			[]*handshake.ServerConfig{scfg},
+			nil,
+			populateServerConfig(&Config{}),
+			clock,
+			utils.DefaultLogger,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		sess = pSess.(*session)
+		sess.streamsMap = streamManager
+		sess.packer = packer
+		return clock
+	}
+
 	Context("source address validation", func() {
 		var (
 			cookieVerify    func(net.Addr, *Cookie) bool
@@ -172,7 +230,7 @@ var _ = Describe("Session", func() {
 				_ net.Addr,
 				_ protocol.VersionNumber,
 				_ []byte,
-				_ *handshake.ServerConfig,
+				_ []*handshake.ServerConfig,
 				_ *handshake.TransportParameters,
 				_ []protocol.VersionNumber,
 				cookieFunc func(net.Addr, *Cookie) bool,
@@ -196,9 +254,10 @@ var _ = Describe("Session", func() {
 				protocol.Version39,
 				protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
 				protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
-				scfg,
+				[]*handshake.ServerConfig{scfg},
 				nil,
 				conf,
+				utils.DefaultClock{},
 				utils.DefaultLogger,
 			)
 			Expect(err).NotTo(HaveOccurred())
@@ -230,6 +289,7 @@ var _ = Describe("Session", func() {
 					Data:     []byte{0xde, 0xca, 0xfb, 0xad},
 				}
 				str := NewMockReceiveStreamI(mockCtrl)
+				str.EXPECT().setEncryptionLevel(protocol.EncryptionForwardSecure)
 				str.EXPECT().handleStreamFrame(f)
 				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(5)).Return(str, nil)
 				err := sess.handleStreamFrame(f, protocol.EncryptionForwardSecure)
@@ -243,12 +303,25 @@ var _ = Describe("Session", func() {
 					Data:     []byte{0xde, 0xca, 0xfb, 0xad},
 				}
 				str := NewMockReceiveStreamI(mockCtrl)
+				str.EXPECT().setEncryptionLevel(protocol.EncryptionForwardSecure)
 				str.EXPECT().handleStreamFrame(f).Return(testErr)
 				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(5)).Return(str, nil)
 				err := sess.handleStreamFrame(f, protocol.EncryptionForwardSecure)
 				Expect(err).To(MatchError(testErr))
 			})
 
+			It("tells the stream about the encryption level of 0-RTT data", func() {
+				f := &wire.StreamFrame{
+					StreamID: 5,
+					Data:     []byte("foobar"),
+				}
+				str := NewMockReceiveStreamI(mockCtrl)
+				str.EXPECT().setEncryptionLevel(protocol.EncryptionSecure)
+				str.EXPECT().handleStreamFrame(f)
+				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(5)).Return(str, nil)
+				Expect(sess.handleStreamFrame(f, protocol.EncryptionSecure)).To(Succeed())
+			})
+
 			It("ignores STREAM frames for closed streams", func() {
 				streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(5)).Return(nil, nil) // for closed streams, the streamManager returns nil
 				err := sess.handleStreamFrame(&wire.StreamFrame{
@@ -317,6 +390,41 @@ var _ = Describe("Session", func() {
 				err := sess.handleAckFrame(ack, protocol.EncryptionUnencrypted)
 				Expect(err).ToNot(HaveOccurred())
 			})
+
+			It("updates the RTT estimates", func() {
+				Expect(sess.RTTStats()).To(BeZero())
+				now := time.Now()
+				for i := 1; i <= 50; i++ {
+					rtt := 100 * time.Millisecond
+					if i == 1 {
+						rtt = 300 * time.Millisecond
+					}
+					pn := protocol.PacketNumber(i)
+					sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
+						PacketNumber:    pn,
+						Frames:          []wire.Frame{&wire.PingFrame{}},
+						Length:          100,
+						EncryptionLevel: protocol.EncryptionForwardSecure,
+						SendTime:        now,
+					})
+					now = now.Add(rtt)
+					sess.lastRcvdPacketNumber = pn
+					sess.lastRcvdPacketTime = now
+					ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: pn, Largest: pn}}}
+					Expect(sess.handleAckFrame(ack, protocol.EncryptionForwardSecure)).To(Succeed())
+					if i == 1 {
+						Expect(sess.RTTStats()).To(Equal(RTTStats{
+							SmoothedRTT:   300 * time.Millisecond,
+							LatestRTT:     300 * time.Millisecond,
+							MeanDeviation: 150 * time.Millisecond,
+						}))
+					}
+				}
+				stats := sess.RTTStats()
+				Expect(stats.LatestRTT).To(Equal(100 * time.Millisecond))
+				Expect(stats.SmoothedRTT).To(BeNumerically("~", 100*time.Millisecond, time.Millisecond))
+				Expect(stats.MeanDeviation).To(BeNumerically("<", 5*time.Millisecond))
+			})
 		})
 
 		Context("handling RST_STREAM frames", func() {
@@ -402,6 +510,21 @@ var _ = Describe("Session", func() {
 				sess.handleMaxDataFrame(&wire.MaxDataFrame{ByteOffset: offset})
 			})
 
+			It("calls the callback for connection-level window updates", func() {
+				var called protocol.ByteCount
+				sess.config.OnConnectionWindowUpdate = func(offset ByteCount) { called = offset }
+				// a gQUIC WINDOW_UPDATE frame for stream 0 updates the connection-level window
+				b := &bytes.Buffer{}
+				err := (&wire.MaxDataFrame{ByteOffset: 0x1337}).Write(b, versionGQUICFrames)
+				Expect(err).ToNot(HaveOccurred())
+				frame, err := wire.ParseNextFrame(bytes.NewReader(b.Bytes()), nil, versionGQUICFrames)
+				Expect(err).ToNot(HaveOccurred())
+				connFC.EXPECT().UpdateSendWindow(protocol.ByteCount(0x1337))
+				err = sess.handleFrames([]wire.Frame{frame}, protocol.EncryptionForwardSecure)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(called).To(Equal(protocol.ByteCount(0x1337)))
+			})
+
 			It("ignores MAX_STREAM_DATA frames for a closed stream", func() {
 				streamManager.EXPECT().GetOrOpenSendStream(protocol.StreamID(10)).Return(nil, nil)
 				err := sess.handleFrames([]wire.Frame{&wire.MaxStreamDataFrame{
@@ -465,9 +588,9 @@ var _ = Describe("Session", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("rejects PATH_RESPONSE frames", func() {
+		It("ignores unexpected PATH_RESPONSE frames", func() {
 			err := sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}}, protocol.EncryptionUnspecified)
-			Expect(err).To(MatchError("unexpected PATH_RESPONSE frame"))
+			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("handles PATH_CHALLENGE frames", func() {
@@ -493,9 +616,12 @@ var _ = Describe("Session", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("errors on GOAWAY frames", func() {
-			err := sess.handleFrames([]wire.Frame{&wire.GoawayFrame{}}, protocol.EncryptionUnspecified)
-			Expect(err).To(MatchError("unimplemented: handling GOAWAY frames"))
+		It("handles GOAWAY frames", func() {
+			Expect(sess.PeerGoneAway()).To(BeFalse())
+			streamManager.EXPECT().CloseStreamsAbove(protocol.StreamID(7), errPeerGoneAway)
+			err := sess.handleFrames([]wire.Frame{&wire.GoawayFrame{LastGoodStream: 7}}, protocol.EncryptionUnspecified)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sess.PeerGoneAway()).To(BeTrue())
 		})
 
 		It("handles STOP_WAITING frames", func() {
@@ -516,6 +642,38 @@ var _ = Describe("Session", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Eventually(sess.Context().Done()).Should(BeClosed())
 		})
+
+		It("stores the reason of the peer's CONNECTION_CLOSE", func() {
+			code, reason := sess.CloseReason()
+			Expect(code).To(BeZero())
+			Expect(reason).To(BeEmpty())
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			err := sess.handleFrames([]wire.Frame{&wire.ConnectionCloseFrame{ErrorCode: qerr.ProofInvalid, ReasonPhrase: "foobar"}}, protocol.EncryptionUnspecified)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess.Context().Done()).Should(BeClosed())
+			code, reason = sess.CloseReason()
+			Expect(code).To(Equal(qerr.ProofInvalid))
+			Expect(reason).To(Equal("foobar"))
+		})
+
+		It("doesn't report a close reason if the session was closed locally", func() {
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			Expect(sess.Close()).To(Succeed())
+			code, reason := sess.CloseReason()
+			Expect(code).To(BeZero())
+			Expect(reason).To(BeEmpty())
+		})
 	})
 
 	It("tells its versions", func() {
@@ -523,6 +681,50 @@ var _ = Describe("Session", func() {
 		Expect(sess.GetVersion()).To(Equal(protocol.VersionNumber(4242)))
 	})
 
+	It("uses the congestion controller from the config", func() {
+		var called bool
+		config := populateServerConfig(&Config{
+			CongestionControl: func() CongestionControl {
+				called = true
+				return NewUnlimitedCongestionControl()
+			},
+		})
+		s, err := newSession(
+			mconn,
+			sessionRunner,
+			protocol.Version39,
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			[]*handshake.ServerConfig{scfg},
+			nil,
+			config,
+			utils.DefaultClock{},
+			utils.DefaultLogger,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).ToNot(BeNil())
+		Expect(called).To(BeTrue())
+	})
+
+	Context("updating keys", func() {
+		It("doesn't support key updates for gQUIC", func() {
+			Expect(sess.UpdateKeys()).To(MatchError("key updates are only supported for IETF QUIC"))
+		})
+
+		It("updates the keys", func() {
+			cs := &mockKeyUpdatingCryptoSetup{}
+			sess.cryptoStreamHandler = cs
+			Expect(sess.UpdateKeys()).To(Succeed())
+			Expect(cs.keyUpdates).To(Equal(1))
+		})
+
+		It("returns errors that occur when updating the keys", func() {
+			testErr := errors.New("test error")
+			sess.cryptoStreamHandler = &mockKeyUpdatingCryptoSetup{updateErr: testErr}
+			Expect(sess.UpdateKeys()).To(MatchError(testErr))
+		})
+	})
+
 	It("accepts new streams", func() {
 		mstr := NewMockStreamI(mockCtrl)
 		streamManager.EXPECT().AcceptStream().Return(mstr, nil)
@@ -531,6 +733,101 @@ var _ = Describe("Session", func() {
 		Expect(str).To(Equal(mstr))
 	})
 
+	It("accepts streams opened by a STREAM frame", func() {
+		sess.streamsMap = newStreamsMapLegacy(sess.newStream, protocol.DefaultMaxIncomingStreams, protocol.PerspectiveServer)
+		accepted := make(chan Stream)
+		go func() {
+			defer GinkgoRecover()
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			accepted <- str
+		}()
+		Consistently(accepted).ShouldNot(Receive())
+		Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 3, Data: []byte("foobar")}, protocol.EncryptionForwardSecure)).To(Succeed())
+		var str Stream
+		Eventually(accepted).Should(Receive(&str))
+		Expect(str.StreamID()).To(Equal(protocol.StreamID(3)))
+		b := make([]byte, 6)
+		_, err := io.ReadFull(str, b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b).To(Equal([]byte("foobar")))
+	})
+
+	Context("reporting closed streams", func() {
+		var closedStreams map[protocol.StreamID]error
+
+		BeforeEach(func() {
+			closedStreams = make(map[protocol.StreamID]error)
+			sess.config.OnStreamClosed = func(id protocol.StreamID, err error) {
+				Expect(closedStreams).ToNot(HaveKey(id))
+				closedStreams[id] = err
+			}
+			sess.streamsMap = newStreamsMapLegacy(sess.newStream, protocol.DefaultMaxIncomingStreams, protocol.PerspectiveServer)
+		})
+
+		It("reports a stream that was closed cleanly", func() {
+			Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 3, Data: []byte("foobar"), FinBit: true}, protocol.EncryptionForwardSecure)).To(Succeed())
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(str.Close()).To(Succeed())
+			Expect(closedStreams).To(BeEmpty())
+			f, _ := str.(sendStreamI).popStreamFrame(protocol.MaxPacketSizeIPv4)
+			Expect(f.FinBit).To(BeTrue())
+			Expect(closedStreams).To(HaveKeyWithValue(protocol.StreamID(3), BeNil()))
+		})
+
+		It("reports a stream that was reset", func() {
+			Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 3, Data: []byte("foo")}, protocol.EncryptionForwardSecure)).To(Succeed())
+			Expect(sess.handleRstStreamFrame(&wire.RstStreamFrame{StreamID: 3, ByteOffset: 3, ErrorCode: 42})).To(Succeed())
+			Expect(closedStreams).To(HaveKey(protocol.StreamID(3)))
+			err := closedStreams[3]
+			Expect(err).To(BeAssignableToTypeOf(streamCanceledError{}))
+			Expect(err.(StreamError).ErrorCode()).To(Equal(protocol.ApplicationErrorCode(42)))
+		})
+
+		It("reports streams that are still open when the session is closed", func() {
+			Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 3, Data: []byte("foo")}, protocol.EncryptionForwardSecure)).To(Succeed())
+			Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 5, Data: []byte("bar")}, protocol.EncryptionForwardSecure)).To(Succeed())
+			testErr := qerr.Error(qerr.ProofInvalid, "foobar")
+			Expect(sess.handleCloseError(closeError{err: testErr, remote: true})).To(Succeed())
+			Expect(closedStreams).To(Equal(map[protocol.StreamID]error{3: testErr, 5: testErr}))
+		})
+
+		It("reports streams that the peer won't process after a GOAWAY", func() {
+			sess.streamsMap.UpdateLimits(&handshake.TransportParameters{MaxStreams: 10000})
+			_, err := sess.OpenStream() // stream 2, the peer will still process it
+			Expect(err).ToNot(HaveOccurred())
+			str4, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str4.StreamID()).To(Equal(protocol.StreamID(4)))
+			sess.handleGoawayFrame(&wire.GoawayFrame{LastGoodStream: 2})
+			Expect(closedStreams).To(Equal(map[protocol.StreamID]error{4: errPeerGoneAway}))
+			_, err = str4.Write([]byte("foobar"))
+			Expect(err).To(MatchError(errPeerGoneAway))
+		})
+
+		It("doesn't hold the lock when reporting streams that are closed with the session", func() {
+			Expect(sess.handleStreamFrame(&wire.StreamFrame{StreamID: 3, Data: []byte("foo")}, protocol.EncryptionForwardSecure)).To(Succeed())
+			sess.config.OnStreamClosed = func(id protocol.StreamID, err error) {
+				// opening a new stream from the callback needs the lock
+				sess.newFlowController(7)
+				closedStreams[id] = err
+			}
+			testErr := qerr.Error(qerr.ProofInvalid, "foobar")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(sess.handleCloseError(closeError{err: testErr, remote: true})).To(Succeed())
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed())
+			Expect(closedStreams).To(Equal(map[protocol.StreamID]error{3: testErr}))
+		})
+	})
+
 	Context("closing", func() {
 		BeforeEach(func() {
 			Eventually(areSessionsRunning).Should(BeFalse())
@@ -573,6 +870,18 @@ var _ = Describe("Session", func() {
 			Expect(sess.Context().Done()).To(BeClosed())
 		})
 
+		It("sends a CONNECTION_CLOSE with the application error code and reason", func() {
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(&wire.ConnectionCloseFrame{
+				ErrorCode:    0x42,
+				ReasonPhrase: "request canceled",
+			}).Return(&packedPacket{raw: []byte("connection close")}, nil)
+			Expect(sess.CloseWithError(0x42, errors.New("request canceled"))).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(mconn.written).To(Receive(ContainSubstring("connection close")))
+		})
+
 		It("closes the session in order to replace it with another QUIC version", func() {
 			streamManager.EXPECT().CloseWithError(gomock.Any())
 			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
@@ -608,6 +917,45 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("running with a context", func() {
+		It("closes the session when the context is canceled", func() {
+			Eventually(areSessionsRunning).Should(BeFalse())
+			streamManager.EXPECT().CloseWithError(qerr.Error(qerr.PeerGoingAway, context.Canceled.Error()))
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(&wire.ConnectionCloseFrame{
+				ErrorCode:    qerr.PeerGoingAway,
+				ReasonPhrase: context.Canceled.Error(),
+			}).Return(&packedPacket{raw: []byte("connection close")}, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := sess.runContext(ctx)
+				Expect(err).To(MatchError(qerr.Error(qerr.PeerGoingAway, context.Canceled.Error())))
+				close(done)
+			}()
+			Eventually(areSessionsRunning).Should(BeTrue())
+			Consistently(done).ShouldNot(BeClosed())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(mconn.written).To(Receive(ContainSubstring("connection close")))
+			Expect(sess.Context().Done()).To(BeClosed())
+		})
+
+		It("closes the session when the context deadline is exceeded", func() {
+			Eventually(areSessionsRunning).Should(BeFalse())
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(20*time.Millisecond))
+			defer cancel()
+			err := sess.runContext(ctx)
+			Expect(err).To(MatchError(qerr.Error(qerr.PeerGoingAway, context.DeadlineExceeded.Error())))
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+	})
+
 	Context("receiving packets", func() {
 		var hdr *wire.Header
 		var unpacker *MockUnpacker
@@ -628,6 +976,55 @@ var _ = Describe("Session", func() {
 			Expect(sess.largestRcvdPacketNumber).To(Equal(protocol.PacketNumber(5)))
 		})
 
+		It("resets the idle timeout when receiving a packet", func() {
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionForwardSecure,
+				frames:          []wire.Frame{&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}},
+			}, nil)
+			sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
+				PacketNumber:    1,
+				Frames:          []wire.Frame{&wire.PingFrame{}},
+				Length:          100,
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+				SendTime:        time.Now(),
+			})
+			sess.lastNetworkActivityTime = time.Now().Add(-time.Hour)
+			now := time.Now()
+			hdr.PacketNumber = 5
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, rcvTime: now})).To(Succeed())
+			Expect(sess.lastNetworkActivityTime).To(Equal(now))
+		})
+
+		It("doesn't reset the idle timeout for ACK-only packets, if configured", func() {
+			sess.config.IdleTimeoutRetransmittableOnly = true
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionForwardSecure,
+				frames:          []wire.Frame{&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}},
+			}, nil)
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionForwardSecure,
+				frames:          []wire.Frame{&wire.PingFrame{}},
+			}, nil)
+			sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
+				PacketNumber:    1,
+				Frames:          []wire.Frame{&wire.PingFrame{}},
+				Length:          100,
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+				SendTime:        time.Now(),
+			})
+			lastActivity := time.Now().Add(-time.Hour)
+			sess.lastNetworkActivityTime = lastActivity
+			hdr.PacketNumber = 5
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, rcvTime: time.Now()})).To(Succeed())
+			Expect(sess.lastNetworkActivityTime).To(Equal(lastActivity))
+			// the ACK is still used for the RTT measurement
+			Expect(sess.RTTStats().LatestRTT).ToNot(BeZero())
+			now := time.Now()
+			hdr.PacketNumber = 6
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, rcvTime: now})).To(Succeed())
+			Expect(sess.lastNetworkActivityTime).To(Equal(now))
+		})
+
 		It("informs the ReceivedPacketHandler", func() {
 			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{}, nil)
 			now := time.Now().Add(time.Hour)
@@ -639,6 +1036,50 @@ var _ = Describe("Session", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("neither handles nor acknowledges packets containing unknown frame types", func() {
+			aead := NewMockGQUICAEAD(mockCtrl)
+			sess.unpacker = newPacketUnpackerGQUIC(aead, sess.version)
+			buf := &bytes.Buffer{}
+			buf.Write([]byte{0x1f, 0x13, 0x37}) // unknown frame type
+			Expect((&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}).Write(buf, sess.version)).To(Succeed())
+			aead.EXPECT().Open(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(buf.Bytes(), protocol.EncryptionForwardSecure, nil)
+			// the ReceivedPacketHandler and the streams map are mocks without expectations
+			sess.receivedPacketHandler = mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
+			hdr.PacketNumber = 5
+			err := sess.handlePacketImpl(&receivedPacket{header: hdr})
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidFrameData))
+		})
+
+		It("acknowledges retransmittable handshake packets right away", func() {
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionSecure,
+				frames:          []wire.Frame{&wire.PingFrame{}},
+			}, nil).Times(2)
+			hdr.PacketNumber = 1
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr})).To(Succeed())
+			Expect(sess.receivedPacketHandler.GetAckFrame()).ToNot(BeNil())
+			hdr.PacketNumber = 2
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr})).To(Succeed())
+			Expect(sess.receivedPacketHandler.GetAlarmTimeout()).To(BeZero())
+			ack := sess.receivedPacketHandler.GetAckFrame()
+			Expect(ack).ToNot(BeNil())
+			Expect(ack.LargestAcked()).To(Equal(protocol.PacketNumber(2)))
+		})
+
+		It("doesn't acknowledge forward-secure packets right away", func() {
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionForwardSecure,
+				frames:          []wire.Frame{&wire.PingFrame{}},
+			}, nil)
+			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
+			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(5), gomock.Any(), true)
+			// don't EXPECT any call to QueueAck
+			sess.receivedPacketHandler = rph
+			hdr.PacketNumber = 5
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr})).To(Succeed())
+		})
+
 		It("doesn't inform the ReceivedPacketHandler about Retry packets", func() {
 			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{}, nil)
 			now := time.Now().Add(time.Hour)
@@ -669,6 +1110,128 @@ var _ = Describe("Session", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		Context("validating new paths", func() {
+			newAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 13, 37), Port: 4242}
+
+			BeforeEach(func() {
+				sess.version = versionIETFFrames
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().SentPacket(gomock.Any()).AnyTimes()
+				sess.sentPacketHandler = sph
+				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{}, nil).AnyTimes()
+			})
+
+			expectPathChallenge := func() *wire.PathChallengeFrame {
+				frame := &wire.PathChallengeFrame{}
+				packer.EXPECT().PackPathChallenge(gomock.Any()).DoAndReturn(func(f *wire.PathChallengeFrame) (*packedPacket, error) {
+					*frame = *f
+					raw := append((*getPacketBuffer())[:0], []byte("challenge")...)
+					return &packedPacket{header: &wire.Header{}, raw: raw, frames: []wire.Frame{f}}, nil
+				})
+				return frame
+			}
+
+			It("doesn't validate the current path", func() {
+				hdr.PacketNumber = 5
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: mconn.RemoteAddr()})).To(Succeed())
+				Expect(mconn.written).To(BeEmpty())
+			})
+
+			It("sends a PATH_CHALLENGE to the new address before migrating", func() {
+				oldAddr := mconn.RemoteAddr()
+				challenge := expectPathChallenge()
+				hdr.PacketNumber = 5
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(Receive(Equal([]byte("challenge"))))
+				Expect(mconn.writtenToAddr).To(Equal(newAddr))
+				Expect(mconn.RemoteAddr()).To(Equal(oldAddr))
+				// a second packet from the same address doesn't trigger another PATH_CHALLENGE
+				hdr.PacketNumber = 6
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(BeEmpty())
+				// a PATH_RESPONSE with the wrong data doesn't validate the path
+				Expect(sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}}, protocol.EncryptionForwardSecure)).To(Succeed())
+				Expect(mconn.RemoteAddr()).To(Equal(oldAddr))
+				Expect(sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: challenge.Data}}, protocol.EncryptionForwardSecure)).To(Succeed())
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+				// a duplicate PATH_RESPONSE is ignored
+				Expect(sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: challenge.Data}}, protocol.EncryptionForwardSecure)).To(Succeed())
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+			})
+
+			It("sends a new PATH_CHALLENGE if the first one was lost", func() {
+				clock := newMockClock()
+				sess.clock = clock
+				sph := ackhandler.NewSentPacketHandler(sess.rttStats, nil, nil, clock, utils.DefaultLogger, sess.version)
+				sess.sentPacketHandler = sph
+				oldAddr := mconn.RemoteAddr()
+				first := expectPathChallenge()
+				hdr.PacketNumber = 5
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(Receive(Equal([]byte("challenge"))))
+				// Packet 2 to 5 are acknowledged, so packet 1, which contained the PATH_CHALLENGE, is declared lost.
+				for pn := protocol.PacketNumber(2); pn <= 5; pn++ {
+					sph.SentPacket(&ackhandler.Packet{PacketNumber: pn, Frames: []wire.Frame{&wire.PingFrame{}}, Length: 1, SendTime: clock.Now()})
+				}
+				clock.Advance(time.Millisecond)
+				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 5}}}
+				Expect(sph.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, clock.Now())).To(Succeed())
+				// the PATH_CHALLENGE is not retransmitted to the old address
+				Expect(sph.DequeuePacketForRetransmission()).To(BeNil())
+				// before the PATH_CHALLENGE times out, no new PATH_CHALLENGE is sent
+				hdr.PacketNumber = 6
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(BeEmpty())
+				clock.Advance(3 * sess.rttStats.SmoothedOrInitialRTT())
+				second := expectPathChallenge()
+				hdr.PacketNumber = 7
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(Receive(Equal([]byte("challenge"))))
+				Expect(mconn.writtenToAddr).To(Equal(newAddr))
+				Expect(second.Data).ToNot(Equal(first.Data))
+				// a response to the first PATH_CHALLENGE doesn't validate the path
+				Expect(sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: first.Data}}, protocol.EncryptionForwardSecure)).To(Succeed())
+				Expect(mconn.RemoteAddr()).To(Equal(oldAddr))
+				Expect(sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: second.Data}}, protocol.EncryptionForwardSecure)).To(Succeed())
+				Expect(mconn.RemoteAddr()).To(Equal(newAddr))
+			})
+
+			It("doesn't validate paths in gQUIC", func() {
+				sess.version = versionGQUICFrames
+				hdr.PacketNumber = 5
+				Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, remoteAddr: newAddr})).To(Succeed())
+				Expect(mconn.written).To(BeEmpty())
+				Expect(mconn.RemoteAddr()).ToNot(Equal(newAddr))
+			})
+		})
+
+		It("passes received packets to the packet sink", func() {
+			testErr := errors.New("unpack error")
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, testErr)
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{raw: []byte("close")}, nil)
+			type sinkedPacket struct {
+				direction Direction
+				data      []byte
+			}
+			sinked := make(chan sinkedPacket, 2)
+			sess.config.PacketSink = func(dir Direction, data []byte) { sinked <- sinkedPacket{dir, data} }
+			hdr.PacketNumber = 5
+			hdr.Raw = []byte("header")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := sess.run()
+				Expect(err).To(MatchError(testErr))
+				close(done)
+			}()
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			sess.handlePacket(&receivedPacket{header: hdr, data: []byte("payload")})
+			Eventually(done).Should(BeClosed())
+			Expect(sinked).To(Receive(Equal(sinkedPacket{DirectionReceived, []byte("headerpayload")})))
+			Expect(sinked).To(Receive(Equal(sinkedPacket{DirectionSent, []byte("close")})))
+		})
+
 		It("sets the {last,largest}RcvdPacketNumber, for an out-of-order packet", func() {
 			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{}, nil).Times(2)
 			hdr.PacketNumber = 5
@@ -732,9 +1295,83 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("tracing", func() {
+		var tracer *recordingTracer
+
+		BeforeEach(func() {
+			tracer = &recordingTracer{}
+			sess.tracer = tracer
+		})
+
+		It("creates a tracer when setting up the session", func() {
+			sess.config.Tracer = func() Tracer { return tracer }
+			sess.tracer = nil
+			sess.preSetup()
+			Expect(sess.tracer).To(Equal(tracer))
+		})
+
+		It("traces lost packets", func() {
+			sess.config.Tracer = func() Tracer { return tracer }
+			sess.preSetup()
+			sess.sentPacketHandler.SetHandshakeComplete()
+			now := time.Now()
+			for i, sendTime := range []time.Time{now.Add(-time.Hour), now} {
+				sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
+					PacketNumber:    protocol.PacketNumber(i + 1),
+					Frames:          []wire.Frame{&wire.PingFrame{}},
+					Length:          1000,
+					EncryptionLevel: protocol.EncryptionForwardSecure,
+					SendTime:        sendTime,
+				})
+			}
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(sess.sentPacketHandler.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, now)).To(Succeed())
+			Expect(tracer.Events()).To(Equal([]string{"lost 1 (1000 bytes)"}))
+		})
+
+		It("traces sent and received packets, opened streams, the handshake and closing", func() {
+			unpacker := NewMockUnpacker(mockCtrl)
+			sess.unpacker = unpacker
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{encryptionLevel: protocol.EncryptionForwardSecure}, nil)
+			hdr := &wire.Header{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6, Raw: []byte("raw header")}
+			Expect(sess.handlePacketImpl(&receivedPacket{header: hdr, data: []byte("foobar")})).To(Succeed())
+
+			packet := &packedPacket{
+				raw:             append((*getPacketBuffer())[:0], []byte("foobar")...),
+				header:          &wire.Header{PacketNumber: 1},
+				encryptionLevel: protocol.EncryptionSecure,
+			}
+			packer.EXPECT().PackPacket().Return(packet, nil)
+			Expect(sess.sendPacket()).To(BeTrue())
+
+			sess.newFlowController(7)
+
+			sessionRunner.EXPECT().onHandshakeComplete(sess)
+			sess.handleHandshakeEvent(true)
+
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{header: &wire.Header{PacketNumber: 2}, raw: []byte("close")}, nil)
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(tracer.Events()).To(Equal([]string{
+				"received 5 (16 bytes, forward-secure)",
+				"sent 1 (6 bytes, encrypted (not forward-secure))",
+				"opened stream 7",
+				"completed handshake",
+				"sent 2 (5 bytes, unknown)",
+				"closed: <nil>",
+			}))
+		})
+	})
+
 	Context("sending packets", func() {
 		getPacket := func(pn protocol.PacketNumber) *packedPacket {
-			data := *getPacketBuffer()
+			data := (*getPacketBuffer())[:0]
 			data = append(data, []byte("foobar")...)
 			return &packedPacket{
 				raw:    data,
@@ -742,6 +1379,18 @@ var _ = Describe("Session", func() {
 			}
 		}
 
+		It("sends packets in bursts when using a congestion controller without pacing", func() {
+			sess.sentPacketHandler = ackhandler.NewSentPacketHandler(sess.rttStats, NewUnlimitedCongestionControl(), nil, sess.clock, utils.DefaultLogger, sess.version)
+			var pn protocol.PacketNumber
+			packer.EXPECT().PackPacket().DoAndReturn(func() (*packedPacket, error) {
+				pn++
+				return getPacket(pn), nil
+			}).Times(10)
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(10))
+			Expect(sess.pacingDeadline).ToNot(BeZero())
+		})
+
 		It("sends packets", func() {
 			packer.EXPECT().PackPacket().Return(getPacket(1), nil)
 			err := sess.receivedPacketHandler.ReceivedPacket(0x035e, time.Now(), true)
@@ -751,6 +1400,20 @@ var _ = Describe("Session", func() {
 			Expect(sent).To(BeTrue())
 		})
 
+		It("passes sent packets to the packet sink", func() {
+			var sinked []byte
+			sess.config.PacketSink = func(dir Direction, data []byte) {
+				Expect(dir).To(Equal(DirectionSent))
+				sinked = append([]byte{}, data...)
+			}
+			packer.EXPECT().PackPacket().Return(getPacket(1), nil)
+			sent, err := sess.sendPacket()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			Expect(sinked).To(Equal([]byte("foobar")))
+			Expect(mconn.written).To(Receive(Equal([]byte("foobar"))))
+		})
+
 		It("doesn't send packets if there's nothing to send", func() {
 			packer.EXPECT().PackPacket().Return(getPacket(2), nil)
 			err := sess.receivedPacketHandler.ReceivedPacket(0x035e, time.Now(), true)
@@ -770,6 +1433,37 @@ var _ = Describe("Session", func() {
 			Expect(sess.sendPackets()).To(Succeed())
 		})
 
+		It("stops sending new data when the maximum bytes in flight are reached", func() {
+			sess.config.MaxBytesInFlight = 12
+			getRetransmittablePacket := func(pn protocol.PacketNumber) *packedPacket {
+				p := getPacket(pn)
+				p.frames = []wire.Frame{&wire.PingFrame{}}
+				p.encryptionLevel = protocol.EncryptionForwardSecure
+				return p
+			}
+			packer.EXPECT().PackPacket().Return(getRetransmittablePacket(1), nil)
+			packer.EXPECT().PackPacket().Return(getRetransmittablePacket(2), nil)
+			for i := 0; i < 2; i++ {
+				Expect(sess.sendPackets()).To(Succeed())
+			}
+			Expect(mconn.written).To(HaveLen(2))
+			sess.updateBytesInFlight()
+			Expect(sess.BytesInFlight()).To(Equal(protocol.ByteCount(12)))
+			// only ACKs are sent now
+			packer.EXPECT().MaybePackAckPacket()
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(2))
+			// receive an ACK for the first packet
+			sess.lastRcvdPacketNumber = 1
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}
+			Expect(sess.handleAckFrame(ack, protocol.EncryptionForwardSecure)).To(Succeed())
+			sess.updateBytesInFlight()
+			Expect(sess.BytesInFlight()).To(Equal(protocol.ByteCount(6)))
+			packer.EXPECT().PackPacket().Return(getRetransmittablePacket(3), nil)
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(3))
+		})
+
 		It("adds a BLOCKED frame when it is connection-level flow control blocked", func() {
 			fc := mocks.NewMockConnectionFlowController(mockCtrl)
 			fc.EXPECT().IsNewlyBlocked().Return(true, protocol.ByteCount(1337))
@@ -856,6 +1550,9 @@ var _ = Describe("Session", func() {
 				EncryptionLevel: protocol.EncryptionForwardSecure,
 			}
 			retransmissions := []*packedPacket{getPacket(1337), getPacket(1338)}
+			str := NewMockSendStreamI(mockCtrl)
+			str.EXPECT().writeCanceled()
+			streamManager.EXPECT().GetSendStream(protocol.StreamID(5)).Return(str)
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().DequeuePacketForRetransmission().Return(packet)
 			packer.EXPECT().PackRetransmission(packet).Return(retransmissions, nil)
@@ -871,17 +1568,101 @@ var _ = Describe("Session", func() {
 			Expect(mconn.written).To(HaveLen(2))
 		})
 
-		It("sends a probe packet", func() {
-			packetToRetransmit := &ackhandler.Packet{
-				PacketNumber: 0x42,
-				PacketType:   protocol.PacketTypeHandshake,
+		It("retransmits STREAM frames when an ACK reports a packet as lost", func() {
+			sess.sentPacketHandler.SetHandshakeComplete()
+			frame := &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}
+			for pn := protocol.PacketNumber(1); pn <= 5; pn++ {
+				p := &ackhandler.Packet{
+					PacketNumber:    pn,
+					Frames:          []wire.Frame{&wire.PingFrame{}},
+					Length:          100,
+					EncryptionLevel: protocol.EncryptionForwardSecure,
+					SendTime:        time.Now(),
+				}
+				if pn == 1 {
+					p.Frames = []wire.Frame{frame}
+				}
+				sess.sentPacketHandler.SentPacket(p)
 			}
-			retransmittedPacket := getPacket(123)
-			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
-			sph.EXPECT().TimeUntilSend()
-			sph.EXPECT().GetPacketNumberLen(gomock.Any()).Return(protocol.PacketNumberLen2).AnyTimes()
-			sph.EXPECT().SendMode().Return(ackhandler.SendTLP)
-			sph.EXPECT().ShouldSendNumPackets().Return(1)
+			// packet 1 is missing from the ACK
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 5}}}
+			Expect(sess.sentPacketHandler.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, time.Now())).To(Succeed())
+			str := NewMockSendStreamI(mockCtrl)
+			str.EXPECT().writeCanceled()
+			streamManager.EXPECT().GetSendStream(protocol.StreamID(5)).Return(str)
+			packer.EXPECT().PackRetransmission(gomock.Any()).DoAndReturn(func(p *ackhandler.Packet) ([]*packedPacket, error) {
+				Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(1)))
+				Expect(p.Frames).To(Equal([]wire.Frame{frame}))
+				packet := getPacket(6)
+				packet.frames = p.Frames
+				return []*packedPacket{packet}, nil
+			})
+			sent, err := sess.maybeSendRetransmission()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			Expect(mconn.written).To(HaveLen(1))
+		})
+
+		It("doesn't retransmit STREAM frames for canceled streams", func() {
+			packet := &ackhandler.Packet{
+				PacketNumber: 42,
+				Frames: []wire.Frame{
+					&wire.StreamFrame{StreamID: 5, Data: []byte("foo")},
+					&wire.MaxDataFrame{ByteOffset: 1337},
+					&wire.StreamFrame{StreamID: 7, Data: []byte("bar")},
+				},
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+			}
+			canceled := NewMockSendStreamI(mockCtrl)
+			canceled.EXPECT().writeCanceled().Return(true)
+			str := NewMockSendStreamI(mockCtrl)
+			str.EXPECT().writeCanceled()
+			streamManager.EXPECT().GetSendStream(protocol.StreamID(5)).Return(canceled)
+			streamManager.EXPECT().GetSendStream(protocol.StreamID(7)).Return(str)
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().DequeuePacketForRetransmission().Return(packet)
+			packer.EXPECT().PackRetransmission(gomock.Any()).DoAndReturn(func(p *ackhandler.Packet) ([]*packedPacket, error) {
+				Expect(p.Frames).To(Equal([]wire.Frame{
+					&wire.MaxDataFrame{ByteOffset: 1337},
+					&wire.StreamFrame{StreamID: 7, Data: []byte("bar")},
+				}))
+				return []*packedPacket{getPacket(43)}, nil
+			})
+			sph.EXPECT().SentPacketsAsRetransmission(gomock.Any(), protocol.PacketNumber(42))
+			sess.sentPacketHandler = sph
+			sent, err := sess.maybeSendRetransmission()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeTrue())
+		})
+
+		It("skips packets that only contain STREAM frames for canceled streams", func() {
+			canceled := NewMockSendStreamI(mockCtrl)
+			canceled.EXPECT().writeCanceled().Return(true)
+			streamManager.EXPECT().GetSendStream(protocol.StreamID(5)).Return(canceled)
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().DequeuePacketForRetransmission().Return(&ackhandler.Packet{
+				PacketNumber:    42,
+				Frames:          []wire.Frame{&wire.StreamFrame{StreamID: 5, Data: []byte("foo")}},
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+			})
+			sph.EXPECT().DequeuePacketForRetransmission()
+			sess.sentPacketHandler = sph
+			sent, err := sess.maybeSendRetransmission()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeFalse())
+		})
+
+		It("sends a probe packet", func() {
+			packetToRetransmit := &ackhandler.Packet{
+				PacketNumber: 0x42,
+				PacketType:   protocol.PacketTypeHandshake,
+			}
+			retransmittedPacket := getPacket(123)
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().TimeUntilSend()
+			sph.EXPECT().GetPacketNumberLen(gomock.Any()).Return(protocol.PacketNumberLen2).AnyTimes()
+			sph.EXPECT().SendMode().Return(ackhandler.SendTLP)
+			sph.EXPECT().ShouldSendNumPackets().Return(1)
 			sph.EXPECT().DequeueProbePacket().Return(packetToRetransmit, nil)
 			packer.EXPECT().PackRetransmission(packetToRetransmit).Return([]*packedPacket{retransmittedPacket}, nil)
 			sph.EXPECT().SentPacketsAsRetransmission(gomock.Any(), protocol.PacketNumber(0x42)).Do(func(packets []*ackhandler.Packet, _ protocol.PacketNumber) {
@@ -906,6 +1687,7 @@ var _ = Describe("Session", func() {
 			BeforeEach(func() {
 				sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetBytesInFlight().AnyTimes()
 				sph.EXPECT().GetPacketNumberLen(gomock.Any()).Return(protocol.PacketNumberLen2).AnyTimes()
 				sph.EXPECT().DequeuePacketForRetransmission().AnyTimes()
 				sess.sentPacketHandler = sph
@@ -1037,6 +1819,7 @@ var _ = Describe("Session", func() {
 			It("sends when scheduleSending is called", func() {
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetBytesInFlight().AnyTimes()
 				sph.EXPECT().TimeUntilSend().AnyTimes()
 				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
 				sph.EXPECT().ShouldSendNumPackets().AnyTimes().Return(1)
@@ -1066,6 +1849,7 @@ var _ = Describe("Session", func() {
 				sph.EXPECT().TimeUntilSend().Return(time.Now())
 				sph.EXPECT().TimeUntilSend().Return(time.Now().Add(time.Hour))
 				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetBytesInFlight().AnyTimes()
 				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
 				sph.EXPECT().ShouldSendNumPackets().Return(1)
 				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
@@ -1091,6 +1875,70 @@ var _ = Describe("Session", func() {
 				Eventually(sess.Context().Done()).Should(BeClosed())
 			})
 		})
+
+		Context("coalescing writes", func() {
+			BeforeEach(func() {
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().GetAlarmTimeout().AnyTimes()
+				sph.EXPECT().GetBytesInFlight().AnyTimes()
+				sph.EXPECT().TimeUntilSend().AnyTimes()
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
+				sph.EXPECT().ShouldSendNumPackets().AnyTimes().Return(1)
+				sph.EXPECT().GetPacketNumberLen(gomock.Any()).Return(protocol.PacketNumberLen2).AnyTimes()
+				sph.EXPECT().SentPacket(gomock.Any())
+				sess.sentPacketHandler = sph
+			})
+
+			AfterEach(func() {
+				// make the go routine return
+				sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+				streamManager.EXPECT().CloseWithError(gomock.Any())
+				packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+				sess.Close()
+				Eventually(sess.Context().Done()).Should(BeClosed())
+			})
+
+			It("sends the data of multiple writes in one packet", func() {
+				sess.config.WriteCoalescingDelay = scaleDuration(100 * time.Millisecond)
+				sess.framer = newFramer(sess.cryptoStream, streamManager, sess.version)
+				for _, id := range []protocol.StreamID{3, 5, 7} {
+					str := NewMockSendStreamI(mockCtrl)
+					str.EXPECT().popStreamFrame(gomock.Any()).Return(&wire.StreamFrame{StreamID: id, Data: []byte("foo")}, false)
+					streamManager.EXPECT().GetOrOpenSendStream(id).Return(str, nil)
+				}
+				packer.EXPECT().PackPacket().DoAndReturn(func() (*packedPacket, error) {
+					frames := sess.framer.AppendStreamFrames(nil, protocol.MaxPacketSizeIPv4)
+					Expect(frames).To(HaveLen(3))
+					return getPacket(1), nil
+				})
+
+				go func() {
+					defer GinkgoRecover()
+					sess.run()
+				}()
+				sess.onHasStreamData(3)
+				time.Sleep(scaleDuration(10 * time.Millisecond))
+				sess.onHasStreamData(5)
+				sess.onHasStreamData(7)
+				Consistently(mconn.written, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+				Eventually(mconn.written).Should(Receive())
+				Consistently(mconn.written).ShouldNot(Receive())
+			})
+
+			It("sends immediately when a stream is flushed", func() {
+				sess.config.WriteCoalescingDelay = time.Hour
+				packer.EXPECT().PackPacket().Return(getPacket(1), nil)
+
+				go func() {
+					defer GinkgoRecover()
+					sess.run()
+				}()
+				sess.onHasStreamData(3)
+				Consistently(mconn.written).ShouldNot(Receive())
+				sess.flush()
+				Eventually(mconn.written).Should(Receive())
+			})
+		})
 	})
 
 	It("closes when crypto stream errors", func() {
@@ -1107,6 +1955,22 @@ var _ = Describe("Session", func() {
 		Eventually(sess.Context().Done()).Should(BeClosed())
 	})
 
+	It("sends a PUBLIC_RESET when the crypto stream errors, if configured", func() {
+		sess.config.PublicResetOnCryptoError = true
+		testErr := qerr.Error(qerr.InvalidCryptoMessageType, "CryptoSetup: expected CHLO")
+		streamManager.EXPECT().CloseWithError(testErr)
+		sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+		cryptoSetup.handleErr = testErr
+		go func() {
+			defer GinkgoRecover()
+			err := sess.run()
+			Expect(err).To(MatchError(testErr))
+		}()
+		Eventually(sess.Context().Done()).Should(BeClosed())
+		Expect(mconn.written).To(HaveLen(1))
+		Expect(mconn.written).To(Receive(ContainSubstring("PRST")))
+	})
+
 	Context("sending a PUBLIC_RESET when receiving undecryptable packets during the handshake", func() {
 		// sends protocol.MaxUndecryptablePackets+1 undecrytable packets
 		// this completely fills up the undecryptable packets queue and triggers the public reset timer
@@ -1123,8 +1987,10 @@ var _ = Describe("Session", func() {
 			}
 		}
 
+		var unpacker *MockUnpacker
+
 		BeforeEach(func() {
-			unpacker := NewMockUnpacker(mockCtrl)
+			unpacker = NewMockUnpacker(mockCtrl)
 			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, qerr.Error(qerr.DecryptionFailure, "")).AnyTimes()
 			sess.unpacker = unpacker
 			sess.cryptoStreamHandler = &mockCryptoSetup{}
@@ -1179,17 +2045,19 @@ var _ = Describe("Session", func() {
 
 		It("sends a PUBLIC_RESET after a timeout", func() {
 			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
-			Expect(sess.receivedTooManyUndecrytablePacketsTime).To(BeZero())
+			clock := useMockClock()
+			sess.unpacker = unpacker
+			sess.cryptoStreamHandler = &mockCryptoSetup{}
 			go func() {
 				defer GinkgoRecover()
 				sess.run()
 			}()
 			sendUndecryptablePackets()
-			Eventually(func() time.Time { return sess.receivedTooManyUndecrytablePacketsTime }).Should(BeTemporally("~", time.Now(), time.Second))
-			// speed up this test by manually setting back the time when too many packets were received
-			sess.receivedTooManyUndecrytablePacketsTime = time.Now().Add(-protocol.PublicResetTimeout)
-			time.Sleep(10 * time.Millisecond) // wait for the run loop to spin up
-			sess.scheduleSending()            // wake up the run loop
+			// wait until the run loop set the timer for the PUBLIC_RESET
+			Eventually(clock.nextDeadline).Should(Equal(clock.Now().Add(protocol.PublicResetTimeout)))
+			clock.Advance(protocol.PublicResetTimeout - time.Nanosecond)
+			Consistently(mconn.written).Should(BeEmpty())
+			clock.Advance(2 * time.Nanosecond)
 			Eventually(mconn.written).Should(HaveLen(1))
 			Expect(mconn.written).To(Receive(ContainSubstring("PRST")))
 			Eventually(sess.Context().Done()).Should(BeClosed())
@@ -1318,6 +2186,7 @@ var _ = Describe("Session", func() {
 		}
 		streamManager.EXPECT().UpdateLimits(&params)
 		packer.EXPECT().HandleTransportParameters(&params)
+		packer.EXPECT().MaxDatagramSize()
 		paramsChan <- params
 		Eventually(func() *handshake.TransportParameters { return sess.peerParams }).Should(Equal(&params))
 		// make the go routine return
@@ -1328,6 +2197,55 @@ var _ = Describe("Session", func() {
 		Eventually(sess.Context().Done()).Should(BeClosed())
 	})
 
+	It("returns the connection parameters received from the peer", func() {
+		Expect(sess.ConnectionParameters()).To(BeZero())
+		paramsChan := make(chan handshake.TransportParameters)
+		sess.paramsChan = paramsChan
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+		}()
+		params := handshake.TransportParameters{
+			MaxStreams:                  123,
+			IdleTimeout:                 90 * time.Second,
+			StreamFlowControlWindow:     0x5000,
+			ConnectionFlowControlWindow: 0x6000,
+			OmitConnectionID:            true,
+			MaxPacketSize:               0x42,
+		}
+		streamManager.EXPECT().UpdateLimits(&params)
+		packer.EXPECT().HandleTransportParameters(&params)
+		packer.EXPECT().MaxDatagramSize()
+		paramsChan <- params
+		Eventually(sess.ConnectionParameters).Should(Equal(ConnectionParameters{
+			StreamFlowControlWindow:     0x5000,
+			ConnectionFlowControlWindow: 0x6000,
+			MaxPacketSize:               0x42,
+			MaxStreams:                  123,
+			IdleTimeout:                 90 * time.Second,
+			OmitConnectionID:            true,
+		}))
+		// make the go routine return
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+		sess.Close()
+		Eventually(sess.Context().Done()).Should(BeClosed())
+	})
+
+	It("returns the stream limits for IETF QUIC", func() {
+		sess.version = versionIETFFrames
+		streamManager.EXPECT().UpdateLimits(gomock.Any())
+		packer.EXPECT().HandleTransportParameters(gomock.Any())
+		packer.EXPECT().MaxDatagramSize()
+		sess.processTransportParameters(&handshake.TransportParameters{
+			MaxBidiStreams: 10,
+			MaxUniStreams:  20,
+		})
+		Expect(sess.ConnectionParameters().MaxStreams).To(Equal(10))
+		Expect(sess.ConnectionParameters().MaxUniStreams).To(Equal(20))
+	})
+
 	Context("keep-alives", func() {
 		// should be shorter than the local timeout for these tests
 		// otherwise we'd send a CONNECTION_CLOSE in the tests where we're testing that no PING is sent
@@ -1423,6 +2341,54 @@ var _ = Describe("Session", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("times out when only receiving ACK-only packets, if configured", func() {
+			sess.config.IdleTimeoutRetransmittableOnly = true
+			sess.config.IdleTimeout = scaleDuration(100 * time.Millisecond)
+			sess.handshakeComplete = true
+			sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
+				PacketNumber:    1,
+				Frames:          []wire.Frame{&wire.PingFrame{}},
+				Length:          100,
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+				SendTime:        time.Now(),
+			})
+			unpacker := NewMockUnpacker(mockCtrl)
+			unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+				encryptionLevel: protocol.EncryptionForwardSecure,
+				frames:          []wire.Frame{&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}},
+			}, nil).AnyTimes()
+			sess.unpacker = unpacker
+			packer.EXPECT().PackPacket().AnyTimes()
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).DoAndReturn(func(f *wire.ConnectionCloseFrame) (*packedPacket, error) {
+				Expect(f.ErrorCode).To(Equal(qerr.NetworkIdleTimeout))
+				return &packedPacket{}, nil
+			})
+			done := make(chan struct{})
+			start := time.Now()
+			go func() {
+				defer GinkgoRecover()
+				err := sess.run()
+				Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.NetworkIdleTimeout))
+				close(done)
+			}()
+			// keep sending ACK-only packets until the session times out
+			var pn protocol.PacketNumber
+			Eventually(func() <-chan struct{} {
+				pn++
+				sess.handlePacket(&receivedPacket{
+					header: &wire.Header{
+						PacketNumber:    pn,
+						PacketNumberLen: protocol.PacketNumberLen6,
+						Raw:             (*getPacketBuffer())[:0],
+					},
+					rcvTime: time.Now(),
+				})
+				return done
+			}, scaleDuration(time.Second), scaleDuration(10*time.Millisecond)).Should(BeClosed())
+			Expect(time.Since(start)).To(BeNumerically(">=", sess.config.IdleTimeout))
+		})
+
 		It("times out due to non-completed handshake", func() {
 			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
 			sess.sessionCreationTime = time.Now().Add(-protocol.DefaultHandshakeTimeout).Add(-time.Second)
@@ -1440,6 +2406,26 @@ var _ = Describe("Session", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("uses the configured handshake timeout", func() {
+			sess.config.HandshakeTimeout = scaleDuration(50 * time.Millisecond)
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).DoAndReturn(func(f *wire.ConnectionCloseFrame) (*packedPacket, error) {
+				Expect(f.ErrorCode).To(Equal(qerr.HandshakeTimeout))
+				return &packedPacket{}, nil
+			})
+			done := make(chan struct{})
+			start := time.Now()
+			go func() {
+				defer GinkgoRecover()
+				err := sess.run()
+				Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.HandshakeTimeout))
+				close(done)
+			}()
+			Eventually(done).Should(BeClosed())
+			Expect(time.Since(start)).To(BeNumerically(">=", sess.config.HandshakeTimeout))
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
 		It("does not use the idle timeout before the handshake complete", func() {
 			sess.config.IdleTimeout = 9999 * time.Second
 			defer sess.Close()
@@ -1481,6 +2467,93 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("connection deadline", func() {
+		var clock *mockClock
+
+		BeforeEach(func() {
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			packer.EXPECT().PackPacket().AnyTimes()
+			clock = useMockClock()
+		})
+
+		It("closes the session when the deadline passes, even if it's active", func() {
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).DoAndReturn(func(f *wire.ConnectionCloseFrame) (*packedPacket, error) {
+				Expect(f.ErrorCode).To(Equal(qerr.PeerGoingAway))
+				Expect(f.ReasonPhrase).To(Equal("Connection deadline exceeded."))
+				return &packedPacket{}, nil
+			})
+			sess.handshakeComplete = true
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := sess.run()
+				Expect(err).To(MatchError(qerr.Error(qerr.PeerGoingAway, "Connection deadline exceeded.")))
+				close(done)
+			}()
+			sess.SetConnectionDeadline(clock.Now().Add(10 * time.Second))
+			clock.Advance(10*time.Second - time.Millisecond)
+			Consistently(done).ShouldNot(BeClosed())
+			clock.Advance(time.Millisecond)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("removes the deadline", func() {
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			sess.SetConnectionDeadline(clock.Now().Add(time.Second))
+			sess.SetConnectionDeadline(time.Time{})
+			// make sure the run loop picked up the new deadline before advancing the clock
+			Eventually(func() int { return len(sess.connectionDeadlineChan) }).Should(BeZero())
+			clock.Advance(2 * time.Second)
+			Consistently(sess.Context().Done()).ShouldNot(BeClosed())
+			// make the go routine return
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&packedPacket{}, nil)
+			sess.Close()
+			Eventually(sess.Context().Done()).Should(BeClosed())
+		})
+	})
+
+	Context("using a mock clock", func() {
+		It("uses the clock for the ACK delay", func() {
+			clock := useMockClock()
+			Expect(sess.receivedPacketHandler.ReceivedPacket(1, clock.Now(), true)).To(Succeed())
+			clock.Advance(5 * time.Millisecond)
+			ack := sess.receivedPacketHandler.GetAckFrame()
+			Expect(ack).ToNot(BeNil())
+			Expect(ack.DelayTime).To(Equal(5 * time.Millisecond))
+		})
+
+		It("uses the clock for loss detection", func() {
+			clock := useMockClock()
+			sph := sess.sentPacketHandler
+			for i := 1; i <= 4; i++ {
+				sph.SentPacket(&ackhandler.Packet{
+					PacketNumber:    protocol.PacketNumber(i),
+					Frames:          []wire.Frame{&wire.PingFrame{}},
+					Length:          100,
+					EncryptionLevel: protocol.EncryptionForwardSecure,
+					SendTime:        clock.Now(),
+				})
+			}
+			clock.Advance(time.Second)
+			// Acknowledging packet 4 measures an RTT of 1s, and arms the loss detection alarm for packet 1.
+			// It is declared lost after 9/8 RTT.
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 4, Largest: 4}}}
+			Expect(sph.ReceivedAck(ack, 1, protocol.EncryptionForwardSecure, clock.Now())).To(Succeed())
+			Expect(sph.GetAlarmTimeout()).To(Equal(clock.Now().Add(time.Second / 8)))
+			clock.Advance(time.Second/8 - time.Millisecond)
+			Expect(sph.OnAlarm()).To(Succeed())
+			Expect(sph.DequeuePacketForRetransmission()).To(BeNil())
+			clock.Advance(2 * time.Millisecond)
+			Expect(sph.OnAlarm()).To(Succeed())
+			Expect(sph.DequeuePacketForRetransmission()).ToNot(BeNil())
+		})
+	})
+
 	It("stores up to MaxSessionUnprocessedPackets packets", func(done Done) {
 		// Nothing here should block
 		for i := protocol.PacketNumber(0); i < protocol.MaxSessionUnprocessedPackets+10; i++ {
@@ -1546,6 +2619,29 @@ var _ = Describe("Session", func() {
 			Expect(str).To(Equal(mstr))
 		})
 
+		It("doesn't open new streams after receiving a GOAWAY frame", func() {
+			mstr := NewMockStreamI(mockCtrl)
+			streamManager.EXPECT().OpenStream().Return(mstr, nil)
+			_, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			streamManager.EXPECT().CloseStreamsAbove(protocol.StreamID(5), errPeerGoneAway)
+			Expect(sess.handleFrames([]wire.Frame{&wire.GoawayFrame{LastGoodStream: 5}}, protocol.EncryptionForwardSecure)).To(Succeed())
+			_, err = sess.OpenStream()
+			Expect(err).To(MatchError(errPeerGoneAway))
+			_, err = sess.OpenStreamSync()
+			Expect(err).To(MatchError(errPeerGoneAway))
+			_, err = sess.OpenUniStream()
+			Expect(err).To(MatchError(errPeerGoneAway))
+			_, err = sess.OpenUniStreamSync()
+			Expect(err).To(MatchError(errPeerGoneAway))
+			// the stream that was opened before can still be used
+			f := &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}
+			mstr.EXPECT().setEncryptionLevel(protocol.EncryptionForwardSecure)
+			mstr.EXPECT().handleStreamFrame(f)
+			streamManager.EXPECT().GetOrOpenReceiveStream(protocol.StreamID(5)).Return(mstr, nil)
+			Expect(sess.handleStreamFrame(f, protocol.EncryptionForwardSecure)).To(Succeed())
+		})
+
 		It("accepts streams", func() {
 			mstr := NewMockStreamI(mockCtrl)
 			streamManager.EXPECT().AcceptStream().Return(mstr, nil)
@@ -1563,6 +2659,411 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	newSessionWithConfig := func(config *Config) *session {
+		aead, err := crypto.NewNullAEAD(protocol.PerspectiveServer, protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1}, protocol.Version39)
+		Expect(err).ToNot(HaveOccurred())
+		cs := &nullSealingCryptoSetup{sealer: aead}
+		newCryptoSetup = func(
+			_ io.ReadWriter,
+			_ protocol.ConnectionID,
+			_ net.Addr,
+			_ protocol.VersionNumber,
+			_ []byte,
+			_ []*handshake.ServerConfig,
+			_ *handshake.TransportParameters,
+			_ []protocol.VersionNumber,
+			_ func(net.Addr, *Cookie) bool,
+			_ chan<- handshake.TransportParameters,
+			_ chan<- struct{},
+			_ utils.Logger,
+		) (handshake.CryptoSetup, error) {
+			return cs, nil
+		}
+		pSess, err := newSession(
+			mconn,
+			sessionRunner,
+			protocol.Version39,
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1},
+			[]*handshake.ServerConfig{scfg},
+			nil,
+			populateServerConfig(config),
+			utils.DefaultClock{},
+			utils.DefaultLogger,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		return pSess.(*session)
+	}
+
+	Context("max packet size", func() {
+		It("uses the default max packet size for the remote address", func() {
+			mconn.remoteAddr = &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
+			sess := newSessionWithConfig(&Config{})
+			Expect(sess.maxPacketSize()).To(Equal(protocol.ByteCount(protocol.MaxPacketSizeIPv4)))
+		})
+
+		It("splits stream data according to the configured max packet size", func() {
+			sess := newSessionWithConfig(&Config{MaxPacketSize: protocol.MinInitialPacketSize})
+			sess.processTransportParameters(&handshake.TransportParameters{
+				MaxStreams:                  100,
+				StreamFlowControlWindow:     0x5000,
+				ConnectionFlowControlWindow: 0x5000,
+			})
+			// the first packet would have to contain crypto stream data
+			sess.packer.(*packetPackerLegacy).hasSentPacket = true
+			str, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := str.Write(bytes.Repeat([]byte{'f'}, 3000))
+				Expect(err).ToNot(HaveOccurred())
+				close(done)
+			}()
+			Eventually(func() int {
+				Expect(sess.sendPackets()).To(Succeed())
+				return len(mconn.written)
+			}).Should(BeNumerically(">=", 3))
+			Eventually(done).Should(BeClosed())
+			for len(mconn.written) > 1 {
+				Expect(<-mconn.written).To(HaveLen(protocol.MinInitialPacketSize))
+			}
+			Expect(len(<-mconn.written)).To(BeNumerically("<", protocol.MinInitialPacketSize))
+		})
+	})
+
+	Context("packet number length", func() {
+		sendPacket := func(sess *session) *wire.Header {
+			// the first packet would have to contain crypto stream data
+			sess.packer.(*packetPackerLegacy).hasSentPacket = true
+			sess.queueControlFrame(&wire.PingFrame{})
+			Expect(sess.sendPackets()).To(Succeed())
+			var packet []byte
+			Expect(mconn.written).To(Receive(&packet))
+			r := bytes.NewReader(packet)
+			iHdr, err := wire.ParseInvariantHeader(r, 0)
+			Expect(err).ToNot(HaveOccurred())
+			hdr, err := iHdr.Parse(r, protocol.PerspectiveServer, protocol.Version39)
+			Expect(err).ToNot(HaveOccurred())
+			return hdr
+		}
+
+		It("chooses the packet number length depending on the packets in flight by default", func() {
+			sess := newSessionWithConfig(&Config{})
+			Expect(sendPacket(sess).PacketNumberLen).To(Equal(protocol.PacketNumberLen2))
+		})
+
+		for _, l := range []protocol.PacketNumberLen{
+			protocol.PacketNumberLen1,
+			protocol.PacketNumberLen2,
+			protocol.PacketNumberLen4,
+		} {
+			pnLen := l
+
+			It(fmt.Sprintf("uses %d byte packet numbers, if configured", pnLen), func() {
+				sess := newSessionWithConfig(&Config{PacketNumberLength: int(pnLen)})
+				hdr := sendPacket(sess)
+				Expect(hdr.PacketNumberLen).To(Equal(pnLen))
+				Expect(hdr.PacketNumber).To(Equal(protocol.PacketNumber(1)))
+			})
+		}
+	})
+
+	Context("flushing streams", func() {
+		It("returns from Flush after the stream data was written", func() {
+			sess := newSessionWithConfig(&Config{WriteCoalescingDelay: time.Hour})
+			sess.processTransportParameters(&handshake.TransportParameters{
+				MaxStreams:                  100,
+				StreamFlowControlWindow:     0x5000,
+				ConnectionFlowControlWindow: 0x5000,
+			})
+			// the first packet would have to contain crypto stream data
+			sess.packer.(*packetPackerLegacy).hasSentPacket = true
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			str, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := str.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				close(done)
+			}()
+			Consistently(mconn.written).ShouldNot(Receive())
+			Expect(str.Flush()).To(Succeed())
+			Expect(mconn.written).To(Receive(ContainSubstring("foobar")))
+			Eventually(done).Should(BeClosed())
+			// make the go routine return
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
+		It("sends multiple writes on the same stream in one packet", func() {
+			sess := newSessionWithConfig(&Config{WriteCoalescingDelay: time.Hour})
+			sess.processTransportParameters(&handshake.TransportParameters{
+				MaxStreams:                  100,
+				StreamFlowControlWindow:     0x5000,
+				ConnectionFlowControlWindow: 0x5000,
+			})
+			// the first packet would have to contain crypto stream data
+			sess.packer.(*packetPackerLegacy).hasSentPacket = true
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			str, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			for _, data := range []string{"foo", "bar", "baz"} {
+				_, err := str.Write([]byte(data))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Consistently(mconn.written).ShouldNot(Receive())
+			Expect(str.Flush()).To(Succeed())
+			Expect(mconn.written).To(Receive(ContainSubstring("foobarbaz")))
+			Consistently(mconn.written).ShouldNot(Receive())
+			// make the go routine return
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
+		It("doesn't block Flush after the session was closed", func() {
+			sess := newSessionWithConfig(&Config{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+			Expect(sess.Close()).To(Succeed())
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Eventually(sess.flush()).Should(BeClosed())
+		})
+	})
+
+	Context("datagrams", func() {
+		var received [][]byte
+
+		BeforeEach(func() {
+			received = nil
+		})
+
+		newDatagramSession := func(config *Config) *session {
+			config.EnableDatagrams = true
+			config.OnDatagram = func(data []byte) {
+				received = append(received, append([]byte{}, data...))
+			}
+			sess := newSessionWithConfig(config)
+			sess.peerParams = &handshake.TransportParameters{SupportsDatagrams: true}
+			sess.handshakeComplete = true
+			return sess
+		}
+
+		parseDatagramFrame := func(packet []byte) *wire.DatagramFrame {
+			r := bytes.NewReader(packet)
+			iHdr, err := wire.ParseInvariantHeader(r, 0)
+			Expect(err).ToNot(HaveOccurred())
+			hdr, err := iHdr.Parse(r, protocol.PerspectiveServer, protocol.Version39)
+			Expect(err).ToNot(HaveOccurred())
+			r.Seek(12, io.SeekCurrent) // skip the FNV-1a hash of the null AEAD
+			frame, err := wire.ParseNextFrame(r, hdr, protocol.Version39)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&wire.DatagramFrame{}))
+			Expect(r.Len()).To(BeZero())
+			return frame.(*wire.DatagramFrame)
+		}
+
+		It("round-trips a datagram", func() {
+			sess := newDatagramSession(&Config{})
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			Expect(sess.sendPackets()).To(Succeed())
+			var packet []byte
+			Expect(mconn.written).To(Receive(&packet))
+			Expect(mconn.written).To(BeEmpty())
+			frame := parseDatagramFrame(packet)
+			Expect(frame.Data).To(Equal([]byte("foobar")))
+			Expect(sess.handleFrames([]wire.Frame{frame}, protocol.EncryptionForwardSecure)).To(Succeed())
+			Expect(received).To(Equal([][]byte{[]byte("foobar")}))
+		})
+
+		It("sends every datagram in a packet of its own", func() {
+			sess := newDatagramSession(&Config{})
+			Expect(sess.SendDatagram([]byte("foo"))).To(Succeed())
+			Expect(sess.SendDatagram([]byte("bar"))).To(Succeed())
+			// sending is paced, so every call only sends a single packet
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(1))
+			Expect(parseDatagramFrame(<-mconn.written).Data).To(Equal([]byte("foo")))
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(1))
+			Expect(parseDatagramFrame(<-mconn.written).Data).To(Equal([]byte("bar")))
+		})
+
+		It("doesn't retransmit datagrams", func() {
+			sess := newDatagramSession(&Config{})
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(1))
+			Expect(sess.sentPacketHandler.GetBytesInFlight()).To(BeZero())
+		})
+
+		It("holds back datagrams until the handshake completes", func() {
+			sess := newDatagramSession(&Config{})
+			sess.handshakeComplete = false
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(BeEmpty())
+			sess.handshakeComplete = true
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(HaveLen(1))
+		})
+
+		It("errors if the datagram doesn't fit into a packet", func() {
+			sess := newDatagramSession(&Config{MaxPacketSize: protocol.MinInitialPacketSize})
+			maxSize := int(sess.maxDatagramSize)
+			Expect(maxSize).To(BeNumerically(">", protocol.MinInitialPacketSize-50))
+			err := sess.SendDatagram(make([]byte, maxSize+1))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("datagram too large"))
+			Expect(sess.SendDatagram(make([]byte, maxSize))).To(Succeed())
+			Expect(sess.sendPackets()).To(Succeed())
+			var packet []byte
+			Expect(mconn.written).To(Receive(&packet))
+			Expect(len(packet)).To(BeNumerically("<=", protocol.MinInitialPacketSize))
+			Expect(parseDatagramFrame(packet).Data).To(HaveLen(maxSize))
+		})
+
+		It("errors if too many datagrams are queued", func() {
+			sess := newDatagramSession(&Config{})
+			for i := 0; i < protocol.MaxDatagramQueueLen; i++ {
+				Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			}
+			Expect(sess.SendDatagram([]byte("foobar"))).To(MatchError(errDatagramQueueFull))
+		})
+
+		It("drops received datagrams if no callback is set", func() {
+			sess := newSessionWithConfig(&Config{EnableDatagrams: true})
+			Expect(sess.handleFrames([]wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}}, protocol.EncryptionForwardSecure)).To(Succeed())
+		})
+
+		It("errors when sending datagrams if they are not enabled", func() {
+			sess := newSessionWithConfig(&Config{})
+			Expect(sess.SendDatagram([]byte("foobar"))).To(MatchError(errDatagramsDisabled))
+		})
+
+		It("errors when sending datagrams if the peer doesn't support them", func() {
+			sess := newDatagramSession(&Config{})
+			sess.peerParams = &handshake.TransportParameters{}
+			Expect(sess.SendDatagram([]byte("foobar"))).To(MatchError(errPeerDoesntSupportDatagrams))
+		})
+
+		It("drops queued datagrams if the peer turns out not to support them", func() {
+			sess := newDatagramSession(&Config{})
+			sess.peerParams = nil
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			sess.peerParams = &handshake.TransportParameters{}
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(BeEmpty())
+			Expect(sess.datagramQueue).To(BeEmpty())
+		})
+
+		It("doesn't send datagrams when congestion limited", func() {
+			sess := newDatagramSession(&Config{})
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			sph.EXPECT().SendMode().Return(ackhandler.SendAck)
+			sph.EXPECT().ShouldSendNumPackets().Return(1)
+			Expect(sess.SendDatagram([]byte("foobar"))).To(Succeed())
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(BeEmpty())
+			Expect(sess.datagramQueue).To(HaveLen(1))
+		})
+
+		It("errors when receiving datagrams if they are not enabled", func() {
+			sess := newSessionWithConfig(&Config{})
+			err := sess.handleFrames([]wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}}, protocol.EncryptionForwardSecure)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidFrameData))
+		})
+	})
+
+	It("sends the handshake messages of the crypto setup on the crypto stream", func() {
+		newCryptoSetup = handshake.NewCryptoSetup
+		connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+		pSess, err := newSession(mconn, sessionRunner, protocol.Version39, connID, connID, []*handshake.ServerConfig{scfg}, nil, populateServerConfig(&Config{}), utils.DefaultClock{}, utils.DefaultLogger)
+		Expect(err).ToNot(HaveOccurred())
+		sess = pSess.(*session)
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+			close(done)
+		}()
+
+		// receive a CHLO
+		hdr := &wire.Header{
+			VersionFlag:      true,
+			Version:          protocol.Version39,
+			DestConnectionID: connID,
+			PacketNumber:     1,
+			PacketNumberLen:  protocol.PacketNumberLen1,
+		}
+		buf := &bytes.Buffer{}
+		Expect(hdr.Write(buf, protocol.PerspectiveClient, protocol.Version39)).To(Succeed())
+		chlo := &bytes.Buffer{}
+		handshake.HandshakeMessage{Tag: handshake.TagCHLO, Data: map[handshake.Tag][]byte{
+			handshake.TagSNI: []byte("quic.clemente.io"),
+			handshake.TagVER: {'Q', '0', '3', '9'},
+			handshake.TagPAD: make([]byte, protocol.MinClientHelloSize),
+		}}.Write(chlo)
+		payload := &bytes.Buffer{}
+		Expect((&wire.StreamFrame{StreamID: protocol.Version39.CryptoStreamID(), Data: chlo.Bytes()}).Write(payload, protocol.Version39)).To(Succeed())
+		aead, err := crypto.NewNullAEAD(protocol.PerspectiveClient, connID, protocol.Version39)
+		Expect(err).ToNot(HaveOccurred())
+		// the session returns the header to the buffer pool
+		data := append((*getPacketBuffer())[:0], buf.Bytes()...)
+		aead.Seal(data[buf.Len():], payload.Bytes(), hdr.PacketNumber, buf.Bytes())
+		data = data[:buf.Len()+int(aead.Overhead())+payload.Len()]
+		hdr.Raw = data[:buf.Len()]
+		sess.handlePacket(&receivedPacket{
+			remoteAddr: mconn.RemoteAddr(),
+			header:     hdr,
+			data:       data[buf.Len():],
+			rcvTime:    time.Now(),
+		})
+
+		// the server replies with a REJ
+		var packet []byte
+		Eventually(mconn.written).Should(Receive(&packet))
+		r := bytes.NewReader(packet)
+		iHdr, err := wire.ParseInvariantHeader(r, 0)
+		Expect(err).ToNot(HaveOccurred())
+		replyHdr, err := iHdr.Parse(r, protocol.PerspectiveServer, protocol.Version39)
+		Expect(err).ToNot(HaveOccurred())
+		r.Seek(12, io.SeekCurrent) // skip the FNV-1a hash of the null AEAD
+		var message handshake.HandshakeMessage
+		for {
+			frame, err := wire.ParseNextFrame(r, replyHdr, protocol.Version39)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).ToNot(BeNil())
+			if sf, ok := frame.(*wire.StreamFrame); ok && sf.StreamID == protocol.Version39.CryptoStreamID() {
+				message, err = handshake.ParseHandshakeMessage(bytes.NewReader(sf.Data))
+				Expect(err).ToNot(HaveOccurred())
+				break
+			}
+		}
+		Expect(message.Tag).To(Equal(handshake.TagREJ))
+		Expect(message.Data).To(HaveKey(handshake.TagSCFG))
+
+		// make the go routine return
+		sessionRunner.EXPECT().removeConnectionID(gomock.Any())
+		Expect(sess.Close()).To(Succeed())
+		Eventually(done).Should(BeClosed())
+	})
+
 	It("returns the local address", func() {
 		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
 		mconn.localAddr = addr
@@ -1619,6 +3120,7 @@ var _ = Describe("Client Session", func() {
 			populateClientConfig(&Config{}, false),
 			protocol.VersionWhatever,
 			nil,
+			utils.DefaultClock{},
 			utils.DefaultLogger,
 		)
 		sess = sessP.(*session)
@@ -1654,6 +3156,18 @@ var _ = Describe("Client Session", func() {
 		Eventually(sess.Context().Done()).Should(BeClosed())
 	})
 
+	Context("validating stream IDs", func() {
+		It("accepts STREAM frames on streams opened by the server", func() {
+			err := sess.handleStreamFrame(&wire.StreamFrame{StreamID: 4, Data: []byte("foobar")}, protocol.EncryptionForwardSecure)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects STREAM frames on client-initiated streams that the client didn't open", func() {
+			err := sess.handleStreamFrame(&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidStreamID, "peer attempted to open stream 5")))
+		})
+	})
+
 	It("changes the connection ID when receiving the first packet from the server", func() {
 		sess.version = protocol.VersionTLS
 		unpacker := NewMockUnpacker(mockCtrl)
@@ -19,6 +19,11 @@ const (
 type streamSender interface {
 	queueControlFrame(wire.Frame)
 	onHasStreamData(protocol.StreamID)
+	// says if stream data is held back to coalesce small writes
+	coalescesWrites() bool
+	// sends stream data immediately, even if sending is delayed to coalesce small writes.
+	// The returned channel is closed once the stream data packed so far has been written.
+	flush() <-chan struct{}
 	// must be called without holding the mutex that is acquired by closeForShutdown
 	onStreamCompleted(protocol.StreamID)
 }
@@ -49,6 +54,7 @@ type streamI interface {
 	closeForShutdown(error)
 	// for receiving
 	handleStreamFrame(*wire.StreamFrame) error
+	setEncryptionLevel(protocol.EncryptionLevel)
 	handleRstStreamFrame(*wire.RstStreamFrame) error
 	getWindowUpdate() protocol.ByteCount
 	// for sending
@@ -56,6 +62,8 @@ type streamI interface {
 	handleStopSendingFrame(*wire.StopSendingFrame)
 	popStreamFrame(maxBytes protocol.ByteCount) (*wire.StreamFrame, bool)
 	handleMaxStreamDataFrame(*wire.MaxStreamDataFrame)
+	writeCanceled() bool
+	closeError() error
 }
 
 var _ receiveStreamI = (streamI)(nil)
@@ -168,6 +176,15 @@ func (s *stream) handleRstStreamFrame(frame *wire.RstStreamFrame) error {
 	return nil
 }
 
+// closeError returns the error that one of the stream halves was canceled with.
+// A RST_STREAM frame received from the peer takes precedence.
+func (s *stream) closeError() error {
+	if err := s.receiveStream.closeError(); err != nil {
+		return err
+	}
+	return s.sendStream.closeError()
+}
+
 // checkIfCompleted is called from the uniStreamSender, when one of the stream halves is completed.
 // It makes sure that the onStreamCompleted callback is only called if both receive and send side have completed.
 func (s *stream) checkIfCompleted() {
@@ -2,10 +2,13 @@ package quic
 
 import (
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
@@ -38,6 +41,7 @@ var _ = Describe("Stream", func() {
 
 	BeforeEach(func() {
 		mockSender = NewMockStreamSender(mockCtrl)
+		mockSender.EXPECT().coalescesWrites().AnyTimes()
 		mockFC = mocks.NewMockStreamFlowController(mockCtrl)
 		str = newStream(streamID, mockSender, mockFC, protocol.VersionWhatever)
 
@@ -176,16 +180,91 @@ var _ = Describe("Stream", func() {
 		})
 
 		It("sets a read deadline, when SetDeadline is called", func() {
-			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false).AnyTimes()
-			f := &wire.StreamFrame{Data: []byte("foobar")}
-			err := str.handleStreamFrame(f)
-			Expect(err).ToNot(HaveOccurred())
 			str.SetDeadline(time.Now().Add(-time.Second))
 			b := make([]byte, 6)
 			n, err := strWithTimeout.Read(b)
 			Expect(err).To(MatchError(errDeadline))
 			Expect(n).To(BeZero())
 		})
+
+		It("returns timeout errors when the read and write deadlines are in the past", func() {
+			str.SetWriteDeadline(time.Now().Add(-time.Second))
+			_, err := strWithTimeout.Write([]byte("foobar"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.(net.Error).Timeout()).To(BeTrue())
+			str.SetReadDeadline(time.Now().Add(-time.Second))
+			_, err = strWithTimeout.Read(make([]byte, 6))
+			Expect(err).To(HaveOccurred())
+			Expect(err.(net.Error).Timeout()).To(BeTrue())
+		})
+	})
+
+	Context("half-closing", func() {
+		writeAndPop := func(data []byte) {
+			writeReturned := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := strWithTimeout.Write(data)
+				Expect(err).ToNot(HaveOccurred())
+				close(writeReturned)
+			}()
+			var frame *wire.StreamFrame
+			Eventually(func() *wire.StreamFrame {
+				frame, _ = str.popStreamFrame(1000)
+				return frame
+			}).ShouldNot(BeNil())
+			Expect(frame.Data).To(Equal(data))
+			Expect(frame.FinBit).To(BeFalse())
+			Eventually(writeReturned).Should(BeClosed())
+		}
+
+		readAll := func() []byte {
+			data, err := ioutil.ReadAll(strWithTimeout)
+			Expect(err).ToNot(HaveOccurred())
+			return data
+		}
+
+		BeforeEach(func() {
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+			mockFC.EXPECT().AddBytesRead(gomock.Any()).AnyTimes()
+			mockFC.EXPECT().MaybeQueueWindowUpdate().AnyTimes()
+		})
+
+		It("allows writing after receiving a FIN, until the stream is closed", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{StreamID: streamID, Data: []byte("foobar"), FinBit: true})).To(Succeed())
+			Expect(readAll()).To(Equal([]byte("foobar")))
+			mockSender.EXPECT().onHasStreamData(streamID).Times(3) // twice for Write, once for Close
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(3)).Times(2)
+			writeAndPop([]byte("foo"))
+			writeAndPop([]byte("bar"))
+			Expect(str.Close()).To(Succeed())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			frame, _ := str.popStreamFrame(1000)
+			Expect(frame).ToNot(BeNil())
+			Expect(frame.Offset).To(Equal(protocol.ByteCount(6)))
+			Expect(frame.FinBit).To(BeTrue())
+		})
+
+		It("allows reading after sending a FIN, until the peer sends a FIN", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).Times(2) // once for Write, once for Close
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			writeAndPop([]byte("foobar"))
+			Expect(str.Close()).To(Succeed())
+			frame, _ := str.popStreamFrame(1000)
+			Expect(frame).ToNot(BeNil())
+			Expect(frame.FinBit).To(BeTrue())
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(3), false)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{StreamID: streamID, Data: []byte("foo")})).To(Succeed())
+			b := make([]byte, 3)
+			_, err := io.ReadFull(strWithTimeout, b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(Equal([]byte("foo")))
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{StreamID: streamID, Offset: 3, Data: []byte("bar"), FinBit: true})).To(Succeed())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			Expect(readAll()).To(Equal([]byte("bar")))
+		})
 	})
 
 	Context("completing", func() {
@@ -204,5 +283,23 @@ var _ = Describe("Stream", func() {
 			str.sendStream.sender.onStreamCompleted(streamID)
 			str.receiveStream.sender.onStreamCompleted(streamID)
 		})
+
+		It("has no close error if it wasn't canceled", func() {
+			Expect(str.closeError()).ToNot(HaveOccurred())
+		})
+
+		It("returns the error of a RST_STREAM frame as the close error", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(10), true)
+			Expect(str.CancelWrite(1234)).To(Succeed())
+			Expect(str.closeError()).To(MatchError("Write on stream 1337 canceled with error code 1234"))
+			Expect(str.handleRstStreamFrame(&wire.RstStreamFrame{
+				StreamID:   streamID,
+				ByteOffset: 10,
+				ErrorCode:  42,
+			})).To(Succeed())
+			Expect(str.closeError()).To(MatchError("Stream 1337 was reset with error code 42"))
+		})
 	})
 })
@@ -191,6 +191,27 @@ func (m *streamsMap) GetOrOpenSendStream(id protocol.StreamID) (sendStreamI, err
 	}
 }
 
+// GetSendStream returns the stream, or nil if it doesn't exist.
+// In contrast to GetOrOpenSendStream, it never opens a new stream.
+func (m *streamsMap) GetSendStream(id protocol.StreamID) sendStreamI {
+	var str sendStreamI
+	switch m.getStreamType(id) {
+	case streamTypeOutgoingBidi:
+		if s, err := m.outgoingBidiStreams.GetStream(id); err == nil && s != nil {
+			str = s
+		}
+	case streamTypeIncomingBidi:
+		if s := m.incomingBidiStreams.GetStream(id); s != nil {
+			str = s
+		}
+	case streamTypeOutgoingUni:
+		if s, err := m.outgoingUniStreams.GetStream(id); err == nil && s != nil {
+			str = s
+		}
+	}
+	return str
+}
+
 func (m *streamsMap) HandleMaxStreamIDFrame(f *wire.MaxStreamIDFrame) error {
 	id := f.StreamID
 	switch m.getStreamType(id) {
@@ -216,6 +237,11 @@ func (m *streamsMap) UpdateLimits(p *handshake.TransportParameters) {
 	m.outgoingUniStreams.SetMaxStream(protocol.MaxUniStreamID(int(p.MaxUniStreams), peerPers))
 }
 
+// should never be called, since GOAWAY frames can only be unpacked for gQUIC
+func (m *streamsMap) CloseStreamsAbove(protocol.StreamID, error) []protocol.StreamID {
+	return nil
+}
+
 func (m *streamsMap) CloseWithError(err error) {
 	m.outgoingBidiStreams.CloseWithError(err)
 	m.outgoingUniStreams.CloseWithError(err)
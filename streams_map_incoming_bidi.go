@@ -104,6 +104,14 @@ func (m *incomingBidiStreamsMap) GetOrOpenStream(id protocol.StreamID) (streamI,
 	return s, nil
 }
 
+// GetStream returns the stream, or nil if the stream doesn't exist.
+// In contrast to GetOrOpenStream, it never opens a new stream.
+func (m *incomingBidiStreamsMap) GetStream(id protocol.StreamID) streamI {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.streams[id]
+}
+
 func (m *incomingBidiStreamsMap) DeleteStream(id protocol.StreamID) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
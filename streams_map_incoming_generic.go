@@ -102,6 +102,14 @@ func (m *incomingItemsMap) GetOrOpenStream(id protocol.StreamID) (item, error) {
 	return s, nil
 }
 
+// GetStream returns the stream, or nil if the stream doesn't exist.
+// In contrast to GetOrOpenStream, it never opens a new stream.
+func (m *incomingItemsMap) GetStream(id protocol.StreamID) item {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.streams[id]
+}
+
 func (m *incomingItemsMap) DeleteStream(id protocol.StreamID) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
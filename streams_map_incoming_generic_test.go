@@ -54,6 +54,16 @@ var _ = Describe("Streams Map (incoming)", func() {
 		Expect(newItemCounter).To(Equal(6))
 	})
 
+	It("gets streams without opening them", func() {
+		Expect(m.GetStream(firstNewStream)).To(BeNil())
+		Expect(newItemCounter).To(BeZero())
+		_, err := m.GetOrOpenStream(firstNewStream)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.GetStream(firstNewStream).(*mockGenericStream).id).To(Equal(firstNewStream))
+		Expect(m.GetStream(firstNewStream + 4)).To(BeNil())
+		Expect(newItemCounter).To(Equal(1))
+	})
+
 	It("starts opening streams at the right position", func() {
 		// like the test above, but with 2 calls to GetOrOpenStream
 		_, err := m.GetOrOpenStream(firstNewStream + 4)
@@ -104,6 +104,14 @@ func (m *incomingUniStreamsMap) GetOrOpenStream(id protocol.StreamID) (receiveSt
 	return s, nil
 }
 
+// GetStream returns the stream, or nil if the stream doesn't exist.
+// In contrast to GetOrOpenStream, it never opens a new stream.
+func (m *incomingUniStreamsMap) GetStream(id protocol.StreamID) receiveStreamI {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.streams[id]
+}
+
 func (m *incomingUniStreamsMap) DeleteStream(id protocol.StreamID) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -87,6 +87,18 @@ func (m *streamsMapLegacy) GetOrOpenSendStream(id protocol.StreamID) (sendStream
 	return m.getOrOpenStream(id)
 }
 
+// GetSendStream returns the stream, or nil if it doesn't exist.
+// In contrast to GetOrOpenSendStream, it never opens a new stream.
+func (m *streamsMapLegacy) GetSendStream(id protocol.StreamID) sendStreamI {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	s, ok := m.streams[id]
+	if !ok {
+		return nil
+	}
+	return s
+}
+
 // getOrOpenStream either returns an existing stream, a newly opened stream, or nil if a stream with the provided ID is already closed.
 // Newly opened streams should only originate from the client. To open a stream from the server, OpenStream should be used.
 func (m *streamsMapLegacy) getOrOpenStream(id protocol.StreamID) (streamI, error) {
@@ -257,6 +269,27 @@ func (m *streamsMapLegacy) CloseWithError(err error) {
 	}
 }
 
+// CloseStreamsAbove closes and deletes the streams opened by us that have a stream ID larger than id.
+// It returns the IDs of the streams that were closed.
+func (m *streamsMapLegacy) CloseStreamsAbove(id protocol.StreamID, err error) []protocol.StreamID {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var closed []protocol.StreamID
+	for sid, s := range m.streams {
+		if sid <= id || m.streamInitiatedBy(sid) != m.perspective {
+			continue
+		}
+		s.closeForShutdown(err)
+		delete(m.streams, sid)
+		m.numOutgoingStreams--
+		closed = append(closed, sid)
+	}
+	if len(closed) > 0 {
+		m.openStreamOrErrCond.Signal()
+	}
+	return closed
+}
+
 // TODO(#952): this won't be needed when gQUIC supports stateless handshakes
 func (m *streamsMapLegacy) UpdateLimits(params *handshake.TransportParameters) {
 	m.mutex.Lock()
@@ -543,6 +543,40 @@ var _ = Describe("Streams Map (for gQUIC)", func() {
 		})
 	})
 
+	It("gets send streams without opening them", func() {
+		setNewStreamsMap(protocol.PerspectiveServer)
+		Expect(m.GetSendStream(3)).To(BeNil())
+		Expect(m.streams).To(BeEmpty())
+		_, err := m.getOrOpenStream(3)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.GetSendStream(3).StreamID()).To(Equal(protocol.StreamID(3)))
+		Expect(m.GetSendStream(5)).To(BeNil())
+		Expect(m.streams).To(HaveLen(1))
+	})
+
+	It("closes the streams opened by us that the peer won't process", func() {
+		setNewStreamsMap(protocol.PerspectiveClient)
+		m.UpdateLimits(&handshake.TransportParameters{MaxStreams: 10000})
+		for i := 0; i < 3; i++ { // open stream 3, 5 and 7
+			_, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+		}
+		_, err := m.getOrOpenStream(4) // open stream 2 and 4
+		Expect(err).ToNot(HaveOccurred())
+		testErr := errors.New("test err")
+		m.streams[5].(*MockStreamI).EXPECT().closeForShutdown(testErr)
+		m.streams[7].(*MockStreamI).EXPECT().closeForShutdown(testErr)
+		Expect(m.CloseStreamsAbove(3, testErr)).To(ConsistOf(protocol.StreamID(5), protocol.StreamID(7)))
+		Expect(m.streams).To(HaveLen(3))
+		Expect(m.streams).To(HaveKey(protocol.StreamID(2)))
+		Expect(m.streams).To(HaveKey(protocol.StreamID(3)))
+		Expect(m.streams).To(HaveKey(protocol.StreamID(4)))
+		Expect(m.numOutgoingStreams).To(BeEquivalentTo(1))
+		str, err := m.getOrOpenStream(5)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str).To(BeNil())
+	})
+
 	It("sets the flow control limit", func() {
 		setNewStreamsMap(protocol.PerspectiveServer)
 		_, err := m.getOrOpenStream(5)
@@ -221,6 +221,23 @@ var _ = Describe("Streams Map (for IETF QUIC)", func() {
 						_, err := m.GetOrOpenSendStream(id)
 						Expect(err).To(MatchError(fmt.Errorf("peer attempted to open send stream %d", id)))
 					})
+
+					It("gets send streams without opening them", func() {
+						Expect(m.GetSendStream(ids.firstOutgoingBidiStream)).To(BeNil())
+						Expect(m.GetSendStream(ids.firstOutgoingUniStream)).To(BeNil())
+						Expect(m.GetSendStream(ids.firstIncomingBidiStream)).To(BeNil())
+						Expect(m.GetSendStream(ids.firstIncomingUniStream)).To(BeNil())
+						_, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = m.OpenUniStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = m.GetOrOpenSendStream(ids.firstIncomingBidiStream)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(m.GetSendStream(ids.firstOutgoingBidiStream).StreamID()).To(Equal(ids.firstOutgoingBidiStream))
+						Expect(m.GetSendStream(ids.firstOutgoingUniStream).StreamID()).To(Equal(ids.firstOutgoingUniStream))
+						Expect(m.GetSendStream(ids.firstIncomingBidiStream).StreamID()).To(Equal(ids.firstIncomingBidiStream))
+						Expect(m.GetSendStream(ids.firstIncomingBidiStream + 4)).To(BeNil())
+					})
 				})
 
 				Context("receive streams", func() {
@@ -0,0 +1,41 @@
+package quic
+
+import (
+	"fmt"
+	"sync"
+)
+
+// recordingTracer records all events as strings
+type recordingTracer struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+var _ Tracer = &recordingTracer{}
+
+func (t *recordingTracer) record(format string, a ...interface{}) {
+	t.mutex.Lock()
+	t.events = append(t.events, fmt.Sprintf(format, a...))
+	t.mutex.Unlock()
+}
+
+func (t *recordingTracer) Events() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string{}, t.events...)
+}
+
+func (t *recordingTracer) SentPacket(pn PacketNumber, size ByteCount, encLevel EncryptionLevel) {
+	t.record("sent %d (%d bytes, %s)", pn, size, encLevel)
+}
+func (t *recordingTracer) ReceivedPacket(pn PacketNumber, size ByteCount, encLevel EncryptionLevel) {
+	t.record("received %d (%d bytes, %s)", pn, size, encLevel)
+}
+func (t *recordingTracer) LostPacket(pn PacketNumber, size ByteCount) {
+	t.record("lost %d (%d bytes)", pn, size)
+}
+func (t *recordingTracer) OpenedStream(id StreamID) { t.record("opened stream %d", id) }
+func (t *recordingTracer) CompletedHandshake()      { t.record("completed handshake") }
+func (t *recordingTracer) ClosedConnection(err error) {
+	t.record("closed: %v", err)
+}